@@ -0,0 +1,349 @@
+package search
+
+import (
+	"net"
+	"regexp"
+	"time"
+)
+
+/*
+Node is an element of the tree that a query is parsed into.
+
+Term nodes are the leaves of the tree, while AndNode, OrNode and NotNode
+combine other nodes.
+*/
+type Node interface {
+	/*
+		Span returns the byte offsets of the start and end of the text in the
+		query that the node was parsed from.  The end is exclusive.
+	*/
+	Span() (start, end int)
+
+	// node restricts the implementations of Node to this package
+	node()
+}
+
+/*
+Term matches records that contain Phrase.
+
+If Field is not empty the match is restricted to the named field.  Kind
+describes how the Phrase is matched.  Comparison terms also record the Op and
+the Time, Number or Network being compared against, or compare with the Phrase
+itself.  Range terms hold their lower bound in Op and Number or Time, and their
+upper bound in UpperOp and Upper or UpperTime.  Fuzzy terms hold the number of
+edits allowed in Distance, while NEAR terms hold the next phrase in Near, which
+may itself have a Near, and the number of words that may separate each phrase
+from the one before it in Distance.  Regular expression terms hold the
+compiled pattern in Regexp, with the Phrase as written between the slashes.
+Optional terms, written with a leading ?, only add to the score of a record
+rather than having to match.  Boosted terms, written with a trailing ^ and a
+number as in tag:book^2, multiply their weight in the score by Boost, with 0
+leaving it unchanged.
+*/
+type Term struct {
+	Field      string
+	Phrase     string
+	Kind       TermKind
+	Op         Op
+	Time       time.Time
+	Number     float64
+	UpperOp    Op
+	Upper      float64
+	UpperTime  time.Time
+	Distance   int
+	Near       *Term
+	Network    *net.IPNet
+	Regexp     *regexp.Regexp
+	Optional   bool
+	Boost      float64
+	Start, End int
+}
+
+/*
+TermKind describes how a Term is matched against a record.
+*/
+type TermKind int
+
+const (
+	// TermContains terms ask the Searchable whether it contains the Phrase
+	TermContains TermKind = iota
+	// TermFieldName terms match records that have a field whose name matches the Phrase, written _field_:pattern
+	TermFieldName
+	// TermDate terms compare a date held in the field with Time, written field:>date
+	TermDate
+	// TermNumber terms compare a number held in the field with Number, written field:>number
+	TermNumber
+	// TermString terms compare the text held in the field with the Phrase, written field:>word
+	TermString
+	// TermPrefix terms match records whose field starts with the Phrase, written field:^="phrase"
+	TermPrefix
+	// TermCIDR terms match records whose field holds an IP address within Network, written field:10.0.0.0/8
+	TermCIDR
+	// TermWildcard terms match records with a word starting with the Phrase, written boa*
+	TermWildcard
+	// TermRange terms match records whose field holds a number between Number and Upper, written field:[5 TO 20]
+	TermRange
+	// TermDateRange terms match records whose field holds a date between Time and UpperTime, written field:[2020-01-01 TO 2020-12-31]
+	TermDateRange
+	// TermFuzzy terms match records with words within Distance edits of the Phrase, written whale~1
+	TermFuzzy
+	// TermNear terms match records with the Phrase and those of the Near terms within Distance words of each other, written whale NEAR/5 boat
+	TermNear
+	// TermNearOrdered terms are TermNear terms whose phrases must be in order, written whale ONEAR/5 boat
+	TermNearOrdered
+	// TermFieldExists terms match records that have the field named by the Phrase, written _exists_:field
+	TermFieldExists
+	// TermRegex terms match records whose field matches the regular expression in Regexp, written field:/pattern/
+	TermRegex
+)
+
+/*
+AndNode matches records that match all of its Children.
+
+An AndNode with no children matches everything.  The top of a parsed query and
+any bracketed groups are represented as an AndNode, with the span of a group
+including its brackets.  Groups that are ANDed with their neighbours, such as
+in "boat (whale shark)", are merged into the enclosing AndNode.
+*/
+type AndNode struct {
+	Children   []Node
+	Start, End int
+}
+
+/*
+OrNode matches records that match any of its Children.
+
+Chains of ORs, such as "a OR b OR c", are held in a single OrNode.  The span of
+an OrNode runs from the start of its first child to the end of its last child.
+*/
+type OrNode struct {
+	Children   []Node
+	Start, End int
+}
+
+/*
+NotNode matches records that do not match Child.
+
+The span of a NotNode includes the NOT keyword.
+*/
+type NotNode struct {
+	Child      Node
+	Start, End int
+}
+
+func (n *Term) Span() (start, end int)    { return n.Start, n.End }
+func (n *AndNode) Span() (start, end int) { return n.Start, n.End }
+func (n *OrNode) Span() (start, end int)  { return n.Start, n.End }
+func (n *NotNode) Span() (start, end int) { return n.Start, n.End }
+
+func (*Term) node()    {}
+func (*AndNode) node() {}
+func (*OrNode) node()  {}
+func (*NotNode) node() {}
+
+// newOrNode builds an OrNode spanning the children
+func newOrNode(children ...Node) *OrNode {
+	start, _ := children[0].Span()
+	_, end := children[len(children)-1].Span()
+	return &OrNode{Children: children, Start: start, End: end}
+}
+
+// orWith combines the node with the previous node in an OR.
+// If the previous node is already an OR, the node is added to it so that chains such as "a OR b OR c" are flat.
+func orWith(previous, n Node) *OrNode {
+	if or, ok := previous.(*OrNode); ok {
+		or.Children = append(or.Children, n)
+		_, or.End = n.Span()
+		return or
+	}
+	return newOrNode(previous, n)
+}
+
+/*
+Walk calls fn for n and then, if fn returns true, for each of the nodes beneath
+it in query order.
+*/
+func Walk(n Node, fn func(n Node) (descend bool)) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range children(n) {
+		Walk(child, fn)
+	}
+}
+
+// markOptional makes the terms in nodes optional, as for the group ?(boat whale), other than those within a NOT
+func markOptional(nodes []Node) {
+	for _, n := range nodes {
+		Walk(n, func(n Node) bool {
+			if term, ok := n.(*Term); ok {
+				term.Optional = true
+			}
+			_, isNot := n.(*NotNode)
+			return !isNot
+		})
+	}
+}
+
+// isOptional returns true if n is an optional term, or an OR of them such as ?tag:(book,leaflet)
+func isOptional(n Node) bool {
+	switch n := n.(type) {
+	case *Term:
+		return n.Optional
+	case *OrNode:
+		for _, child := range n.Children {
+			if !isOptional(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// children returns the nodes directly beneath n
+func children(n Node) []Node {
+	switch n := n.(type) {
+	case *AndNode:
+		return n.Children
+	case *OrNode:
+		return n.Children
+	case *NotNode:
+		return []Node{n.Child}
+	}
+	return nil
+}
+
+// nodeAt returns the deepest node beneath and including n whose span contains pos
+func nodeAt(n Node, pos int) Node {
+	if start, end := n.Span(); pos < start || pos >= end {
+		return nil
+	}
+	for _, child := range children(n) {
+		if found := nodeAt(child, pos); found != nil {
+			return found
+		}
+	}
+	return n
+}
+
+// copyTerm returns a copy of the term, including any Near terms that follow it
+func copyTerm(term *Term) *Term {
+	copied := *term
+	for last := &copied; last.Near != nil; last = last.Near {
+		next := *last.Near
+		last.Near = &next
+	}
+	return &copied
+}
+
+// termWrapper changes the filter compiled for a term, used to alter a single search
+type termWrapper func(term *Term, f filter) filter
+
+// compile turns the node into a filter that carries out the search.
+// If wrap is not nil it is applied to the filter of each term.
+func compile(n Node, opts Options, wrap termWrapper) filter {
+	switch n := n.(type) {
+	case *Term:
+		f := compileTerm(n, opts)
+		if forwardsKind(n.Kind) {
+			f = forwarded(n.Field, f)
+		}
+		if opts.TreatMissingFieldAsMatch && n.Field != "" {
+			f = missingFieldMatches(n.Field, f)
+		}
+		if wrap != nil {
+			f = wrap(n, f)
+		}
+		return f
+	case *AndNode:
+		return compileAnd(n.Children, opts, wrap).Search
+	case *OrNode:
+		return orFilter(compileAll(n.Children, opts, wrap)...)
+	case *NotNode:
+		switch child := n.Child.(type) {
+		case *Term:
+			if child.Kind == TermContains && wrap == nil && !opts.CaseInsensitive && (child.Field == "" || !opts.TreatMissingFieldAsMatch) {
+				return mustNotContain(child.Field, child.Phrase, opts.matchFunc())
+			}
+		case *AndNode:
+			return notFilter(compileAnd(child.Children, opts, wrap)...)
+		}
+		return notFilter(compile(n.Child, opts, wrap))
+	}
+	panic("search: unknown node type")
+}
+
+// compileTerm turns the term into a filter using the kind of search the term asks for
+func compileTerm(n *Term, opts Options) filter {
+	match := opts.matchFunc()
+	switch n.Kind {
+	case TermFieldName:
+		return mustHaveFieldName(n.Phrase)
+	case TermFieldExists:
+		return mustHaveField(n.Phrase)
+	case TermRegex:
+		return mustMatchRegex(n.Field, n.Regexp, n.Phrase)
+	case TermDate:
+		return mustCompareDate(n.Field, n.Op, n.Time, n.Phrase, opts, match)
+	case TermNumber:
+		return mustCompareNumber(n.Field, n.Op, n.Number, n.Phrase, opts, match)
+	case TermString:
+		return mustCompareString(n.Field, n.Op, n.Phrase, opts.Collator, match)
+	case TermPrefix:
+		return mustStartWith(n.Field, n.Phrase, opts.prefixFunc())
+	case TermCIDR:
+		return mustBeInNetwork(n.Field, n.Network, n.Phrase, match)
+	case TermRange:
+		return mustBeInRange(n.Field, n.Op, n.Number, n.UpperOp, n.Upper, n.Phrase, opts, match)
+	case TermDateRange:
+		return mustBeInDateRange(n.Field, n.Op, n.Time, n.UpperOp, n.UpperTime, n.Phrase, opts, match)
+	case TermFuzzy:
+		return mustBeNear(n.Field, n.Phrase, n.Distance, fuzzyMatch(n.Distance, opts.CaseInsensitive))
+	case TermNear, TermNearOrdered:
+		return mustBeWithin(n.Field, nearPhrases(n), n.Distance, n.Kind == TermNearOrdered, opts.CaseInsensitive)
+	case TermWildcard:
+		literal := compileTerm(&Term{Field: n.Field, Phrase: n.Phrase + string(wildcard)}, opts)
+		return mustHavePrefix(n.Field, n.Phrase, opts.wildcardFunc(), literal)
+	}
+	if opts.CaseInsensitive {
+		return mustContainFold(n.Field, n.Phrase, match)
+	}
+	return mustContain(n.Field, n.Phrase, match)
+}
+
+// forwardsKind returns true if terms of the kind use optional interfaces that wrappers pass on to the objects they
+// hold.  Other kinds are asked of the wrapper with ContainsMatch, which it passes on itself.
+func forwardsKind(kind TermKind) bool {
+	switch kind {
+	case TermFieldName, TermFieldExists, TermDate, TermNumber, TermString, TermCIDR, TermRange, TermDateRange, TermWildcard:
+		return true
+	}
+	return false
+}
+
+// compileAnd compiles the children of an AndNode.
+// Optional terms are left out unless there is nothing else to match, when at least one of them must match.
+func compileAnd(nodes []Node, opts Options, wrap termWrapper) filters {
+	var required, optional []Node
+	for _, n := range nodes {
+		if isOptional(n) {
+			optional = append(optional, n)
+		} else {
+			required = append(required, n)
+		}
+	}
+	if len(required) == 0 && len(optional) > 0 {
+		return filters{orFilter(compileAll(optional, opts, wrap)...)}
+	}
+	return compileAll(required, opts, wrap)
+}
+
+// compileAll compiles each of the nodes
+func compileAll(nodes []Node, opts Options, wrap termWrapper) filters {
+	results := make(filters, len(nodes))
+	for i, n := range nodes {
+		results[i] = compile(n, opts, wrap)
+	}
+	return results
+}
@@ -0,0 +1,152 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNodeAt(t *testing.T) {
+	// Offsets:   0         1         2
+	//            0123456789012345678901234567
+	condition := "title:merry OR (body:battle)"
+	query := QueryParser(condition)
+
+	for _, test := range []struct {
+		Pos    int
+		Phrase string
+		Type   string
+		Start  int
+		End    int
+	}{
+		{0, "merry", "*search.Term", 0, 11},
+		{10, "merry", "*search.Term", 0, 11},
+		{11, "", "*search.OrNode", 0, 28},
+		{12, "", "*search.OrNode", 0, 28},
+		{15, "", "*search.AndNode", 15, 28},
+		{16, "battle", "*search.Term", 16, 27},
+		{26, "battle", "*search.Term", 16, 27},
+		{27, "", "*search.AndNode", 15, 28},
+	} {
+		n := query.NodeAt(test.Pos)
+		if n == nil {
+			t.Errorf("Expected a node at %v\n", test.Pos)
+			continue
+		}
+		if typeName := fmt.Sprintf("%T", n); typeName != test.Type {
+			t.Errorf("Expected %v at %v, got %v\n", test.Type, test.Pos, typeName)
+		}
+		if term, ok := n.(*Term); ok && term.Phrase != test.Phrase {
+			t.Errorf("Expected phrase %v at %v, got %v\n", test.Phrase, test.Pos, term.Phrase)
+		}
+		if start, end := n.Span(); start != test.Start || end != test.End {
+			t.Errorf("Expected span %v-%v at %v, got %v-%v\n", test.Start, test.End, test.Pos, start, end)
+		}
+	}
+
+	if n := query.NodeAt(28); n != nil {
+		t.Errorf("Expected no node after the end of the query, got %v\n", fmt.Sprintf("%T", n))
+	}
+	if n := query.NodeAt(-1); n != nil {
+		t.Errorf("Expected no node before the start of the query, got %v\n", fmt.Sprintf("%T", n))
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+		Start     int
+		End       int
+	}{
+		// Quotes are part of the term
+		{`  "floating boat" whale`, 5, 2, 17},
+		{`title:"upon a very" whale`, 8, 0, 19},
+		// NOT includes the keyword
+		{"boat NOT whale", 6, 5, 14},
+		{"boat NOT (whale shark)", 6, 5, 22},
+		{"boat OR NOT whale", 9, 8, 17},
+		// Groups left open run to the end of the query
+		{"boat OR (whale shark", 8, 8, 20},
+		// Terms from lists share the span of the whole phrase
+		{"tag:(book,leaflet) whale", 7, 0, 18},
+	} {
+		n := QueryParser(test.Condition).NodeAt(test.Pos)
+		if n == nil {
+			t.Errorf("Expected a node at %v in %v\n", test.Pos, test.Condition)
+			continue
+		}
+		if start, end := n.Span(); start != test.Start || end != test.End {
+			t.Errorf("Expected span %v-%v at %v in %v, got %v %v-%v\n", test.Start, test.End, test.Pos, test.Condition, fmt.Sprintf("%T", n), start, end)
+		}
+	}
+}
+
+func TestFlatChains(t *testing.T) {
+	root := QueryParser("frog OR boil OR test").(*query).root
+	if len(root.Children) != 1 {
+		t.Fatalf("Expected a single OR, got %v nodes\n", len(root.Children))
+	}
+	or, ok := root.Children[0].(*OrNode)
+	if !ok {
+		t.Fatalf("Expected an OrNode, got %T\n", root.Children[0])
+	}
+	if len(or.Children) != 3 {
+		t.Errorf("Expected three children, got %v\n", len(or.Children))
+	}
+	if start, end := or.Span(); start != 0 || end != 20 {
+		t.Errorf("Expected span 0-20, got %v-%v\n", start, end)
+	}
+
+	for _, test := range []struct {
+		Condition string
+		Children  int
+		OrLength  int
+	}{
+		{"a1 OR b1 OR c1 OR d1", 1, 4},
+		{"a1 OR b1 OR NOT c1", 1, 3},
+		{"(a1 OR b1) OR c1", 1, 3},
+		{"a1 OR (b1 c1) OR d1", 1, 3},
+		{"z1 a1 OR b1 OR c1 y1", 3, 3},
+		{"(a1 b1) c1 (d1 (e1 f1))", 6, 0},
+	} {
+		root := QueryParser(test.Condition).(*query).root
+		if len(root.Children) != test.Children {
+			t.Errorf("Expected %v children for %v, got %v\n", test.Children, test.Condition, len(root.Children))
+			continue
+		}
+		for _, n := range root.Children {
+			if or, ok := n.(*OrNode); ok && len(or.Children) != test.OrLength {
+				t.Errorf("Expected an OR of %v for %v, got %v\n", test.OrLength, test.Condition, len(or.Children))
+			}
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root := QueryParser("boat (whale OR NOT shark) tag:book").(*query).root
+	var visited []string
+	Walk(root, func(n Node) bool {
+		if term, ok := n.(*Term); ok {
+			visited = append(visited, term.Phrase)
+		} else {
+			visited = append(visited, fmt.Sprintf("%T", n))
+		}
+		return true
+	})
+	expected := "[*search.AndNode boat *search.OrNode whale *search.NotNode shark book]"
+	if fmt.Sprint(visited) != expected {
+		t.Errorf("Expected %v, got %v\n", expected, visited)
+	}
+
+	visited = nil
+	Walk(root, func(n Node) bool {
+		if term, ok := n.(*Term); ok {
+			visited = append(visited, term.Phrase)
+		}
+		_, isNot := n.(*NotNode)
+		return !isNot
+	})
+	if fmt.Sprint(visited) != "[boat whale book]" {
+		t.Errorf("Expected the NOT to be skipped, got %v\n", visited)
+	}
+}
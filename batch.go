@@ -0,0 +1,105 @@
+package search
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+ParseAll parses each of the queries using QueryParserErr.
+
+The returned slices are aligned with queries: parsed[i] is the Query for
+queries[i], or nil if it could not be parsed, in which case errs[i] holds the
+*ParseError.  The queries are parsed in parallel.
+*/
+func ParseAll(queries []string) (parsed []Query, errs []error) {
+	parsed = make([]Query, len(queries))
+	errs = make([]error, len(queries))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(queries) {
+		workers = len(queries)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			// Each worker writes to a distinct index, so no locking is needed.
+			for i := range next {
+				parsed[i], errs[i] = QueryParserErr(queries[i])
+			}
+		}()
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return parsed, errs
+}
+
+/*
+AnyMatch returns true if the query matches any of the records.
+
+Records are searched in order, stopping at the first that matches, so the
+rest of a large set is not searched.
+*/
+func AnyMatch(q Query, records []Searchable) bool {
+	for _, record := range records {
+		if q.Search(record) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+AnyMatchParallel returns true if the query matches any of the records,
+searching them in parallel.
+
+Once a record matches no more records are handed out and the workers stop,
+though searches already under way are finished.  Each record is searched by a
+single goroutine, but the Searchable objects must not share state that is
+unsafe to use from several goroutines at once.
+*/
+func AnyMatchParallel(q Query, records []Searchable) bool {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(records) {
+		workers = len(records)
+	}
+
+	var found int32
+	done := make(chan struct{})
+	var once sync.Once
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if atomic.LoadInt32(&found) == 0 && q.Search(records[i]) {
+					atomic.StoreInt32(&found, 1)
+					once.Do(func() { close(done) })
+				}
+			}
+		}()
+	}
+send:
+	for i := range records {
+		select {
+		case next <- i:
+		case <-done:
+			break send
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	return atomic.LoadInt32(&found) == 1
+}
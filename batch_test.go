@@ -0,0 +1,115 @@
+package search
+
+import (
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseAll(t *testing.T) {
+	queries := []string{
+		"merry",
+		"(merry OR frog",
+		"title:merry NOT body:frog",
+		"merry) battle",
+		"frog",
+	}
+	valid := []bool{true, false, true, false, true}
+	matches := []bool{true, false, true, false, false}
+
+	parsed, errs := ParseAll(queries)
+	if len(parsed) != len(queries) || len(errs) != len(queries) {
+		t.Fatalf("Expected %v results, got %v queries and %v errors\n", len(queries), len(parsed), len(errs))
+	}
+	for i, query := range queries {
+		if valid[i] {
+			if errs[i] != nil {
+				t.Errorf("Unexpected error for %v: %v\n", query, errs[i])
+				continue
+			}
+			if parsed[i].Search(testFieldMaterial) != matches[i] {
+				t.Errorf("Expected %v to have match %v\n", query, matches[i])
+			}
+		} else {
+			if parsed[i] != nil {
+				t.Errorf("Expected no query for invalid %v\n", query)
+			}
+			if _, ok := errs[i].(*ParseError); !ok {
+				t.Errorf("Expected a *ParseError for %v, got %v\n", query, errs[i])
+			}
+		}
+	}
+
+	parsed, errs = ParseAll(nil)
+	if len(parsed) != 0 || len(errs) != 0 {
+		t.Errorf("Expected no results for no queries\n")
+	}
+}
+
+// testCountingRecord counts the searches made of it
+type testCountingRecord struct {
+	text     string
+	searched *int32
+}
+
+func (r testCountingRecord) Contains(field, phrase string) (present bool) {
+	atomic.AddInt32(r.searched, 1)
+	return strings.Contains(r.text, phrase)
+}
+
+func TestAnyMatch(t *testing.T) {
+	var searched int32
+	records := make([]Searchable, 1000)
+	for i := range records {
+		records[i] = testCountingRecord{text: "a beetle in a bottle", searched: &searched}
+	}
+	records[2] = testCountingRecord{text: "a merry time", searched: &searched}
+
+	if !AnyMatch(QueryParser("merry"), records) {
+		t.Errorf("Expected a match for merry\n")
+	}
+	if searched != 3 {
+		t.Errorf("Expected the search to stop after 3 records, got %v\n", searched)
+	}
+	searched = 0
+	if AnyMatch(QueryParser("whale"), records) {
+		t.Errorf("Expected no match for whale\n")
+	}
+	if searched != int32(len(records)) {
+		t.Errorf("Expected every record to be searched, got %v\n", searched)
+	}
+	if AnyMatch(QueryParser("merry"), nil) {
+		t.Errorf("Expected no match for no records\n")
+	}
+}
+
+func TestAnyMatchParallel(t *testing.T) {
+	var searched int32
+	records := make([]Searchable, 100000)
+	for i := range records {
+		records[i] = testCountingRecord{text: "a merry time", searched: &searched}
+	}
+	if !AnyMatchParallel(QueryParser("merry"), records) {
+		t.Errorf("Expected a match for merry\n")
+	}
+	// Every record matches, so at most one search per worker runs after the first match
+	if searched > int32(2*runtime.GOMAXPROCS(0)) {
+		t.Errorf("Expected the search to stop early, searched %v records\n", searched)
+	}
+
+	searched = 0
+	if AnyMatchParallel(QueryParser("whale"), records) {
+		t.Errorf("Expected no match for whale\n")
+	}
+	if searched != int32(len(records)) {
+		t.Errorf("Expected every record to be searched, got %v\n", searched)
+	}
+	records[len(records)-1] = testCountingRecord{text: "a whale", searched: &searched}
+	if !AnyMatchParallel(QueryParser("whale"), records) {
+		t.Errorf("Expected a match for whale in the last record\n")
+	}
+	if AnyMatchParallel(QueryParser("merry"), nil) {
+		t.Errorf("Expected no match for no records\n")
+	}
+}
@@ -0,0 +1,27 @@
+package search
+
+import (
+	"errors"
+)
+
+/*
+ErrBudgetExceeded is returned by Query.SearchBudget when the terms the query
+searches for cost more than the budget allows.
+*/
+var ErrBudgetExceeded = errors.New("search: query exceeded its budget of operations")
+
+// budget counts down the cost of the terms a search may still carry out
+type budget struct {
+	remaining int
+	exceeded  bool
+}
+
+// spend counts the cost of searching for a term against the budget, returning false once the budget is exceeded
+func (b *budget) spend(cost int) bool {
+	if b.remaining < cost {
+		b.exceeded = true
+		return false
+	}
+	b.remaining -= cost
+	return true
+}
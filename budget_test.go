@@ -0,0 +1,57 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestSearchBudget(t *testing.T) {
+	var calls int
+	record := SearchableFunc(func(field, phrase string) bool {
+		calls++
+		return phrase != "shark"
+	})
+	for _, test := range []struct {
+		Condition string
+		MaxOps    int
+		Match     bool
+		Exceeded  bool
+		Calls     int
+	}{
+		{"boat whale", 2, true, false, 2},
+		{"boat whale", 1, false, true, 1},
+		{"shark boat whale", 1, false, false, 1},
+		{"boat whale shark", 2, false, true, 2},
+		{"(boat OR whale) NOT shark", 2, true, false, 2},
+		{"(shark OR whale) NOT boat", 2, false, true, 2},
+		{"(shark OR whale) NOT boat", 3, false, false, 3},
+		// A regular expression costs more than a phrase, so uses up more of the budget
+		{"boat title:/^wh/", 2, false, true, 1},
+		{"boat title:/^wh/", 11, true, false, 2},
+	} {
+		calls = 0
+		match, err := QueryParser(test.Condition).SearchBudget(record, test.MaxOps)
+		if match != test.Match {
+			t.Errorf("Expected %v for %v with budget %v\n", test.Match, test.Condition, test.MaxOps)
+		}
+		if (err == ErrBudgetExceeded) != test.Exceeded {
+			t.Errorf("Expected exceeded %v for %v with budget %v, got %v\n", test.Exceeded, test.Condition, test.MaxOps, err)
+		}
+		if calls != test.Calls {
+			t.Errorf("Expected %v calls for %v with budget %v, got %v\n", test.Calls, test.Condition, test.MaxOps, calls)
+		}
+	}
+}
+
+func TestSearchBudgetCompilesOnce(t *testing.T) {
+	q := QueryParser("boat whale shark").(*query)
+	record := SearchableString("boat whale")
+	for i := 0; i < 2; i++ {
+		if match, err := q.SearchBudget(record, 2); match || err != ErrBudgetExceeded {
+			t.Errorf("Expected the budget to be exceeded, got %v, %v\n", match, err)
+		}
+	}
+	first := q.calls
+	if _, err := q.SearchBudget(record, 1); err != ErrBudgetExceeded || len(first) == 0 || &q.calls[0] != &first[0] {
+		t.Errorf("Expected the filters that count the budget to be compiled once\n")
+	}
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"strings"
+)
+
+/*
+Builder builds a Query from code rather than text, created by NewBuilder.
+
+Terms are held as they are given, so phrases and field names never need
+quoting or escaping, even if they contain spaces, colons or keywords such as
+OR.  Terms are ANDed together, as they are in a parsed query:
+
+	q := NewBuilder().
+		Must("boat").
+		MustField("tag", "book").
+		Or(NewBuilder().Must("whale")).
+		Not("shark").
+		Build()
+
+builds the same Query as `boat tag:book OR whale NOT shark`.
+*/
+type Builder struct {
+	nodes []Node
+}
+
+/*
+NewBuilder returns an empty Builder, which matches everything.
+*/
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+/*
+Must adds a phrase that must be present in any field.  Empty phrases are
+ignored.
+*/
+func (b *Builder) Must(phrase string) *Builder {
+	return b.MustField("", phrase)
+}
+
+/*
+MustField adds a phrase that must be present in the field.  Empty phrases are
+ignored.
+*/
+func (b *Builder) MustField(field, phrase string) *Builder {
+	if phrase != "" {
+		b.nodes = append(b.nodes, &Term{Field: field, Phrase: phrase})
+	}
+	return b
+}
+
+/*
+Not adds a phrase that must not be present in any field.  Empty phrases are
+ignored.
+*/
+func (b *Builder) Not(phrase string) *Builder {
+	return b.NotField("", phrase)
+}
+
+/*
+NotField adds a phrase that must not be present in the field.  Empty phrases
+are ignored.
+*/
+func (b *Builder) NotField(field, phrase string) *Builder {
+	if phrase != "" {
+		b.nodes = append(b.nodes, &NotNode{Child: &Term{Field: field, Phrase: phrase}})
+	}
+	return b
+}
+
+/*
+Or makes the last phrase or group added an alternative to everything in
+other, as the OR keyword does.  If other holds several terms they must all
+match, as if they were in brackets, so
+
+	NewBuilder().Must("boat").Or(NewBuilder().Must("whale").Must("shark"))
+
+is the same as `boat OR (whale shark)`.  If nothing has been added yet the
+terms of other are added as if Or was not used.
+*/
+func (b *Builder) Or(other *Builder) *Builder {
+	// Copied so that later changes to other do not affect b
+	nodes := copyNodes(other.nodes, 0, 0)
+	if len(b.nodes) == 0 {
+		b.nodes = append(b.nodes, nodes...)
+		return b
+	}
+	var alternative Node = &AndNode{Children: nodes}
+	if len(nodes) == 1 {
+		alternative = nodes[0]
+	}
+	b.nodes[len(b.nodes)-1] = orWith(b.nodes[len(b.nodes)-1], alternative)
+	return b
+}
+
+/*
+Build returns the Query for the terms added so far.  The query has no source
+text, so the spans of its nodes are all zero.
+*/
+func (b *Builder) Build() Query {
+	return newQuery(b.root(), Options{})
+}
+
+/*
+String returns the query text equivalent to the Builder, which parses back to
+the same Query.  Field names containing spaces or colons are quoted, as in
+`"odd: field":"x y"`.
+*/
+func (b *Builder) String() string {
+	var text strings.Builder
+	formatNode(&text, b.root(), true, defaultKeywords, NotSingleTerm)
+	return text.String()
+}
+
+// root returns a copy of the query tree built so far, so that later changes to the Builder do not affect it
+func (b *Builder) root() *AndNode {
+	return &AndNode{Children: copyNodes(b.nodes, 0, 0)}
+}
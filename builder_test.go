@@ -0,0 +1,64 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	for _, test := range []struct {
+		Builder *Builder
+		Query   string
+	}{
+		{NewBuilder(), ""},
+		{NewBuilder().Must("boat").MustField("tag", "book").Or(NewBuilder().Must("whale")).Not("shark"), "boat tag:book OR whale NOT shark"},
+		{NewBuilder().Must("merry time").NotField("title", "frog"), `"merry time" NOT title:frog`},
+		{NewBuilder().Must("boat").Or(NewBuilder().Must("whale").Must("shark")), "boat OR (whale shark)"},
+		{NewBuilder().Must("boat").Or(NewBuilder().Must("whale")).Or(NewBuilder().Must("shark")), "boat OR whale OR shark"},
+		{NewBuilder().Or(NewBuilder().Must("whale").Must("shark")), "whale shark"},
+		{NewBuilder().Must("boat").Or(NewBuilder()), "boat OR ()"},
+		{NewBuilder().Must("").Not(""), ""},
+	} {
+		built := test.Builder.Build()
+		_, builtKey := canonical(built.(*query).root)
+		_, parsedKey := canonical(QueryParser(test.Query).(*query).root)
+		if builtKey != parsedKey {
+			t.Errorf("Expected the same query as %v, got %v\n", test.Query, builtKey)
+		}
+		if built.Search(testFieldMaterial) != QueryParser(test.Query).Search(testFieldMaterial) {
+			t.Errorf("Expected the same result as %v\n", test.Query)
+		}
+	}
+
+	b := NewBuilder().MustField("title", "merry").Or(NewBuilder().Must("OR"))
+	if text := b.String(); text != `title:merry OR "OR"` {
+		t.Errorf("Expected the text of the builder, got %v\n", text)
+	}
+
+	// Field names and phrases are held as given, without going through the query grammar
+	record := SearchableTypedRow(map[string]interface{}{"odd: field": "a (value) OR NOT"})
+	if !NewBuilder().MustField("odd: field", "(value) OR NOT").Build().Search(record) {
+		t.Errorf("Expected a field name with a colon and space to match\n")
+	}
+
+	// Such field names are quoted in the text of the builder, which parses back to the same query
+	odd := NewBuilder().MustField("odd: field", "x y").NotField("odd: field", "(value) OR NOT")
+	if text := odd.String(); text != `"odd: field":"x y" NOT "odd: field":"\(value) OR NOT"` {
+		t.Errorf("Expected the field name to be quoted, got %v\n", text)
+	}
+	_, builtKey := canonical(odd.Build().(*query).root)
+	_, parsedKey := canonical(QueryParser(odd.String()).(*query).root)
+	if builtKey != parsedKey {
+		t.Errorf("Expected %v to parse back to the built query %v, got %v\n", odd, builtKey, parsedKey)
+	}
+	record = SearchableTypedRow(map[string]interface{}{"odd: field": "x y"})
+	if !QueryParser(odd.String()).Search(record) {
+		t.Errorf("Expected the text of the builder to match as the built query does\n")
+	}
+
+	// Queries already built are not changed by adding to the builder
+	q := b.Build()
+	b.Must("frog")
+	if !q.Search(testFieldMaterial) {
+		t.Errorf("Expected the built query not to change\n")
+	}
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+searchCall carries the state of a single call, such as one of SearchContext,
+SearchBudget or SearchWithFields, to the filters returned by
+query.callFilters.
+
+Those filters are compiled once and shared by every call, so that searching
+each of a large number of records does not compile the query again.  The
+filter of each term checks the state of the call before searching the
+Searchable that the call holds.
+*/
+type searchCall struct {
+	Searchable
+	state *callState
+}
+
+// callState is shared by the searchCalls of a single call, including those made for each element of a document
+// narrowed by an elementScoper
+type callState struct {
+	// cancel is set if the call stops once a context is done
+	cancel *cancellation
+	// budget is set if the call stops once the terms searched for cost more than it allows
+	budget *budget
+	// overrides holds the values searched for the fields given to SearchWithFields in place of the record
+	overrides map[string]string
+}
+
+// with returns a searchCall for s that shares the state of c
+func (c *searchCall) with(s Searchable) *searchCall {
+	return &searchCall{Searchable: s, state: c.state}
+}
+
+// callFilters returns the filters of the query compiled to check the state of the searchCall they are given.
+// They are compiled the first time they are needed, as most queries are only ever used by Search.
+func (q *query) callFilters() filters {
+	q.callOnce.Do(func() {
+		q.calls = compileQuery(q.root, q.opts, q.callTerm)
+	})
+	return q.calls
+}
+
+// callTerm returns a filter that, given a searchCall, uses the term's filter f to search the Searchable the call
+// holds, or the value the call gives for the term's field, unless the call has been stopped.  Other Searchables are
+// searched by f as they are.
+func (q *query) callTerm(term *Term, f filter) filter {
+	cost := termKindCost(term)
+	return func(s Searchable) bool {
+		call, isCall := s.(*searchCall)
+		if !isCall {
+			return f(s)
+		}
+		if cancel := call.state.cancel; cancel != nil && cancel.done() {
+			return false
+		}
+		if budget := call.state.budget; budget != nil && !budget.spend(cost) {
+			return false
+		}
+		if value, overridden := call.state.overrides[term.Field]; overridden && term.Field != "" {
+			return f(&fieldOverride{field: term.Field, value: value, opts: q.opts})
+		}
+		return f(call.Searchable)
+	}
+}
+
+/*
+fieldOverride is searched in place of the record by the terms of
+SearchWithFields whose field is given a value.
+
+The value is searched as the text of the field, using the options of the
+query, and compared as a number or date for terms such as `tenant:>5`.
+*/
+type fieldOverride struct {
+	field, value string
+	opts         Options
+}
+
+func (o *fieldOverride) Contains(field, phrase string) (present bool) {
+	return o.ContainsMatch(field, phrase, strings.Contains)
+}
+
+func (o *fieldOverride) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	return (field == "" || field == o.field) && match(o.value, phrase)
+}
+
+func (o *fieldOverride) HasField(field string) (present bool) {
+	return field == o.field
+}
+
+func (o *fieldOverride) FieldValue(field string) (value string, present bool) {
+	if field != o.field {
+		return "", false
+	}
+	return o.value, true
+}
+
+func (o *fieldOverride) Compare(field string, op Op, value float64) (match bool) {
+	return field == o.field && o.opts.compareNumberText(o.value, op, value)
+}
+
+func (o *fieldOverride) CompareDate(field string, op Op, t time.Time) (match bool) {
+	return field == o.field && o.opts.compareDateText(o.value, op, t)
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Canonicalize returns a copy of q with the children of every AND and OR sorted
+into a stable order, by field and then phrase, so that equivalent queries such
+as `whale boat` and `boat OR whale` in either order have identical trees.  This
+allows parsed queries to be compared, cached and de-duplicated.
+
+What a NOT applies to is kept, and the canonical query searches exactly as q
+does.  As the canonical query no longer follows the text it was parsed from,
+the spans of its nodes are all zero.  Queries not returned by this package are
+returned unchanged.
+*/
+func Canonicalize(q Query) Query {
+	parsed, ok := q.(*query)
+	if !ok {
+		return q
+	}
+	root, _ := canonical(parsed.root)
+	return buildQuery(root.(*AndNode), parsed.opts, parsed.diagnostics)
+}
+
+// canonical returns a sorted copy of n without spans, together with a key that sorts and identifies it
+func canonical(n Node) (Node, string) {
+	switch n := n.(type) {
+	case *Term:
+		term := *n
+		term.Start, term.End = 0, 0
+		return &term, fmt.Sprintf("%q:%q %v %v %v %v %v %v %v %v %q %v %v %v", term.Field, term.Phrase, term.Kind, term.Op, term.Time.UnixNano(), term.Number,
+			term.UpperOp, term.UpperTime.UnixNano(), term.Upper, term.Distance, nearPhrases(term.Near), term.Network, term.Optional, term.Boost)
+	case *AndNode:
+		children, keys := canonicalChildren(n.Children)
+		return &AndNode{Children: children}, "(" + strings.Join(keys, " ") + ")"
+	case *OrNode:
+		children, keys := canonicalChildren(n.Children)
+		return &OrNode{Children: children}, "(" + strings.Join(keys, " OR ") + ")"
+	case *NotNode:
+		child, key := canonical(n.Child)
+		return &NotNode{Child: child}, "NOT " + key
+	}
+	panic("search: unknown node type")
+}
+
+// canonicalChildren returns the canonical form of each node, sorted by their keys
+func canonicalChildren(nodes []Node) ([]Node, []string) {
+	children := make([]Node, len(nodes))
+	keys := make([]string, len(nodes))
+	for i, n := range nodes {
+		children[i], keys[i] = canonical(n)
+	}
+	sort.Sort(byKey{children, keys})
+	return children, keys
+}
+
+// byKey sorts nodes by their canonical keys
+type byKey struct {
+	nodes []Node
+	keys  []string
+}
+
+func (b byKey) Len() int           { return len(b.nodes) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.nodes[i], b.nodes[j] = b.nodes[j], b.nodes[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
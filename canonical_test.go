@@ -0,0 +1,44 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	for _, test := range []struct {
+		First  string
+		Second string
+		Same   bool
+	}{
+		{"whale boat", "boat whale", true},
+		{"boat OR whale", "whale OR boat", true},
+		{"tag:book (shark OR whale) boat", "boat (whale OR shark) tag:book", true},
+		{"NOT (whale boat) shark", "shark NOT (boat whale)", true},
+		{"title:boat body:whale", "body:whale title:boat", true},
+		{"boat NOT whale", "whale NOT boat", false},
+		{"title:boat", "body:boat", false},
+		{"boat OR whale", "boat whale", false},
+		{"boat^2 boat", "boat boat^2", true},
+		{"boat^2", "boat", false},
+	} {
+		first := Canonicalize(QueryParser(test.First)).(*query)
+		second := Canonicalize(QueryParser(test.Second)).(*query)
+		if reflect.DeepEqual(first.root, second.root) != test.Same {
+			t.Errorf("Expected same %v for %v and %v\n", test.Same, test.First, test.Second)
+		}
+	}
+}
+
+func TestCanonicalizeSearch(t *testing.T) {
+	original := QueryParser("title:merry NOT (whale OR shark) battle")
+	canonical := Canonicalize(original)
+	for _, record := range []Searchable{testFieldMaterial, testFieldMaterialWithSpecialChars, SearchableString("merry battle whale")} {
+		if original.Search(record) != canonical.Search(record) {
+			t.Errorf("Expected the canonical query to search as the original for %v\n", record)
+		}
+	}
+	if start, end := original.(*query).root.Span(); start != 0 || end == 0 {
+		t.Errorf("Expected the original query to be unchanged, got span %v to %v\n", start, end)
+	}
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+/*
+IPSearchable is an optional interface for Searchable objects holding IP
+addresses, used for terms such as `src:192.168.0.0/16` that match addresses
+within a network written in CIDR notation.
+
+Searchable objects that do not implement IPSearchable but do implement
+FieldValuer have the value of the field parsed as an IP address.  Others are
+asked whether the field contains the text of the network.
+*/
+type IPSearchable interface {
+	Searchable
+	/*
+		IPInCIDR returns true if the field holds an IP address within ipnet.
+	*/
+	IPInCIDR(field string, ipnet *net.IPNet) (match bool)
+}
+
+// looksLikeCIDR returns true if the phrase is written like a network in CIDR notation, such as 10.0.0.0/8 or fe80::/10
+func looksLikeCIDR(phrase string) bool {
+	slash := strings.IndexByte(phrase, '/')
+	if slash <= 0 || !strings.ContainsAny(phrase[:slash], ".:") {
+		return false
+	}
+	return strings.Trim(phrase, "0123456789abcdefABCDEF.:/") == ""
+}
+
+/*
+parseNetworks turns the terms beneath n whose phrase is a network, such as
+src:192.168.0.0/16, into TermCIDR terms.
+
+A ParseError is added to diagnostics for each phrase that looks like a network
+but is not valid, such as 10.0.0.0/33, which is searched for as text.
+*/
+func parseNetworks(n Node, diagnostics []*ParseError) []*ParseError {
+	if term, ok := n.(*Term); ok {
+		if term.Kind != TermContains || term.Field == "" || !looksLikeCIDR(term.Phrase) {
+			return diagnostics
+		}
+		_, network, err := net.ParseCIDR(term.Phrase)
+		if err != nil {
+			return append(diagnostics, &ParseError{
+				Pos:     term.Start,
+				Message: fmt.Sprintf("invalid network %q, searching for it as text", term.Phrase),
+			})
+		}
+		term.Kind, term.Network = TermCIDR, network
+		return diagnostics
+	}
+	for _, child := range children(n) {
+		diagnostics = parseNetworks(child, diagnostics)
+	}
+	return diagnostics
+}
+
+// mustBeInNetwork returns true if the Searchable's field holds an IP address within the network
+func mustBeInNetwork(field string, network *net.IPNet, phrase string, match MatchFunc) filter {
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case IPSearchable:
+			return s.IPInCIDR(field, network)
+		case FieldValuer:
+			value, present := s.FieldValue(field)
+			ip := net.ParseIP(strings.TrimSpace(value))
+			return present && ip != nil && network.Contains(ip)
+		}
+		return contains(s, field, phrase, match)
+	}
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+// testLogEntry holds the source address of a network log entry
+type testLogEntry struct {
+	Src net.IP
+}
+
+func (e testLogEntry) Contains(field, phrase string) (present bool) {
+	return strings.Contains(e.Src.String(), phrase)
+}
+
+func (e testLogEntry) IPInCIDR(field string, ipnet *net.IPNet) (match bool) {
+	return field == "src" && ipnet.Contains(e.Src)
+}
+
+func TestCIDR(t *testing.T) {
+	inside := testLogEntry{net.ParseIP("192.168.4.20")}
+	outside := testLogEntry{net.ParseIP("10.1.2.3")}
+	row := SearchableTypedRow(map[string]interface{}{"src": "192.168.4.20"})
+	for _, test := range []struct {
+		Condition string
+		Inside    bool
+		Outside   bool
+	}{
+		{"src:192.168.0.0/16", true, false},
+		{"src:10.0.0.0/8", false, true},
+		{"NOT src:192.168.0.0/16", false, true},
+		{"src:(10.0.0.0/8,192.168.4.0/24)", true, true},
+		{"src:192.168.4.20/32", true, false},
+		{"dst:192.168.0.0/16", false, false},
+		{"src:::1/128", false, false},
+	} {
+		q, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.Search(inside); result != test.Inside {
+			t.Errorf("Expected %v for %v against %v, got %v\n", test.Inside, test.Condition, inside.Src, result)
+		}
+		if result := q.Search(outside); result != test.Outside {
+			t.Errorf("Expected %v for %v against %v, got %v\n", test.Outside, test.Condition, outside.Src, result)
+		}
+		// Searchables with FieldValue have the value parsed as an address
+		if result := q.Search(row); result != test.Inside {
+			t.Errorf("Expected %v for %v against a row, got %v\n", test.Inside, test.Condition, result)
+		}
+	}
+
+	q := QueryParser("merry src:10.0.0.0/33")
+	if diagnostics := q.Diagnostics(); len(diagnostics) != 1 || diagnostics[0].Pos != 6 {
+		t.Errorf("Expected a diagnostic at 6 for an invalid network, got %v\n", diagnostics)
+	}
+	if !q.MatchString("merry src:10.0.0.0/33") {
+		t.Errorf("Expected an invalid network to be searched for as text\n")
+	}
+	if len(QueryParser("path:a/b price:1/2 10.0.0.0/8").Diagnostics()) != 0 {
+		t.Errorf("Expected phrases that are not networks not to be reported\n")
+	}
+	if !QueryParser("src:10.0.0.0/8").MatchString("from 10.0.0.0/8") {
+		t.Errorf("Expected Searchables without addresses to be searched for the text\n")
+	}
+}
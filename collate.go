@@ -0,0 +1,95 @@
+package search
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Collator compares strings in the order of a language, used by
+Options.Collator.  It is satisfied by *collate.Collator from
+golang.org/x/text/collate, e.g. collate.New(language.Swedish), which should
+be given to Options.NewCollator as it is not safe for concurrent use.
+
+CompareString returns -1, 0 or 1 as a sorts before, the same as or after b.
+*/
+type Collator interface {
+	CompareString(a, b string) int
+}
+
+// pooledCollator lends each comparison a Collator made by Options.NewCollator, so that searches in several goroutines
+// never share one, as collators such as *collate.Collator keep buffers between comparisons
+type pooledCollator struct {
+	collators sync.Pool
+}
+
+// newPooledCollator returns a pooledCollator that makes its collators with newCollator
+func newPooledCollator(newCollator func() Collator) *pooledCollator {
+	pc := &pooledCollator{}
+	pc.collators.New = func() interface{} {
+		return newCollator()
+	}
+	return pc
+}
+
+func (pc *pooledCollator) CompareString(a, b string) int {
+	collator := pc.collators.Get().(Collator)
+	defer pc.collators.Put(collator)
+	return collator.CompareString(a, b)
+}
+
+/*
+FieldValuer is an optional interface for Searchable objects that can return
+the text of a field.
+
+Comparisons against words, such as `name:>m`, compare the value of the field
+with the word using Options.Collator, or byte order if it is not set.
+Searchable objects that do not implement FieldValuer are asked whether the
+field contains the text of the comparison, such as `>m`.
+*/
+type FieldValuer interface {
+	Searchable
+	/*
+		FieldValue returns the text of the field and whether the object has it.
+	*/
+	FieldValue(field string) (value string, present bool)
+}
+
+// isWord returns true if the value starts with a letter, so may be compared as a string
+func isWord(value string) bool {
+	char, _ := utf8.DecodeRuneInString(value)
+	return unicode.IsLetter(char)
+}
+
+// compareOp returns true if the result of a comparison, -1, 0 or 1, satisfies op
+func compareOp(compared int, op Op) bool {
+	switch op {
+	case OpLess:
+		return compared < 0
+	case OpLessEqual:
+		return compared <= 0
+	case OpGreater:
+		return compared > 0
+	case OpGreaterEqual:
+		return compared >= 0
+	}
+	return false
+}
+
+// mustCompareString returns true if the Searchable's text in the field compares to value using op
+func mustCompareString(field string, op Op, value string, collator Collator, match MatchFunc) filter {
+	compare := strings.Compare
+	if collator != nil {
+		compare = collator.CompareString
+	}
+	literal := op.String() + value
+	return func(s Searchable) bool {
+		if valuer, ok := s.(FieldValuer); ok {
+			text, present := valuer.FieldValue(field)
+			return present && compareOp(compare(text, value), op)
+		}
+		return contains(s, field, literal, match)
+	}
+}
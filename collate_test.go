@@ -0,0 +1,60 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+// testSwedish orders the Swedish letters å, ä and ö after z, as collate.New(language.Swedish) does
+type testSwedish struct{}
+
+func (testSwedish) CompareString(a, b string) int {
+	key := strings.NewReplacer("å", "z\x01", "ä", "z\x02", "ö", "z\x03")
+	return strings.Compare(key.Replace(a), key.Replace(b))
+}
+
+func TestCollator(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Name      string
+		Collator  Collator
+		Match     bool
+	}{
+		{"name:>m", "Olle", nil, false},
+		{"name:>m", "olle", nil, true},
+		{"name:<=olle", "olle", nil, true},
+		{"name:<ä", "åsa", nil, false},
+		{"name:<ä", "åsa", testSwedish{}, true},
+		{"name:>z", "ärlig", testSwedish{}, true},
+		{"name:>z", "zebra", testSwedish{}, true},
+		{"name:<z", "ärlig", testSwedish{}, false},
+		{"missing:>a", "olle", nil, false},
+	} {
+		query, err := QueryParserOptions(test.Condition, Options{Collator: test.Collator})
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		record := SearchableTypedRow(map[string]interface{}{"name": test.Name})
+		if result := query.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v against %v, got %v\n", test.Match, test.Condition, test.Name, result)
+		}
+	}
+
+	// Each comparison borrows a collator made by NewCollator
+	made := 0
+	query, err := QueryParserOptions("name:>z", Options{NewCollator: func() Collator { made++; return testSwedish{} }})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(SearchableTypedRow(map[string]interface{}{"name": "ärlig"})) || made == 0 {
+		t.Errorf("Expected ärlig to sort after z using a collator from NewCollator\n")
+	}
+	if _, err := QueryParserOptions("name:>z", Options{Collator: testSwedish{}, NewCollator: func() Collator { return testSwedish{} }}); err == nil {
+		t.Errorf("Expected an error setting both Collator and NewCollator\n")
+	}
+
+	// Searchables that can't return the value of a field are asked for the text of the comparison
+	if !QueryParser("name:>m").Search(SearchableString("sorted name:>m")) {
+		t.Errorf("Expected the comparison text to be searched for\n")
+	}
+}
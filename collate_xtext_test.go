@@ -0,0 +1,55 @@
+//go:build xtext
+// +build xtext
+
+package search
+
+import (
+	"sync"
+	"testing"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+func TestCollatorSwedish(t *testing.T) {
+	swedish := func() Collator { return collate.New(language.Swedish) }
+	for _, test := range []struct {
+		Condition string
+		Name      string
+		Match     bool
+	}{
+		{"name:<ä", "åsa", true},
+		{"name:>z", "ärlig", true},
+		{"name:<z", "ärlig", false},
+		{"name:>ä", "öberg", true},
+		{"name:>m", "olle", true},
+	} {
+		query, err := QueryParserOptions(test.Condition, Options{NewCollator: swedish})
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		record := SearchableTypedRow(map[string]interface{}{"name": test.Name})
+		if result := query.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v against %v, got %v\n", test.Match, test.Condition, test.Name, result)
+		}
+	}
+
+	// Run with go test -race -tags xtext to check that searches do not share a collator
+	query, err := QueryParserOptions("name:>z", Options{NewCollator: swedish})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if !query.Search(SearchableTypedRow(map[string]interface{}{"name": "ärlig"})) {
+					t.Errorf("Expected ärlig to sort after z\n")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,104 @@
+package search
+
+/*
+IntersectSearchable combines two Searchable objects so that a phrase is only
+present if it is present in both a and b.
+
+This suits records assembled from a primary and a secondary source where every
+term must be found in both.  Matching options are passed on to a and b if they
+implement MatchSearchable, and terms that use the other optional interfaces,
+such as `price:>10` for Comparable, must be true for both.
+*/
+func IntersectSearchable(a, b Searchable) Searchable {
+	return intersectSearchable{a: a, b: b}
+}
+
+// intersectSearchable is the Searchable returned by IntersectSearchable
+type intersectSearchable struct {
+	a, b Searchable
+}
+
+func (is intersectSearchable) Contains(field, phrase string) (present bool) {
+	return is.a.Contains(field, phrase) && is.b.Contains(field, phrase)
+}
+
+func (is intersectSearchable) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	return contains(is.a, field, phrase, match) && contains(is.b, field, phrase, match)
+}
+
+func (is intersectSearchable) forward(field string, f filter) (match bool) {
+	return f(is.a) && f(is.b)
+}
+
+func (is intersectSearchable) HasField(field string) (present bool) {
+	return hasField(is.a, field) && hasField(is.b, field)
+}
+
+/*
+TransformSearchable applies transform to each query phrase before asking s
+whether it is present.
+
+This allows normalisation such as stemming to be applied to the query, so that
+a search for "running" can ask for "run".  Use TransformStringSlice to apply
+the same normalisation to the text of the record.  Terms that are not words,
+such as `price:>10` or `boa*`, are passed on to s unchanged so that it can use
+Comparable and the other optional interfaces.
+*/
+func TransformSearchable(s Searchable, transform func(phrase string) string) Searchable {
+	return transformSearchable{s: s, transform: transform}
+}
+
+// transformSearchable is the Searchable returned by TransformSearchable
+type transformSearchable struct {
+	s         Searchable
+	transform func(phrase string) string
+}
+
+func (ts transformSearchable) Contains(field, phrase string) (present bool) {
+	return ts.s.Contains(field, ts.transform(phrase))
+}
+
+func (ts transformSearchable) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	return contains(ts.s, field, ts.transform(phrase), match)
+}
+
+func (ts transformSearchable) forward(field string, f filter) (match bool) {
+	return f(ts.s)
+}
+
+func (ts transformSearchable) HasField(field string) (present bool) {
+	return hasField(ts.s, field)
+}
+
+/*
+TransformStringSlice makes a slice of strings Searchable after applying
+transform to each of them.
+
+The strings are transformed once, when TransformStringSlice is called, rather
+than on every search.
+*/
+func TransformStringSlice(record []string, transform func(text string) string) Searchable {
+	transformed := make([]string, len(record))
+	for i, str := range record {
+		transformed[i] = transform(str)
+	}
+	return SearchableMatchStringSlice(transformed)
+}
+
+// transformTerms applies the transform for the field of each term beneath n to its phrase
+func transformTerms(n Node, transforms map[string]func(phrase string) string) {
+	if term, ok := n.(*Term); ok {
+		transform, found := transforms[term.Field]
+		if !found || term.Kind != TermContains && term.Kind != TermNear && term.Kind != TermNearOrdered {
+			return
+		}
+		// The phrases of a NEAR term are each transformed
+		for ; term != nil; term = term.Near {
+			term.Phrase = transform(term.Phrase)
+		}
+		return
+	}
+	for _, child := range children(n) {
+		transformTerms(child, transforms)
+	}
+}
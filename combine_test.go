@@ -0,0 +1,157 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntersectSearchable(t *testing.T) {
+	primary := SearchableString("A whale of a boat trip")
+	secondary := SearchableString("Boat trip booking confirmed")
+	both := IntersectSearchable(primary, secondary)
+
+	if !QueryParser("trip").Search(both) {
+		t.Errorf("Expected a term present in both sources to match\n")
+	}
+	if QueryParser("whale").Search(both) {
+		t.Errorf("Expected a term present only in the first source not to match\n")
+	}
+	if QueryParser("booking").Search(both) {
+		t.Errorf("Expected a term present only in the second source not to match\n")
+	}
+	if !QueryParser("trip NOT whale").Search(both) {
+		t.Errorf("Expected NOT of a term present in only one source to match\n")
+	}
+
+	query, err := QueryParserOptions("👩", Options{GraphemeAware: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if query.Search(IntersectSearchable(SearchableMatchString("👩"), SearchableMatchString("👨‍👩‍👧"))) {
+		t.Errorf("Expected matching options to be passed to both sources\n")
+	}
+}
+
+func TestIntersectSearchableComparisons(t *testing.T) {
+	primary := SearchableTypedRow(map[string]interface{}{"price": 12, "stock": 4})
+	secondary := SearchableTypedRow(map[string]interface{}{"price": 15})
+	both := IntersectSearchable(primary, secondary)
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"price:>10", true},
+		{"price:>13", false},
+		{"price:[10 TO 20]", true},
+		{"_exists_:price", true},
+		{"_exists_:stock", false},
+		{"stock:>1", false},
+		{"NOT price:>13", true},
+	} {
+		if result := QueryParser(test.Condition).Search(both); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
+
+// testStem is a crude stemmer for testing, reducing "running" to "run"
+func testStem(word string) string {
+	word = strings.ToLower(word)
+	if strings.HasSuffix(word, "ning") {
+		return strings.TrimSuffix(word, "ning")
+	}
+	return strings.TrimSuffix(word, "ing")
+}
+
+func TestTransformSearchable(t *testing.T) {
+	record := SearchableString("I run every day")
+	if QueryParser("running").Search(record) {
+		t.Errorf("Expected running not to match without a transform\n")
+	}
+	if !QueryParser("running").Search(TransformSearchable(record, testStem)) {
+		t.Errorf("Expected a stemmed running to match run\n")
+	}
+	if QueryParser("walking").Search(TransformSearchable(record, testStem)) {
+		t.Errorf("Expected a stemmed walking not to match\n")
+	}
+	if !QueryParser("NOT walking").Search(TransformSearchable(record, testStem)) {
+		t.Errorf("Expected NOT of a stemmed walking to match\n")
+	}
+
+	query, err := QueryParserOptions("👩", Options{GraphemeAware: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if query.Search(TransformSearchable(SearchableMatchString("👨‍👩‍👧"), strings.TrimSpace)) {
+		t.Errorf("Expected matching options to be passed to the source\n")
+	}
+}
+
+func TestTransformSearchableComparisons(t *testing.T) {
+	record := TransformSearchable(SearchableTypedRow(map[string]interface{}{"price": 12, "activity": "run"}), testStem)
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"price:>10", true},
+		{"price:<10", false},
+		{"price:>10 activity:running", true},
+		{"_exists_:activity", true},
+		{"_missing_:price", false},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
+
+func TestTransformStringSlice(t *testing.T) {
+	record := TransformStringSlice([]string{"Sunday RUN", "Monday swim"}, strings.ToLower)
+	if !QueryParser("Running").Search(TransformSearchable(record, testStem)) {
+		t.Errorf("Expected a transformed record to match a transformed query\n")
+	}
+	if !QueryParser("sunday swim").Search(record) {
+		t.Errorf("Expected lower case terms to match a lower cased record\n")
+	}
+	if QueryParser("Sunday").Search(record) {
+		t.Errorf("Expected the original record text not to be searched\n")
+	}
+}
+
+func TestFieldTransforms(t *testing.T) {
+	user := map[string]string{"title": "Merry Time", "email": "Bob@Example.com"}
+	record := NewFieldSearchable().
+		Field("title", func() string { return user["title"] }).
+		Field("email", func() string { return strings.ToLower(user["email"]) }).
+		Build()
+	opts := Options{FieldTransforms: map[string]func(string) string{"email": strings.ToLower}}
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"email:bob@example.com", true},
+		{"email:BOB@EXAMPLE.COM", true},
+		{"email:(alice@example.com OR BOB@example.com)", true},
+		{"NOT email:Bob@Example.com", false},
+		{"title:Merry", true},
+		{"title:merry", false},
+		{"BOB", false},
+	} {
+		query, err := QueryParserOptions(test.Condition, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if query.Search(record) != test.Match {
+			t.Errorf("Expected %v for %v\n", test.Match, test.Condition)
+		}
+	}
+
+	opts.FieldTransforms[""] = strings.ToLower
+	query, err := QueryParserOptions("BOB", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(record) {
+		t.Errorf("Expected the default transform to apply to terms without a field\n")
+	}
+}
@@ -0,0 +1,164 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Op is a comparison operator used in queries such as `created:>2020-01-01`.
+*/
+type Op int
+
+const (
+	// OpLess matches values less than the one in the query, written field:<value
+	OpLess Op = iota + 1
+	// OpLessEqual matches values less than or equal to the one in the query, written field:<=value
+	OpLessEqual
+	// OpGreater matches values greater than the one in the query, written field:>value
+	OpGreater
+	// OpGreaterEqual matches values greater than or equal to the one in the query, written field:>=value
+	OpGreaterEqual
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpLess:
+		return "<"
+	case OpLessEqual:
+		return "<="
+	case OpGreater:
+		return ">"
+	case OpGreaterEqual:
+		return ">="
+	}
+	return ""
+}
+
+/*
+DefaultDateLayouts are the layouts tried when parsing dates in comparisons if
+Options.DateLayouts is not set.
+*/
+var DefaultDateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+/*
+DateComparable is an optional interface for Searchable objects that hold dates.
+
+Queries such as `created:>2020-01-01` call CompareDate, which should return true
+if the date in the field compares to t using op, e.g. the field is after t for
+OpGreater.  Dates may also be relative to when the query is parsed, with a
+number of hours, days or weeks such as `created:>-7d` for within the last 7
+days, and ranges such as `created:[2020-01-01 TO 2020-12-31]` call CompareDate
+for each bound.  Searchable objects that do not implement DateComparable but
+do implement FieldValuer have the value of the field parsed as a date using the
+same layouts as the query.  Others are asked whether the field contains the
+text of the comparison, such as `>2020-01-01`.
+*/
+type DateComparable interface {
+	Searchable
+	CompareDate(field string, op Op, t time.Time) (match bool)
+}
+
+// splitComparison separates a leading comparison operator from the value
+func splitComparison(value string) (op Op, rest string) {
+	for _, candidate := range []Op{OpLessEqual, OpGreaterEqual, OpLess, OpGreater} {
+		if strings.HasPrefix(value, candidate.String()) {
+			return candidate, value[len(candidate.String()):]
+		}
+	}
+	return 0, value
+}
+
+// relativeUnits are the units of relative dates such as -7d
+var relativeUnits = map[byte]time.Duration{
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// relativeDate returns the time a relative date such as -7d or +2w is from now, ok is false if value is not one
+func relativeDate(value string, now time.Time) (t time.Time, ok bool) {
+	if len(value) < 3 || (value[0] != '-' && value[0] != '+') {
+		return time.Time{}, false
+	}
+	unit, known := relativeUnits[value[len(value)-1]]
+	if !known {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	if value[0] == '-' {
+		n = -n
+	}
+	return now.Add(time.Duration(n) * unit), true
+}
+
+// now returns the time relative dates are measured from
+func (opts Options) now() time.Time {
+	if opts.Now != nil {
+		return opts.Now()
+	}
+	return time.Now()
+}
+
+// parseDate tries each of the layouts in turn, reporting an error if none match or they disagree on the date.
+// Relative dates such as -7d are resolved against the current time.
+func (opts Options) parseDate(value string) (t time.Time, err error) {
+	if t, ok := relativeDate(value, opts.now()); ok {
+		return t, nil
+	}
+	layouts := opts.DateLayouts
+	if layouts == nil {
+		layouts = DefaultDateLayouts
+	}
+	found := false
+	for _, layout := range layouts {
+		candidate, parseErr := time.Parse(layout, value)
+		if parseErr != nil {
+			continue
+		}
+		if found && !candidate.Equal(t) {
+			return time.Time{}, fmt.Errorf("ambiguous date %q", value)
+		}
+		t = candidate
+		found = true
+	}
+	if !found {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
+
+// compareDateText returns true if text, such as the value of a field, is a date that compares to t using op
+func (opts Options) compareDateText(text string, op Op, t time.Time) bool {
+	date, err := opts.parseDate(strings.TrimSpace(text))
+	if err != nil {
+		return false
+	}
+	compared := 0
+	if date.Before(t) {
+		compared = -1
+	} else if date.After(t) {
+		compared = 1
+	}
+	return compareOp(compared, op)
+}
+
+// mustCompareDate returns true if the Searchable's date in the field compares to t using op
+func mustCompareDate(field string, op Op, t time.Time, phrase string, opts Options, match MatchFunc) filter {
+	literal := op.String() + phrase
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case DateComparable:
+			return s.CompareDate(field, op, t)
+		case FieldValuer:
+			text, present := s.FieldValue(field)
+			return present && opts.compareDateText(text, op, t)
+		}
+		return contains(s, field, literal, match)
+	}
+}
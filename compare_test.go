@@ -0,0 +1,250 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDatedRecord struct {
+	Title   string
+	Created time.Time
+}
+
+func (r *testDatedRecord) Contains(field, phrase string) (present bool) {
+	return strings.Contains(r.Title, phrase)
+}
+
+func (r *testDatedRecord) CompareDate(field string, op Op, t time.Time) (match bool) {
+	if field != "created" {
+		return false
+	}
+	switch op {
+	case OpLess:
+		return r.Created.Before(t)
+	case OpLessEqual:
+		return !r.Created.After(t)
+	case OpGreater:
+		return r.Created.After(t)
+	case OpGreaterEqual:
+		return !r.Created.Before(t)
+	}
+	return false
+}
+
+func TestDateComparison(t *testing.T) {
+	record := &testDatedRecord{Title: "Once upon a time", Created: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)}
+	for _, test := range []struct {
+		Condition string
+		Result    bool
+	}{
+		{"created:>2020-01-01", true},
+		{"created:<2020-01-01", false},
+		{"created:>=2020-06-01T12:00:00Z", true},
+		{"created:>2020-06-01T12:00:00Z", false},
+		{"created:<=2020-06-01T12:00:00Z", true},
+		{"upon created:<2021-01-01", true},
+		{"upon NOT created:<2021-01-01", false},
+		{"frog OR created:>2019-12-31", true},
+		{"created:(<2019-01-01,>2020-05-01)", true},
+	} {
+		query, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+			continue
+		}
+		if result := query.Search(record); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+	}
+
+	// Searchables that can't compare dates are asked for the text of the comparison
+	text := SearchableString("Created >2020-01-01 by the author")
+	if !QueryParser("created:>2020-01-01").Search(text) {
+		t.Errorf("Expected the comparison text to be searched for\n")
+	}
+
+	// Searchables with FieldValue have the value of the field parsed as a date
+	fields := SearchableFields(map[string]string{"created": "2020-06-01", "title": "Created >2020-01-01"})
+	for _, test := range []struct {
+		Condition string
+		Result    bool
+	}{
+		{"created:>2020-01-01", true},
+		{"created:<2020-01-01", false},
+		{"created:[2020-01-01 TO 2020-12-31]", true},
+		{"created:[2021-01-01 TO 2021-12-31]", false},
+		{"title:>2020-01-01", false},
+	} {
+		if result := QueryParser(test.Condition).Search(fields); result != test.Result {
+			t.Errorf("Expected %v for %v in the fields, got %v\n", test.Result, test.Condition, result)
+		}
+	}
+}
+
+func TestDateLayouts(t *testing.T) {
+	record := &testDatedRecord{Created: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)}
+	opts := Options{DateLayouts: []string{time.RFC3339, "2006-01-02", "01/02/2006"}}
+
+	query, err := QueryParserOptions("created:>01/15/2020", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(record) {
+		t.Errorf("Expected a later date to match\n")
+	}
+	query, err = QueryParserOptions("created:>2020-07-01", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if query.Search(record) {
+		t.Errorf("Expected an earlier date not to match\n")
+	}
+
+	// The default layouts don't include 01/02/2006
+	if _, err := QueryParserErr("created:>01/15/2020"); err == nil {
+		t.Errorf("Expected an error for a date not matching the default layouts\n")
+	}
+
+	ambiguous := Options{DateLayouts: []string{"01/02/2006", "02/01/2006"}}
+	for _, test := range []struct {
+		Condition string
+		Valid     bool
+	}{
+		{"created:>03/04/2020", false},
+		{"created:>15/01/2020", true},
+		{"created:>01/01/2020", true},
+		{"created:>13/13/2020", false},
+	} {
+		_, err := QueryParserOptions(test.Condition, ambiguous)
+		if test.Valid && err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if !test.Valid {
+			if perr, ok := err.(*ParseError); !ok || perr.Pos != 8 {
+				t.Errorf("Expected a *ParseError at position 8 for %v, got %v\n", test.Condition, err)
+			}
+		}
+	}
+}
+
+func TestMissingComparisonValue(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+		Message   string
+	}{
+		{"price:>", 6, "missing value after >"},
+		{"boat price:<=", 11, "missing value after <="},
+		{"created:>= whale", 8, "missing value after >="},
+	} {
+		_, err := QueryParserErr(test.Condition)
+		if perr, ok := err.(*ParseError); !ok || perr.Pos != test.Pos || perr.Message != test.Message {
+			t.Errorf("Expected %v at position %v for %v, got %v\n", test.Message, test.Pos, test.Condition, err)
+		}
+	}
+}
+
+func TestDateRanges(t *testing.T) {
+	now := time.Date(2021, 6, 10, 12, 0, 0, 0, time.UTC)
+	opts := Options{Now: func() time.Time { return now }}
+	for _, test := range []struct {
+		Condition string
+		Created   time.Time
+		Result    bool
+	}{
+		{"created:[2020-01-01 TO 2020-12-31]", time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), true},
+		{"created:[2020-01-01 TO 2020-12-31]", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"created:{2020-01-01 TO 2020-12-31]", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"created:[2020-01-01 TO 2020-12-31]", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"created:[2020-01-01 TO 2020-12-31}", time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC), false},
+		{"created:[2020-06-01T00:00:00Z TO 2020-06-02T00:00:00Z]", time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"created:[2021-01-01 TO *]", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), true},
+		{"created:[* TO 2021-01-01]", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), false},
+		{"created:>2021-06-01", time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC), true},
+		{"created:>-7d", time.Date(2021, 6, 5, 0, 0, 0, 0, time.UTC), true},
+		{"created:>-7d", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), false},
+		{"created:<-1w", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), true},
+		{"created:>-36h", time.Date(2021, 6, 9, 6, 0, 0, 0, time.UTC), true},
+		{"created:<+2d", time.Date(2021, 6, 11, 0, 0, 0, 0, time.UTC), true},
+		{"created:[-30d TO -7d]", time.Date(2021, 5, 20, 0, 0, 0, 0, time.UTC), true},
+		{"created:[-30d TO -7d]", time.Date(2021, 6, 5, 0, 0, 0, 0, time.UTC), false},
+	} {
+		query, err := QueryParserOptions(test.Condition, opts)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+			continue
+		}
+		record := &testDatedRecord{Title: "Once upon a time", Created: test.Created}
+		if result := query.Search(record); result != test.Result {
+			t.Errorf("Expected %v for %v created %v, got %v\n", test.Result, test.Condition, test.Created, result)
+		}
+	}
+
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+	}{
+		{"created:>2020-13-01", 8},
+		{"created:[2020-01-01 TO 2020-02-30]", 8},
+		{"created:[2020-01-01 TO 5]", 8},
+	} {
+		_, err := QueryParserOptions(test.Condition, opts)
+		if perr, ok := err.(*ParseError); !ok || perr.Pos != test.Pos {
+			t.Errorf("Expected a *ParseError at position %v for %v, got %v\n", test.Pos, test.Condition, err)
+		}
+	}
+	if query, err := QueryParserOptions("boat created:>-7x", opts); err != nil || len(query.Diagnostics()) != 1 {
+		t.Errorf("Expected a diagnostic for the unknown unit of -7x, got %v\n", err)
+	}
+
+	term := QueryParser("created:>-7d").(*query).root.Children[0].(*Term)
+	if since := time.Since(term.Time); term.Kind != TermDate || since < 7*24*time.Hour || since > 8*24*time.Hour {
+		t.Errorf("Expected -7d to be 7 days before now, got %v\n", term.Time)
+	}
+}
+
+func TestSpacedComparisons(t *testing.T) {
+	record := SearchableTypedRow(map[string]interface{}{
+		"title": "Once upon a time",
+		"price": 75,
+		"date":  time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+	})
+	for _, test := range []struct {
+		Condition string
+		Default   bool
+		Spaced    bool
+	}{
+		{"price >= 50", false, true},
+		{"price >= 100", false, false},
+		{"price > 5", false, true},
+		{"price < 5", false, false},
+		{"date > 2020-01-01", false, true},
+		{"date <= 2020-01-01", false, false},
+		{"upon NOT price >= 100", false, true},
+		{"whale OR price >= 50", false, true},
+		{"upon > time", true, false},
+		{"upon > a time", true, true},
+		{"upon >", true, true},
+		{"price:>=50", true, true},
+		{"(price >= 50) upon", false, true},
+		{"price >= (50)", false, false},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Default {
+			t.Errorf("Expected %v for %v by default, got %v\n", test.Default, test.Condition, result)
+		}
+		query, err := QueryParserOptions(test.Condition, Options{SpacedComparisons: true})
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+			continue
+		}
+		if result := query.Search(record); result != test.Spaced {
+			t.Errorf("Expected %v for %v with SpacedComparisons, got %v\n", test.Spaced, test.Condition, result)
+		}
+	}
+
+	_, err := QueryParserOptions("date > 2020-13-01", Options{SpacedComparisons: true})
+	if perr, ok := err.(*ParseError); !ok || perr.Pos != 5 {
+		t.Errorf("Expected a *ParseError at position 5, got %v\n", err)
+	}
+}
@@ -0,0 +1,42 @@
+package search
+
+import (
+	"context"
+)
+
+/*
+ContextQuery is implemented by a Query that can give up part way through a
+search.  Every Query returned by the package implements it, so
+
+	match, err := query.(search.ContextQuery).SearchContext(ctx, record)
+
+searches record until ctx is done.  Keeping the method out of Query means
+other implementations of Query do not have to provide it.
+*/
+type ContextQuery interface {
+	Query
+
+	/*
+		SearchContext executes the query against s as Search does, checking ctx
+		before searching for each term.  If ctx is done before the search
+		completes, SearchContext gives up and returns ctx.Err().
+
+		This allows long searches, such as of a record with many fields or of
+		every record in a large collection, to be cancelled part way through.
+	*/
+	SearchContext(ctx context.Context, s Searchable) (match bool, err error)
+}
+
+// cancellation stops a search once its context is done
+type cancellation struct {
+	ctx context.Context
+	err error
+}
+
+// done returns true once the context is done, after which err records why
+func (c *cancellation) done() bool {
+	if c.err == nil {
+		c.err = c.ctx.Err()
+	}
+	return c.err != nil
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearchContext(t *testing.T) {
+	var calls int
+	var stop context.CancelFunc
+	record := SearchableFunc(func(field, phrase string) bool {
+		calls++
+		if phrase == "cancel" {
+			stop()
+		}
+		return phrase != "shark" && phrase != "cancel"
+	})
+
+	for _, condition := range []string{"boat whale", "(shark OR whale) NOT (dead OR (shark battle))", "boat NOT shark"} {
+		match, err := QueryParser(condition).(ContextQuery).SearchContext(context.Background(), record)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", condition, err)
+		}
+		if expected := QueryParser(condition).Search(record); match != expected {
+			t.Errorf("Expected %v for %v, got %v\n", expected, condition, match)
+		}
+	}
+
+	for _, test := range []struct {
+		Condition string
+		Calls     int
+	}{
+		{"boat (cancel OR whale)", 2},
+		{"(shark OR cancel OR whale) boat", 2},
+		{"boat NOT (shark OR cancel OR dead)", 3},
+		{"NOT (shark OR (cancel OR whale))", 2},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		stop = cancel
+		calls = 0
+		match, err := QueryParser(test.Condition).(ContextQuery).SearchContext(ctx, record)
+		if err != context.Canceled || match {
+			t.Errorf("Expected no match and a cancelled error for %v, got %v, %v\n", test.Condition, match, err)
+		}
+		if calls != test.Calls {
+			t.Errorf("Expected %v calls for %v, got %v\n", test.Calls, test.Condition, calls)
+		}
+		cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	if _, err := QueryParser("boat").(ContextQuery).SearchContext(ctx, record); err != context.Canceled || calls != 0 {
+		t.Errorf("Expected a cancelled context to search nothing, got %v after %v calls\n", err, calls)
+	}
+}
+
+func TestSearchContextCompilesOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := QueryParser("items.name:widget items.colour:red").(*query)
+	red, _ := SearchableJSON([]byte(`{"items": [{"name": "widget", "colour": "red"}]}`))
+	blue, _ := SearchableJSON([]byte(`{"items": [{"name": "widget", "colour": "blue"}, {"name": "gadget", "colour": "red"}]}`))
+	for _, test := range []struct {
+		Record Searchable
+		Match  bool
+	}{{red, true}, {blue, false}, {red, true}} {
+		if match, err := q.SearchContext(ctx, test.Record); err != nil || match != test.Match {
+			t.Errorf("Expected %v from SearchContext, got %v, %v\n", test.Match, match, err)
+		}
+	}
+	first := q.calls
+	if _, err := q.SearchContext(ctx, red); err != nil || len(first) == 0 || &q.calls[0] != &first[0] {
+		t.Errorf("Expected the filters that check the context to be compiled once\n")
+	}
+}
@@ -0,0 +1,59 @@
+package search
+
+// The costs of searching for the kinds of term, relative to asking a Searchable whether it contains a single phrase
+const (
+	// termCost is the cost of asking a Searchable whether it contains a single phrase
+	termCost = 1
+	// compareCost is the cost of a term that parses the value of a field, such as price:>5 or created:>-7d
+	compareCost = 2
+	// wildcardCost is the cost of a term such as boa* that looks for the start of every word
+	wildcardCost = 2
+	// nearCost is the cost of each phrase of a term such as "climate change" NEAR/5 policy, which finds every place each phrase is
+	nearCost = 4
+	// fuzzyCost is the cost of a term such as whale~1 that measures the edit distance of every word
+	fuzzyCost = 8
+	// regexCost is the cost of a term such as title:/^Once .* time$/ that runs a regular expression
+	regexCost = 10
+)
+
+/*
+nodeCost estimates how expensive a node is to search.
+
+The estimate is the sum of the cost of each term beneath the node, so it grows
+in proportion to the number of terms in the query.  Each term is weighted by
+its kind, so a regular expression or fuzzy term costs more than a phrase.
+*/
+func nodeCost(n Node) (cost int) {
+	switch n := n.(type) {
+	case *Term:
+		return termKindCost(n)
+	case *AndNode:
+		for _, child := range n.Children {
+			cost += nodeCost(child)
+		}
+	case *OrNode:
+		for _, child := range n.Children {
+			cost += nodeCost(child)
+		}
+	case *NotNode:
+		cost = nodeCost(n.Child)
+	}
+	return cost
+}
+
+// termKindCost returns the cost of searching for the term, weighted by its kind
+func termKindCost(n *Term) int {
+	switch n.Kind {
+	case TermRegex:
+		return regexCost
+	case TermFuzzy:
+		return fuzzyCost
+	case TermNear, TermNearOrdered:
+		return nearCost * len(nearPhrases(n))
+	case TermWildcard:
+		return wildcardCost
+	case TermDate, TermNumber, TermString, TermCIDR, TermRange, TermDateRange:
+		return compareCost
+	}
+	return termCost
+}
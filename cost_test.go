@@ -0,0 +1,60 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestCost(t *testing.T) {
+	single := QueryParser("boat").Cost()
+	if single <= 0 {
+		t.Fatalf("Expected a positive cost for a single term, got %v\n", single)
+	}
+	for _, test := range []struct {
+		Condition string
+		Terms     int
+	}{
+		{"", 0},
+		{"()", 0},
+		{"boat whale", 2},
+		{"boat whale shark", 3},
+		{"boat OR whale NOT shark", 3},
+		{"boat (whale OR (shark frog))", 4},
+		{"NOT (boat whale)", 2},
+		{"any(title,subject,body):merry", 3},
+	} {
+		if cost := QueryParser(test.Condition).Cost(); cost != test.Terms*single {
+			t.Errorf("Expected cost %v for %v, got %v\n", test.Terms*single, test.Condition, cost)
+		}
+	}
+}
+
+func TestCostByKind(t *testing.T) {
+	single := QueryParser("boat").Cost()
+	for _, condition := range []string{"title:/.*(a|b)*.*/", "whale~1", "boa*", "price:>5", `"climate change" NEAR/5 policy`} {
+		if cost := QueryParser(condition).Cost(); cost <= single {
+			t.Errorf("Expected %v to cost more than a phrase, got %v\n", condition, cost)
+		}
+	}
+	regex := QueryParser("title:/^Once/").Cost()
+	if fuzzy := QueryParser("whale~1").Cost(); regex <= fuzzy {
+		t.Errorf("Expected a regular expression to cost more than a fuzzy term, got %v and %v\n", regex, fuzzy)
+	}
+
+	// Costs of several terms add up in proportion
+	for _, test := range []struct {
+		Condition string
+		Cost      int
+	}{
+		{"title:/^Once/ body:/time$/", 2 * regex},
+		{"boat title:/^Once/ NOT whale", regex + 2*single},
+		{"title:/^Once/ OR (boat whale)", regex + 2*single},
+	} {
+		if cost := QueryParser(test.Condition).Cost(); cost != test.Cost {
+			t.Errorf("Expected cost %v for %v, got %v\n", test.Cost, test.Condition, cost)
+		}
+	}
+	near := QueryParser("whale NEAR/3 boat").Cost()
+	if chained := QueryParser("whale NEAR/3 boat NEAR/3 shark").Cost(); chained <= near {
+		t.Errorf("Expected each phrase of a NEAR to add to its cost, got %v and %v\n", chained, near)
+	}
+}
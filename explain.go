@@ -0,0 +1,146 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Explanation reports how a record was matched by a Query, mirroring the tree
+that the query was parsed into.
+
+Operator is AND, OR or NOT for the nodes that combine others, with the
+explanations of those nodes in Children, and is empty for terms.  Field and
+Phrase are those of a term.  Node is the parsed node being explained, giving
+its span in the query.
+
+Every node is checked, even those a search would skip because the result was
+already known, so that all of the terms that failed are reported.
+*/
+type Explanation struct {
+	Operator string
+	Field    string
+	Phrase   string
+	Matched  bool
+	Children []Explanation
+	Node     Node
+}
+
+/*
+String returns the explanation as indented lines, one per node, for logging.
+*/
+func (e Explanation) String() string {
+	var b strings.Builder
+	e.format(&b, 0)
+	return b.String()
+}
+
+// format writes the explanation and its children indented by depth
+func (e Explanation) format(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	switch {
+	case e.Operator != "":
+		b.WriteString(e.Operator)
+	case e.Field != "":
+		fmt.Fprintf(b, "%v:%q", e.Field, e.Phrase)
+	default:
+		fmt.Fprintf(b, "%q", e.Phrase)
+	}
+	fmt.Fprintf(b, " %v\n", e.Matched)
+	for _, child := range e.Children {
+		child.format(b, depth+1)
+	}
+}
+
+/*
+MatchedTerm is a term that a record matched, as reported by MatchedTerms.
+
+Field and Phrase are those of the term, and Operator is AND or OR as the term,
+or the group it is within, is joined to its neighbours by one or the other.
+Term is the parsed term, giving its span in the query.
+*/
+type MatchedTerm struct {
+	Field    string
+	Phrase   string
+	Operator string
+	Term     *Term
+}
+
+// termField returns the field of the term as written in the query, such as _field_ for a TermFieldName
+func termField(n *Term) string {
+	switch n.Kind {
+	case TermFieldName:
+		return fieldNameField
+	case TermFieldExists:
+		return existsField
+	}
+	return n.Field
+}
+
+// explain checks n and each of the nodes beneath it against the Searchable
+func explain(n Node, s Searchable, opts Options) Explanation {
+	switch n := n.(type) {
+	case *Term:
+		return Explanation{Field: termField(n), Phrase: n.Phrase, Matched: compile(n, opts, nil)(s), Node: n}
+	case *AndNode:
+		e := Explanation{Operator: "AND", Matched: true, Children: explainAll(n.Children, s, opts), Node: n}
+		// Optional terms only decide the result when there is nothing else to match, as in compileAnd
+		var required, optionalMatched bool
+		for i, child := range e.Children {
+			if isOptional(n.Children[i]) {
+				optionalMatched = optionalMatched || child.Matched
+				continue
+			}
+			required = true
+			e.Matched = e.Matched && child.Matched
+		}
+		if !required && len(e.Children) > 0 {
+			e.Matched = optionalMatched
+		}
+		return e
+	case *OrNode:
+		e := Explanation{Operator: "OR", Children: explainAll(n.Children, s, opts), Node: n}
+		for _, child := range e.Children {
+			e.Matched = e.Matched || child.Matched
+		}
+		return e
+	case *NotNode:
+		child := explain(n.Child, s, opts)
+		return Explanation{Operator: "NOT", Matched: !child.Matched, Children: []Explanation{child}, Node: n}
+	}
+	panic("search: unknown node type")
+}
+
+// explainAll explains each of the nodes
+func explainAll(nodes []Node, s Searchable, opts Options) []Explanation {
+	results := make([]Explanation, len(nodes))
+	for i, n := range nodes {
+		results[i] = explain(n, s, opts)
+	}
+	return results
+}
+
+// matchedTerms adds the terms beneath n that s matches, and which a search for n relies on, to matched.
+// op is the operator joining n to its neighbours, and ok is false if s does not match n.
+func matchedTerms(n Node, op string, s Searchable, opts Options, matched []MatchedTerm) (results []MatchedTerm, ok bool) {
+	if !compile(n, opts, nil)(s) {
+		return matched, false
+	}
+	switch n := n.(type) {
+	case *Term:
+		matched = append(matched, MatchedTerm{Field: termField(n), Phrase: n.Phrase, Operator: op, Term: n})
+	case *AndNode:
+		for _, child := range n.Children {
+			matched, _ = matchedTerms(child, "AND", s, opts, matched)
+		}
+	case *OrNode:
+		// Only the first alternative that matches is reported, as a search stops there
+		for _, child := range n.Children {
+			if matched, ok = matchedTerms(child, "OR", s, opts, matched); ok {
+				break
+			}
+		}
+	}
+	// A NOT matches when its child does not, so has no terms to report
+	return matched, true
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	e := QueryParser("title:merry whale OR battle NOT (bottle fought)").Explain(testFieldMaterial)
+	if e.Matched || e.Operator != "AND" || len(e.Children) != 3 {
+		t.Fatalf("Expected an unmatched AND of 3 nodes, got %v\n", e)
+	}
+	if term := e.Children[0]; term.Field != "title" || term.Phrase != "merry" || !term.Matched {
+		t.Errorf("Expected title:merry to match, got %v\n", term)
+	}
+	or := e.Children[1]
+	if or.Operator != "OR" || !or.Matched || or.Children[0].Matched || !or.Children[1].Matched {
+		t.Errorf("Expected whale OR battle to match on battle, got %v\n", or)
+	}
+	not := e.Children[2]
+	if not.Operator != "NOT" || not.Matched || !not.Children[0].Matched || len(not.Children[0].Children) != 2 {
+		t.Errorf("Expected NOT (bottle fought) to fail, got %v\n", not)
+	}
+	if start, end := not.Node.Span(); start != 28 || end != 47 {
+		t.Errorf("Expected the NOT to span 28-47, got %v-%v\n", start, end)
+	}
+
+	expected := `AND false
+  title:"merry" true
+  OR true
+    "whale" false
+    "battle" true
+  NOT false
+    AND true
+      "bottle" true
+      "fought" true
+`
+	if text := e.String(); text != expected {
+		t.Errorf("Expected:\n%v\ngot:\n%v\n", expected, text)
+	}
+
+	// Every term is checked, even after the result is known
+	e = QueryParser("frog merry").Explain(testFieldMaterial)
+	if e.Matched || e.Children[0].Matched || !e.Children[1].Matched {
+		t.Errorf("Expected frog to fail and merry to match, got %v\n", e)
+	}
+
+	// Optional terms are reported without affecting the result
+	e = QueryParser("merry ?frog").Explain(testFieldMaterial)
+	if !e.Matched || e.Children[1].Matched {
+		t.Errorf("Expected a match with ?frog reported as failing, got %v\n", e)
+	}
+
+	for _, test := range testCases {
+		q := QueryParser(test.Condition)
+		if q.Explain(test.Records).Matched != q.Search(test.Records) {
+			t.Errorf("Expected Explain to agree with Search for %v\n", test.Condition)
+		}
+	}
+}
+
+func TestMatchedTerms(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Matched   []string
+	}{
+		{"title:merry beetle", []string{"AND title:merry", "AND beetle"}},
+		{"whale OR battle OR bottle", []string{"OR battle"}},
+		{"merry NOT whale", []string{"AND merry"}},
+		{"merry (whale OR (beetle bottle))", []string{"AND merry", "AND beetle", "AND bottle"}},
+		{"merry ?whale ?bottle", []string{"AND merry", "AND bottle"}},
+		{"title:(whale OR merry) battle", []string{"OR title:merry", "AND battle"}},
+		{"merry whale", nil},
+		{"NOT merry", nil},
+	} {
+		var matched []string
+		for _, term := range QueryParser(test.Condition).MatchedTerms(testFieldMaterial) {
+			text := term.Phrase
+			if term.Field != "" {
+				text = term.Field + ":" + text
+			}
+			matched = append(matched, term.Operator+" "+text)
+		}
+		if strings.Join(matched, "|") != strings.Join(test.Matched, "|") {
+			t.Errorf("Expected %v for %v, got %v\n", test.Matched, test.Condition, matched)
+		}
+	}
+
+	// A match without terms to report is told apart from a record that does not match
+	if matched := QueryParser("NOT whale").MatchedTerms(testFieldMaterial); matched == nil || len(matched) != 0 {
+		t.Errorf("Expected an empty slice that is not nil for a match without positive terms, got %#v\n", matched)
+	}
+	if matched := QueryParser("NOT merry").MatchedTerms(testFieldMaterial); matched != nil {
+		t.Errorf("Expected nil for a record that does not match, got %#v\n", matched)
+	}
+
+	q := QueryParser("whale OR title:merry")
+	if matched := q.MatchedTerms(testFieldMaterial); len(matched) != 1 || matched[0].Term != q.(*query).root.Children[0].(*OrNode).Children[1] {
+		t.Errorf("Expected the parsed term to be reported, got %v\n", matched)
+	}
+}
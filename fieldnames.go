@@ -0,0 +1,156 @@
+package search
+
+import (
+	"path"
+)
+
+// fieldNameField is the field used in queries to match against field names
+const fieldNameField = "_field_"
+
+// existsField and missingField are the fields used in queries to match records that have, or lack, a field
+const (
+	existsField  = "_exists_"
+	missingField = "_missing_"
+)
+
+/*
+FieldNamer is an optional interface for Searchable objects that can list the
+names of their fields.
+
+Queries such as `_field_:author*` use FieldNames to find records that have a
+field whose name matches the pattern.  Patterns use the syntax of path.Match,
+so `*` matches any sequence of characters.  Searchable objects that do not
+implement FieldNamer are asked whether their `_field_` field contains the
+pattern, as for any other field.
+*/
+type FieldNamer interface {
+	Searchable
+	/*
+		FieldNames returns the names of the fields present in the object.
+	*/
+	FieldNames() (names []string)
+}
+
+/*
+FieldExister is an optional interface for Searchable objects that can report
+whether they have a field.
+
+Queries such as `_exists_:thumbnail` use HasField to find records that have
+the field, while `_missing_:thumbnail`, the same as `NOT _exists_:thumbnail`,
+finds those without it.  Searchable objects that do not implement FieldExister
+are asked whether their `_exists_` field contains the field name, as for any
+other field.  HasField is also used by Options.TreatMissingFieldAsMatch.
+*/
+type FieldExister interface {
+	Searchable
+	/*
+		HasField returns true if the object has the field, even if it is empty.
+	*/
+	HasField(field string) (present bool)
+}
+
+// hasField returns true if the Searchable has the field, or can not say because it does not implement FieldExister
+func hasField(s Searchable, field string) bool {
+	exister, ok := s.(FieldExister)
+	return !ok || exister.HasField(field)
+}
+
+// missingFieldMatches returns true if the Searchable does not have the field, otherwise the result of f
+func missingFieldMatches(field string, f filter) filter {
+	return func(s Searchable) bool {
+		if !hasField(s, field) {
+			return true
+		}
+		return f(s)
+	}
+}
+
+// mustHaveField returns true if the Searchable has the field
+func mustHaveField(field string) filter {
+	return func(s Searchable) bool {
+		exister, ok := s.(FieldExister)
+		if !ok {
+			return s.Contains(existsField, field)
+		}
+		return exister.HasField(field)
+	}
+}
+
+// mustHaveFieldName returns true if the Searchable has a field whose name matches the pattern
+func mustHaveFieldName(pattern string) filter {
+	return func(s Searchable) bool {
+		namer, ok := s.(FieldNamer)
+		if !ok {
+			return s.Contains(fieldNameField, pattern)
+		}
+		for _, name := range namer.FieldNames() {
+			// The pattern is checked when the query is parsed, so errors can not occur here
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// implicitField restricts each term beneath n that is written without a field to the field
+func implicitField(n Node, field string) {
+	if term, ok := n.(*Term); ok {
+		if !needsImplicitField(term) {
+			return
+		}
+		// Each of the phrases of a NEAR term is restricted to the field
+		for ; term != nil; term = term.Near {
+			term.Field = field
+		}
+		return
+	}
+	for _, child := range children(n) {
+		implicitField(child, field)
+	}
+}
+
+// implicitFields replaces each term beneath n that is written without a field with an OR of the term in each of the fields.
+// It returns the node that replaces n.
+func implicitFields(n Node, fields []string) Node {
+	switch n := n.(type) {
+	case *Term:
+		if !needsImplicitField(n) {
+			return n
+		}
+		terms := make([]Node, len(fields))
+		for i, field := range fields {
+			term := copyTerm(n)
+			implicitField(term, field)
+			terms[i] = term
+		}
+		return newOrNode(terms...)
+	case *AndNode:
+		for i, child := range n.Children {
+			n.Children[i] = implicitFields(child, fields)
+		}
+	case *OrNode:
+		// The alternatives of a term join the OR it is in, so merry OR frog stays a single OR
+		alternatives := make([]Node, 0, len(n.Children))
+		for _, child := range n.Children {
+			if or, ok := implicitFields(child, fields).(*OrNode); ok {
+				alternatives = append(alternatives, or.Children...)
+			} else {
+				alternatives = append(alternatives, child)
+			}
+		}
+		n.Children = alternatives
+	case *NotNode:
+		n.Child = implicitFields(n.Child, fields)
+	}
+	return n
+}
+
+// needsImplicitField returns true for terms written without a field that search for text
+func needsImplicitField(term *Term) bool {
+	switch term.Kind {
+	case TermContains, TermWildcard, TermFuzzy, TermNear, TermNearOrdered:
+		return term.Field == ""
+	}
+	return false
+}
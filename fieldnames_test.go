@@ -0,0 +1,259 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+type testSchemalessRecord map[string]string
+
+func (r testSchemalessRecord) Contains(field, phrase string) (present bool) {
+	if field != "" {
+		return strings.Contains(r[field], phrase)
+	}
+	for _, value := range r {
+		if strings.Contains(value, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r testSchemalessRecord) FieldNames() (names []string) {
+	for name := range r {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (r testSchemalessRecord) HasField(field string) (present bool) {
+	_, present = r[field]
+	return present
+}
+
+func TestFieldNames(t *testing.T) {
+	record := testSchemalessRecord{
+		"title":       "Once upon a very merry time",
+		"author":      "A. Writer",
+		"author_note": "Written in a bottle",
+	}
+	for _, test := range []struct {
+		Condition string
+		Result    bool
+	}{
+		{"_field_:author", true},
+		{"_field_:author*", true},
+		{"_field_:auth", false},
+		{"_field_:*note", true},
+		{"_field_:editor", false},
+		{"merry NOT _field_:editor", true},
+		{"merry NOT _field_:author", false},
+		{"_field_:editor OR _field_:title", true},
+		{"_field_:(editor,title)", true},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+	}
+
+	// Searchables without FieldNames treat _field_ as an ordinary field
+	fallback := SearchableFunc(func(field, phrase string) bool {
+		return field == "_field_" && phrase == "author"
+	})
+	if !QueryParser("_field_:author").Search(fallback) {
+		t.Errorf("Expected _field_ to be passed to Contains when FieldNames is not implemented\n")
+	}
+
+	if _, err := QueryParserErr("_field_:[author"); err == nil {
+		t.Errorf("Expected an error for an invalid field name pattern\n")
+	}
+}
+
+func TestFieldExists(t *testing.T) {
+	record := testSchemalessRecord{
+		"title":     "Once upon a very merry time",
+		"thumbnail": "",
+	}
+	for _, test := range []struct {
+		Condition string
+		Result    bool
+		String    string
+	}{
+		{"_exists_:thumbnail", true, "_exists_:thumbnail"},
+		{"_exists_:author", false, "_exists_:author"},
+		{"NOT _exists_:thumbnail", false, "_missing_:thumbnail"},
+		{"NOT _exists_:author", true, "_missing_:author"},
+		{"_missing_:author", true, "_missing_:author"},
+		{"merry _missing_:thumbnail", false, "merry _missing_:thumbnail"},
+		{"NOT _missing_:title", true, "NOT _missing_:title"},
+		{"NOT _missing_:author", false, "NOT _missing_:author"},
+		{`_missing_:"release date"`, true, `_missing_:"release date"`},
+		{"NOT (NOT _exists_:title)", true, "NOT (_missing_:title)"},
+		{"_exists_:(author,title)", true, "_exists_:author OR _exists_:title"},
+		{`_exists_:"release date"`, false, `_exists_:"release date"`},
+		{"merry ?_exists_:author", true, "merry ?_exists_:author"},
+	} {
+		q := QueryParser(test.Condition)
+		if result := q.Search(record); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		if result := q.String(); result != test.String {
+			t.Errorf("Expected %v for %v, got %v\n", test.String, test.Condition, result)
+		}
+		// The text must parse back to a query with the same result
+		if result := QueryParser(q.String()).Search(record); result != test.Result {
+			t.Errorf("Expected %v for %v written as %v, got %v\n", test.Result, test.Condition, q.String(), result)
+		}
+	}
+
+	// Searchables without HasField treat _exists_ as an ordinary field
+	fallback := SearchableFunc(func(field, phrase string) bool {
+		return field == "_exists_" && phrase == "thumbnail"
+	})
+	if !QueryParser("_exists_:thumbnail").Search(fallback) || QueryParser("_missing_:thumbnail").Search(fallback) {
+		t.Errorf("Expected _exists_ to be passed to Contains when HasField is not implemented\n")
+	}
+}
+
+func TestTreatMissingFieldAsMatch(t *testing.T) {
+	record := testSchemalessRecord{"body": "A beetle battle fought in a bottle"}
+	for _, test := range []struct {
+		Condition string
+		Default   bool
+		Missing   bool
+	}{
+		{"title:merry", false, true},
+		{"NOT title:merry", true, false},
+		{"body:beetle title:merry", false, true},
+		{"body:beetle NOT title:merry", true, false},
+		{"body:merry", false, false},
+		{"NOT body:merry", true, true},
+		{"merry", false, false},
+		{"NOT merry", true, true},
+		{"NOT (title:merry OR body:whale)", true, false},
+	} {
+		query := QueryParser(test.Condition)
+		if query.Search(record) != test.Default {
+			t.Errorf("Expected %v for %v by default\n", test.Default, test.Condition)
+		}
+		query, err := QueryParserOptions(test.Condition, Options{TreatMissingFieldAsMatch: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if query.Search(record) != test.Missing {
+			t.Errorf("Expected %v for %v with TreatMissingFieldAsMatch\n", test.Missing, test.Condition)
+		}
+	}
+
+	// Records that can not report their fields are asked as usual
+	query, err := QueryParserOptions("title:merry", Options{TreatMissingFieldAsMatch: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if query.Search(SearchableFunc(func(field, phrase string) bool { return false })) {
+		t.Errorf("Expected a record without HasField to be asked using Contains\n")
+	}
+}
+
+func TestDefaultField(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Default   bool
+		Implicit  bool
+	}{
+		{"merry", true, true},
+		{"merry battle", true, false},
+		{"battle", true, false},
+		{"merry body:battle", true, true},
+		{"NOT battle", false, true},
+		{"whale OR time", true, true},
+		{"battle~1", true, false},
+		{"merry~1", true, true},
+		{"merry NEAR/5 battle", true, false},
+		{"merry NEAR/5 time", true, true},
+		{"title:merry NEAR/5 title:time", true, true},
+	} {
+		if result := QueryParser(test.Condition).Search(testFieldMaterial); result != test.Default {
+			t.Errorf("Expected %v for %v by default, got %v\n", test.Default, test.Condition, result)
+		}
+		// ImplicitField is the earlier name for DefaultField
+		for _, opts := range []Options{{DefaultField: "title"}, {ImplicitField: "title"}} {
+			query, err := QueryParserOptions(test.Condition, opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			if result := query.Search(testFieldMaterial); result != test.Implicit {
+				t.Errorf("Expected %v for %v with %+v, got %v\n", test.Implicit, test.Condition, opts, result)
+			}
+		}
+	}
+
+	// Setting more than one of the default fields is an error rather than one of them being ignored
+	for _, opts := range []Options{
+		{DefaultField: "title", ImplicitField: "body"},
+		{DefaultField: "title", ImplicitField: "title"},
+		{DefaultField: "title", DefaultFields: []string{"title", "body"}},
+		{DefaultField: "title", DefaultFields: []string{"title"}},
+		{ImplicitField: "title", DefaultFields: []string{"body"}},
+	} {
+		if _, err := QueryParserOptions("battle", opts); err == nil {
+			t.Errorf("Expected an error for conflicting default fields in %+v\n", opts)
+		}
+	}
+}
+
+func TestDefaultFields(t *testing.T) {
+	record := SearchableFields(map[string]string{
+		"title":          "Once upon a very merry time",
+		"body":           "A beetle battle fought in a bottle",
+		"internal_notes": "frog",
+	})
+	for _, test := range []struct {
+		Condition string
+		Opts      Options
+		Match     bool
+	}{
+		{"merry battle", Options{DefaultFields: []string{"title", "body"}}, true},
+		{"frog", Options{DefaultFields: []string{"title", "body"}}, false},
+		{"frog", Options{DefaultFields: []string{}}, true},
+		{"frog", Options{}, true},
+		{"merry OR frog", Options{DefaultFields: []string{"title", "body"}}, true},
+		{"frog OR toad", Options{DefaultFields: []string{"title", "body"}}, false},
+		{"NOT battle", Options{DefaultFields: []string{"title", "body"}}, false},
+		{"NOT frog", Options{DefaultFields: []string{"title", "body"}}, true},
+		{"internal_notes:frog", Options{DefaultFields: []string{"title", "body"}}, true},
+		{"battle", Options{ImplicitField: "title", DefaultFields: []string{}}, false},
+		{"battle", Options{DefaultField: "title", DefaultFields: []string{}}, false},
+		{"beetle NEAR/1 fought", Options{DefaultFields: []string{"title", "body"}}, true},
+	} {
+		q, err := QueryParserOptions(test.Condition, test.Opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if result := q.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v with %v, got %v\n", test.Match, test.Condition, test.Opts.DefaultFields, result)
+		}
+	}
+
+	// A term becomes an OR of the term in each field, which joins any OR the term is in
+	q, _ := QueryParserOptions("merry OR frog", Options{DefaultFields: []string{"title", "body"}})
+	if result := q.String(); result != "title:merry OR body:merry OR title:frog OR body:frog" {
+		t.Errorf("Expected a single OR across the fields, got %v\n", result)
+	}
+
+	// A NEAR in each field is bracketed so that the OR between them reads back
+	for _, condition := range []string{"beetle NEAR/1 fought", "merry beetle ONEAR/2 battle", "frog OR (beetle NEAR/1 fought)"} {
+		opts := Options{DefaultFields: []string{"title", "body"}}
+		q, err := QueryParserOptions(condition, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		reparsed, err := QueryParserOptions(q.String(), opts)
+		if err != nil {
+			t.Fatalf("Expected %v to parse back, got %v\n", q.String(), err)
+		}
+		if reparsed.String() != q.String() || reparsed.Search(record) != q.Search(record) {
+			t.Errorf("Expected %v to read back the same, got %v\n", q.String(), reparsed.String())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"strings"
+)
+
+/*
+FieldProvider is a richer alternative to Searchable for records made up of
+named fields.  Use FromFieldProvider to search one.
+*/
+type FieldProvider interface {
+	/*
+		Field returns the value of the named field, and whether the record has
+		the field at all.
+	*/
+	Field(name string) (value string, present bool)
+	/*
+		AnyMatch returns true if the phrase is present in any field of the record.
+	*/
+	AnyMatch(phrase string) (present bool)
+}
+
+/*
+FromFieldProvider makes a FieldProvider Searchable.
+
+Terms with a field are matched against the value returned by Field, so
+matching options such as Options.GraphemeAware are applied to them.  Terms
+without a field are passed to AnyMatch.  The Searchable implements
+FieldExister and FieldValuer, reporting the fields that Field says are present.
+*/
+func FromFieldProvider(fp FieldProvider) Searchable {
+	return fieldProviderSearchable{fp}
+}
+
+// fieldProviderSearchable is the Searchable returned by FromFieldProvider
+type fieldProviderSearchable struct {
+	fp FieldProvider
+}
+
+func (fps fieldProviderSearchable) Contains(field, phrase string) (present bool) {
+	return fps.ContainsMatch(field, phrase, strings.Contains)
+}
+
+func (fps fieldProviderSearchable) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	if field == "" {
+		return fps.fp.AnyMatch(phrase)
+	}
+	value, present := fps.fp.Field(field)
+	return present && match(value, phrase)
+}
+
+func (fps fieldProviderSearchable) HasField(field string) (present bool) {
+	_, present = fps.fp.Field(field)
+	return present
+}
+
+func (fps fieldProviderSearchable) FieldValue(field string) (value string, present bool) {
+	return fps.fp.Field(field)
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+type testProvider map[string]string
+
+func (p testProvider) Field(name string) (value string, present bool) {
+	value, present = p[name]
+	return value, present
+}
+
+func (p testProvider) AnyMatch(phrase string) (present bool) {
+	for _, value := range p {
+		if strings.Contains(value, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFromFieldProvider(t *testing.T) {
+	record := FromFieldProvider(testProvider{
+		"title": "Once upon a very merry time",
+		"body":  "A beetle battle fought in a bottle",
+	})
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"merry", true},
+		{"beetle merry", true},
+		{"whale", false},
+		{"title:merry", true},
+		{"title:beetle", false},
+		{"author:merry", false},
+		{"NOT author:merry", true},
+		{"any(title,author):merry", true},
+	} {
+		if QueryParser(test.Condition).Search(record) != test.Match {
+			t.Errorf("Expected %v for %v\n", test.Match, test.Condition)
+		}
+	}
+
+	query, err := QueryParserOptions("author:merry", Options{TreatMissingFieldAsMatch: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(record) {
+		t.Errorf("Expected a missing field to be reported by HasField\n")
+	}
+
+	query, err = QueryParserOptions("title:👩", Options{GraphemeAware: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if query.Search(FromFieldProvider(testProvider{"title": "👨‍👩‍👧"})) {
+		t.Errorf("Expected matching options to be applied to field values\n")
+	}
+}
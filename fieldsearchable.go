@@ -0,0 +1,126 @@
+package search
+
+import (
+	"strings"
+)
+
+/*
+FieldSearchableBuilder builds a Searchable from functions that return the value
+of each field, created by NewFieldSearchable.
+
+This gives the convenience of describing a record by its fields without the
+cost of reflection:
+
+	record := NewFieldSearchable().
+		Field("title", func() string { return book.Title }).
+		Field("body", func() string { return book.Body }).
+		Build()
+*/
+type FieldSearchableBuilder struct {
+	names   []string
+	getters []func() string
+}
+
+/*
+NewFieldSearchable returns an empty FieldSearchableBuilder.
+*/
+func NewFieldSearchable() *FieldSearchableBuilder {
+	return &FieldSearchableBuilder{}
+}
+
+/*
+Field registers the getter that returns the value of the named field.
+
+Terms without a field, such as `merry`, search every registered field.
+*/
+func (b *FieldSearchableBuilder) Field(name string, getter func() string) *FieldSearchableBuilder {
+	b.names = append(b.names, name)
+	b.getters = append(b.getters, getter)
+	return b
+}
+
+/*
+Build returns a Searchable for the registered fields.
+
+Getters are only called when their field is searched, and each is called at
+most once while a Query searches the Searchable, however many terms search
+its field.  The values are read again by the next search, so a Searchable
+whose getters read the current record, such as book above, can be built once
+and used for every record.  Searching the Searchable in some other way, such
+as within SearchableSliceOf, calls the getters for each term.
+
+The Searchable implements MatchSearchable, FieldNamer, FieldExister and
+FieldValuer, and may be searched by several goroutines at once if the getters
+allow it.
+*/
+func (b *FieldSearchableBuilder) Build() Searchable {
+	return &fieldSearchable{
+		names:   b.names[:len(b.names):len(b.names)],
+		getters: b.getters[:len(b.getters):len(b.getters)],
+	}
+}
+
+// fieldSearchable is the Searchable built by FieldSearchableBuilder
+type fieldSearchable struct {
+	names   []string
+	getters []func() string
+	// values holds those read during a single search, or is nil for the Searchable returned by Build
+	values []fieldValue
+}
+
+// fieldValue is the value of a field, once read is set
+type fieldValue struct {
+	value string
+	read  bool
+}
+
+// forSearch returns a copy of fs for a single search, which calls each getter at most once
+func (fs *fieldSearchable) forSearch() *fieldSearchable {
+	return &fieldSearchable{names: fs.names, getters: fs.getters, values: make([]fieldValue, len(fs.getters))}
+}
+
+// value returns the value of the field at index i, calling its getter unless it has already been read for the search
+func (fs *fieldSearchable) value(i int) string {
+	if fs.values == nil {
+		return fs.getters[i]()
+	}
+	if !fs.values[i].read {
+		fs.values[i] = fieldValue{value: fs.getters[i](), read: true}
+	}
+	return fs.values[i].value
+}
+
+func (fs *fieldSearchable) Contains(field, phrase string) (present bool) {
+	return fs.ContainsMatch(field, phrase, strings.Contains)
+}
+
+func (fs *fieldSearchable) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	for i, name := range fs.names {
+		if (field == "" || field == name) && match(fs.value(i), phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *fieldSearchable) FieldNames() (names []string) {
+	return fs.names
+}
+
+func (fs *fieldSearchable) HasField(field string) (present bool) {
+	for _, name := range fs.names {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *fieldSearchable) FieldValue(field string) (value string, present bool) {
+	for i, name := range fs.names {
+		if name == field {
+			return fs.value(i), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,123 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testBook struct {
+	Title string
+	Body  string
+}
+
+func TestFieldSearchable(t *testing.T) {
+	book := testBook{Title: "Once upon a very merry time", Body: "A beetle battle fought in a bottle"}
+	record := NewFieldSearchable().
+		Field("title", func() string { return book.Title }).
+		Field("body", func() string { return book.Body }).
+		Build()
+
+	for _, test := range []struct {
+		Condition string
+		Result    bool
+	}{
+		{"merry", true},
+		{"beetle", true},
+		{"title:merry", true},
+		{"title:beetle", false},
+		{"body:beetle NOT title:beetle", true},
+		{"author:merry", false},
+		{"_field_:ti*", true},
+	} {
+		if QueryParser(test.Condition).Search(record) != test.Result {
+			t.Errorf("Expected %v for %v\n", test.Result, test.Condition)
+		}
+	}
+
+	lazy := NewFieldSearchable().
+		Field("title", func() string { return book.Title }).
+		Field("body", func() string { t.Errorf("Unexpected call of the body getter\n"); return book.Body }).
+		Build()
+	if !QueryParser("title:merry").Search(lazy) {
+		t.Errorf("Expected title:merry to match\n")
+	}
+
+	query, err := QueryParserOptions("title:merry", Options{TreatMissingFieldAsMatch: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(NewFieldSearchable().Field("body", func() string { return book.Body }).Build()) {
+		t.Errorf("Expected a missing title to be reported by HasField\n")
+	}
+
+	// Values are read again for each search, so a Searchable can be reused for the next record
+	book = testBook{Title: "A whale of a time"}
+	if !QueryParser("title:whale").Search(record) {
+		t.Errorf("Expected the changed title to be searched\n")
+	}
+	if QueryParser("merry").Search(record) {
+		t.Errorf("Expected the previous title not to be searched\n")
+	}
+
+	// Each getter is called once per search, however many terms search its field
+	calls := 0
+	counted := NewFieldSearchable().
+		Field("title", func() string { calls++; return book.Title }).
+		Field("body", func() string { return book.Body }).
+		Build()
+	query = QueryParser("title:whale title:time whale NOT title:merry _field_:title")
+	for search := 1; search <= 2; search++ {
+		if !query.Search(counted) {
+			t.Errorf("Expected the query to match\n")
+		}
+		if calls != search {
+			t.Errorf("Expected %v calls of the title getter after %v searches, got %v\n", search, search, calls)
+		}
+	}
+	if query.Score(counted); calls != 3 {
+		t.Errorf("Expected a single call of the title getter for Score, got %v\n", calls-2)
+	}
+}
+
+// testReflectRecord searches the fields of a struct found using reflection, for comparison with FieldSearchableBuilder
+type testReflectRecord struct {
+	value reflect.Value
+}
+
+func (r testReflectRecord) Contains(field, phrase string) (present bool) {
+	for i := 0; i < r.value.NumField(); i++ {
+		name := strings.ToLower(r.value.Type().Field(i).Name)
+		if (field == "" || field == name) && strings.Contains(r.value.Field(i).String(), phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func BenchmarkFieldSearchable(b *testing.B) {
+	book := testBook{Title: "Once upon a very merry time", Body: "A beetle battle fought in a bottle"}
+	query := QueryParser("body:bottle merry NOT title:whale")
+	record := NewFieldSearchable().
+		Field("title", func() string { return book.Title }).
+		Field("body", func() string { return book.Body }).
+		Build()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !query.Search(record) {
+			b.Fatalf("Expected the query to match\n")
+		}
+	}
+}
+
+func BenchmarkReflectSearchable(b *testing.B) {
+	book := testBook{Title: "Once upon a very merry time", Body: "A beetle battle fought in a bottle"}
+	query := QueryParser("body:bottle merry NOT title:whale")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !query.Search(testReflectRecord{reflect.ValueOf(book)}) {
+			b.Fatalf("Expected the query to match\n")
+		}
+	}
+}
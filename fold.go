@@ -0,0 +1,64 @@
+package search
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+ContainsLower is a MatchFunc that ignores case, reporting phrase as found in
+text when both are the same after strings.ToLower.
+*/
+func ContainsLower(text, phrase string) (found bool) {
+	// Each character is lowered as it is compared, rather than copying text and phrase
+	for pos := range text {
+		if lowerPrefix(text[pos:], phrase) {
+			return true
+		}
+	}
+	return phrase == ""
+}
+
+// lowerEqual returns true if a and b are the same once both are lower case
+func lowerEqual(a, b string) bool {
+	return lowerPrefix(a, b) && utf8.RuneCountInString(a) == utf8.RuneCountInString(b)
+}
+
+// lowerPrefix returns true if text starts with phrase once both are lower case
+func lowerPrefix(text, phrase string) bool {
+	for _, want := range phrase {
+		char, size := utf8.DecodeRuneInString(text)
+		if size == 0 || unicode.ToLower(char) != unicode.ToLower(want) {
+			return false
+		}
+		text = text[size:]
+	}
+	return true
+}
+
+/*
+FoldSearchable is an optional interface for Searchable objects that can
+ignore case when matching, used when Options.CaseInsensitive is set.
+
+Searchable objects that implement MatchSearchable are given a MatchFunc that
+ignores case, so only need FoldSearchable if they match in some other way.
+*/
+type FoldSearchable interface {
+	Searchable
+	/*
+		ContainsFold returns true if the phrase is present in the object,
+		ignoring case, optionally restricted to the given field.
+	*/
+	ContainsFold(field, phrase string) (present bool)
+}
+
+// mustContainFold returns true if the Searchable matches the field and phrase ignoring case,
+// preferring ContainsFold to the match func when the Searchable has it
+func mustContainFold(field, phrase string, match MatchFunc) filter {
+	return func(s Searchable) bool {
+		if fs, ok := s.(FoldSearchable); ok {
+			return fs.ContainsFold(field, phrase)
+		}
+		return contains(s, field, phrase, match)
+	}
+}
@@ -0,0 +1,78 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+// testFoldRecord only supports case insensitive matching through ContainsFold
+type testFoldRecord struct {
+	Title string
+}
+
+func (r testFoldRecord) Contains(field, phrase string) (present bool) {
+	return strings.Contains(r.Title, phrase)
+}
+
+func (r testFoldRecord) ContainsFold(field, phrase string) (present bool) {
+	return strings.Contains(strings.ToLower(r.Title), strings.ToLower(phrase))
+}
+
+func TestContainsLower(t *testing.T) {
+	for _, test := range []struct {
+		Text   string
+		Phrase string
+		Found  bool
+	}{
+		{"Merry Time", "merry", true},
+		{"merry time", "MERRY", true},
+		{"ÉCOLE", "école", true},
+		{"merry time", "battle", false},
+		{"", "", true},
+	} {
+		if found := ContainsLower(test.Text, test.Phrase); found != test.Found {
+			t.Errorf("Expected %v for %v in %v, got %v\n", test.Found, test.Phrase, test.Text, found)
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	opts := Options{CaseInsensitive: true}
+	for _, test := range []struct {
+		Condition string
+		Record    Searchable
+		Default   bool
+		Fold      bool
+	}{
+		{"Merry", SearchableMatchString("a merry time"), false, true},
+		{"NOT Merry", SearchableMatchString("a merry time"), true, false},
+		{"MERRY OR whale", SearchableMatchStringSlice([]string{"whale", "Merry"}), true, true},
+		{"merry", testFoldRecord{"A Merry Time"}, false, true},
+		{"NOT merry", testFoldRecord{"A Merry Time"}, true, false},
+		{"title:merry", SearchableTypedRow(map[string]interface{}{"title": "Merry"}), false, true},
+		// Searchables without ContainsMatch or ContainsFold are asked as usual
+		{"Merry", testFieldMaterial, false, false},
+	} {
+		if result := QueryParser(test.Condition).Search(test.Record); result != test.Default {
+			t.Errorf("Expected %v for %v by default, got %v\n", test.Default, test.Condition, result)
+		}
+		query, err := QueryParserOptions(test.Condition, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if result := query.Search(test.Record); result != test.Fold {
+			t.Errorf("Expected %v for %v with CaseInsensitive, got %v\n", test.Fold, test.Condition, result)
+		}
+	}
+
+	query, err := QueryParserOptions("👩 Merry", Options{CaseInsensitive: true, GraphemeAware: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(SearchableMatchString("MERRY 👩")) {
+		t.Errorf("Expected case to be ignored with GraphemeAware\n")
+	}
+	if query.Search(SearchableMatchString("MERRY 👨‍👩‍👧")) {
+		t.Errorf("Expected graphemes to be respected with CaseInsensitive\n")
+	}
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+/*
+BoolOp is the way several values of a field are combined, see
+Options.FieldMultiValueMode.
+*/
+type BoolOp int
+
+const (
+	// BoolOr matches records that contain any of the values
+	BoolOr BoolOp = iota
+	// BoolAnd matches records that contain all of the values
+	BoolAnd
+)
+
+/*
+QueryFromForm builds a Query from the inputs of an advanced search form, such
+as url.Values, without writing out a query string.
+
+Each key is a field and each of its values is a phrase that must be present
+in that field.  Where a field has several values any of them may match, so
+{"tag": {"book", "leaflet"}, "title": {"merry time"}} is the same as the query
+`tag:(book,leaflet) title:"merry time"`.  Values are searched for as written,
+without looking for operators, and empty values are ignored.  The key "" is
+used for phrases that may be in any field.
+
+The query has no source text, so the spans of its nodes are all zero.
+*/
+func QueryFromForm(form map[string][]string) Query {
+	return QueryFromFormOptions(form, Options{})
+}
+
+/*
+QueryFromFormOptions builds a Query from the inputs of a form in the same way
+as QueryFromForm, using the given Options.
+
+Options.FieldMultiValueMode chooses whether the values of each field are
+combined with OR or AND, so with a mode of BoolAnd for "body" the form
+{"tag": {"book", "leaflet"}, "body": {"whale", "boat"}} is the same as the
+query `tag:(book,leaflet) body:whale body:boat`.
+*/
+func QueryFromFormOptions(form map[string][]string, opts Options) Query {
+	fields := make([]string, 0, len(form))
+	for field := range form {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	root := &AndNode{}
+	for _, field := range fields {
+		var terms []Node
+		for _, value := range form[field] {
+			if value = strings.TrimSpace(value); value != "" {
+				terms = append(terms, &Term{Field: field, Phrase: value})
+			}
+		}
+		switch {
+		case len(terms) == 0:
+		case len(terms) == 1 || opts.FieldMultiValueMode[field] == BoolAnd:
+			root.Children = append(root.Children, terms...)
+		default:
+			root.Children = append(root.Children, newOrNode(terms...))
+		}
+	}
+	return newQuery(root, opts)
+}
+
+/*
+QueryFromLines builds a Query that requires every non-empty line of text to be
+present, such as a list of phrases pasted into a bulk search.
+
+Each line is searched for as a single phrase, in any field, without looking
+for operators, so "merry time\nNOT whale" is the same as the query
+`"merry time" "NOT whale"`.  Spaces at the start and end of lines are
+ignored.  The spans of the terms are the byte offsets of their lines in text.
+*/
+func QueryFromLines(text string) Query {
+	root := &AndNode{End: len(text)}
+	for start := 0; start < len(text); {
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		line := text[start:end]
+		if phrase := strings.TrimSpace(line); phrase != "" {
+			phraseStart := start + strings.Index(line, phrase)
+			root.Children = append(root.Children, &Term{Phrase: phrase, Start: phraseStart, End: phraseStart + len(phrase)})
+		}
+		start = end + 1
+	}
+	return newQuery(root, Options{})
+}
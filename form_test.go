@@ -0,0 +1,97 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestQueryFromForm(t *testing.T) {
+	q := QueryFromForm(map[string][]string{
+		"title": {"merry time"},
+		"body":  {"whale", "", "battle fought"},
+		"tag":   {" "},
+	})
+	root := q.(*query).root
+	if len(root.Children) != 2 {
+		t.Fatalf("Expected 2 nodes, got %v\n", len(root.Children))
+	}
+	or, ok := root.Children[0].(*OrNode)
+	if !ok || len(or.Children) != 2 {
+		t.Fatalf("Expected an OR of the two body values, got %#v\n", root.Children[0])
+	}
+	if term := or.Children[1].(*Term); term.Field != "body" || term.Phrase != "battle fought" {
+		t.Errorf("Expected body:\"battle fought\", got %v:%v\n", term.Field, term.Phrase)
+	}
+
+	if !q.Search(testFieldMaterial) {
+		t.Errorf("Expected the form query to match\n")
+	}
+	if QueryFromForm(map[string][]string{"title": {"merry time"}, "body": {"whale"}}).Search(testFieldMaterial) {
+		t.Errorf("Expected a form query with no body match not to match\n")
+	}
+	if !QueryFromForm(map[string][]string{"": {"beetle", "NOT"}}).Search(testFieldMaterial) {
+		t.Errorf("Expected values to be searched for in any field without operators\n")
+	}
+	if !QueryFromForm(nil).Search(testFieldMaterial) {
+		t.Errorf("Expected an empty form to match everything\n")
+	}
+}
+
+func TestQueryFromLines(t *testing.T) {
+	record := SearchableString("A merry time was had, and NOT a whale was seen by the OR boat")
+	for _, test := range []struct {
+		Lines string
+		Match bool
+	}{
+		{"merry time\nwhale\nOR boat", true},
+		{"merry time\nwhale\nsubmarine", false},
+		{"merry time\n\n  whale  \r\nNOT a whale\n", true},
+		{"time merry\nwhale\nOR boat", false},
+		{"whale\n(merry", false},
+		{"", true},
+	} {
+		if result := QueryFromLines(test.Lines).Search(record); result != test.Match {
+			t.Errorf("Expected %v for %q, got %v\n", test.Match, test.Lines, result)
+		}
+	}
+
+	root := QueryFromLines("merry time\n  whale\n").(*query).root
+	if len(root.Children) != 2 {
+		t.Fatalf("Expected 2 terms, got %v\n", len(root.Children))
+	}
+	if term := root.Children[1].(*Term); term.Phrase != "whale" || term.Start != 13 || term.End != 18 {
+		t.Errorf("Expected whale at 13-18, got %v at %v-%v\n", term.Phrase, term.Start, term.End)
+	}
+}
+
+func TestQueryFromFormOptions(t *testing.T) {
+	opts := Options{FieldMultiValueMode: map[string]BoolOp{"body": BoolAnd, "title": BoolOr}}
+	form := map[string][]string{
+		"title": {"whale", "merry"},
+		"body":  {"beetle", "bottle"},
+	}
+	q := QueryFromFormOptions(form, opts)
+	root := q.(*query).root
+	if len(root.Children) != 3 {
+		t.Fatalf("Expected 2 body terms and an OR of the titles, got %v nodes\n", len(root.Children))
+	}
+	if _, ok := root.Children[2].(*OrNode); !ok {
+		t.Errorf("Expected the title values to be ORed, got %#v\n", root.Children[2])
+	}
+	for _, test := range []struct {
+		Form  map[string][]string
+		Match bool
+	}{
+		{form, true},
+		{map[string][]string{"title": {"whale", "merry"}, "body": {"beetle", "whale"}}, false},
+		{map[string][]string{"title": {"whale", "frog"}, "body": {"beetle"}}, false},
+		{map[string][]string{"tag": {"frog"}, "body": {"beetle", "battle", "fought"}}, false},
+		{map[string][]string{"": {"merry", "frog"}}, true},
+	} {
+		if result := QueryFromFormOptions(test.Form, opts).Search(testFieldMaterial); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Form, result)
+		}
+	}
+	if !QueryFromForm(map[string][]string{"body": {"beetle", "whale"}}).Search(testFieldMaterial) {
+		t.Errorf("Expected values to be ORed by default\n")
+	}
+}
@@ -0,0 +1,268 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// formatNode writes n as query text that parses back to an equivalent node.
+// Groups are bracketed unless they are the top of the query, and operators are written with the keywords kw.
+// NOTs are written so that they apply to the same terms when parsed with the scope notScope.
+func formatNode(b *strings.Builder, n Node, top bool, kw operatorWords, notScope NotScope) {
+	switch n := n.(type) {
+	case *Term:
+		formatTerm(b, n, kw)
+	case *AndNode:
+		if !top {
+			b.WriteByte('(')
+		}
+		for i, child := range n.Children {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			formatNode(b, child, false, kw, notScope)
+		}
+		if !top {
+			b.WriteByte(')')
+		}
+	case *OrNode:
+		for i, child := range n.Children {
+			if i > 0 {
+				b.WriteString(" " + kw.or + " ")
+			}
+			if term, ok := child.(*Term); ok && term.Near != nil && !term.Optional {
+				// The phrases of a NEAR would otherwise be read as joining the OR, as in (boat NEAR/1 whale) OR shark
+				child = &AndNode{Children: []Node{child}}
+			}
+			formatNode(b, child, false, kw, notScope)
+		}
+	case *NotNode:
+		if term, ok := missingTerm(n); ok {
+			// Written as _missing_:thumbnail so that a NOT before it, as in NOT _missing_:thumbnail, is kept
+			b.WriteString(missingField)
+			b.WriteByte(':')
+			formatQuoted(b, term.Phrase, kw)
+			return
+		}
+		if notScope == NotRestOfClause {
+			// A NOT keyword would also apply to the terms after this one, while a sign or a group does not
+			if term, ok := n.Child.(*Term); ok && term.Kind != TermNear && term.Kind != TermNearOrdered {
+				var text strings.Builder
+				formatTerm(&text, term, kw)
+				if negated := "-" + text.String(); signPrefix(negated) == '-' {
+					// A sign is not read as one before a digit or another sign, as in -5
+					b.WriteString(negated)
+					return
+				}
+			}
+			b.WriteString(kw.not + " ")
+			if _, isGroup := n.Child.(*AndNode); !isGroup {
+				formatNode(b, &AndNode{Children: []Node{n.Child}}, false, kw, notScope)
+				return
+			}
+			formatNode(b, n.Child, false, kw, notScope)
+			return
+		}
+		b.WriteString(kw.not + " ")
+		_, missing := missingTerm(n.Child)
+		switch n.Child.(type) {
+		case *OrNode, *NotNode:
+			if !missing {
+				// NOT applies to a single term or group, so an OR such as NOT tag:(book,leaflet) is bracketed,
+				// as is a second NOT which would otherwise be read as part of the first
+				formatNode(b, &AndNode{Children: []Node{n.Child}}, false, kw, notScope)
+				return
+			}
+		}
+		formatNode(b, n.Child, false, kw, notScope)
+	}
+}
+
+// missingTerm returns the _exists_ term of a node written as _missing_:thumbnail, a NOT of a term that is not optional
+func missingTerm(n Node) (term *Term, ok bool) {
+	not, ok := n.(*NotNode)
+	if !ok {
+		return nil, false
+	}
+	term, ok = not.Child.(*Term)
+	return term, ok && term.Kind == TermFieldExists && !term.Optional
+}
+
+// formatTerm writes the term with its field, comparison, optional marker and boost, quoting the phrase if needed
+func formatTerm(b *strings.Builder, term *Term, kw operatorWords) {
+	if term.Optional && (term.Kind == TermNear || term.Kind == TermNearOrdered) {
+		// A ? before the first phrase would stop it joining the next, so the group is marked instead, as in ?(boat NEAR/1 whale)
+		required := *term
+		required.Optional = false
+		b.WriteString("?(")
+		formatTerm(b, &required, kw)
+		b.WriteByte(')')
+		return
+	}
+	if term.Optional {
+		b.WriteByte('?')
+	}
+	if term.Boost != 0 {
+		defer func() {
+			b.WriteByte(boostMark)
+			b.WriteString(strconv.FormatFloat(term.Boost, 'f', -1, 64))
+		}()
+	}
+	switch {
+	case term.Kind == TermFieldName:
+		b.WriteString(fieldNameField)
+		b.WriteByte(':')
+	case term.Kind == TermFieldExists:
+		b.WriteString(existsField)
+		b.WriteByte(':')
+	case term.Field != "":
+		formatField(b, term.Field, kw)
+		b.WriteByte(':')
+	}
+	if term.Kind == TermPrefix {
+		b.WriteString(prefixOp)
+	}
+	if term.Kind == TermRange || term.Kind == TermDateRange {
+		// The range is kept as written, such as [5 TO 20]
+		b.WriteString(term.Phrase)
+		return
+	}
+	b.WriteString(term.Op.String())
+	switch term.Kind {
+	case TermNear, TermNearOrdered:
+		operator := nearOperator
+		if term.Kind == TermNearOrdered {
+			operator = orderedNearOperator
+		}
+		formatPhrase(b, term.Phrase, term.Field == "", kw)
+		for next := term.Near; next != nil; next = next.Near {
+			b.WriteString(" " + operator + strconv.Itoa(term.Distance) + " ")
+			formatTerm(b, next, kw)
+		}
+		return
+	case TermWildcard:
+		formatMarked(b, term.Phrase, string(wildcard), term.Field == "", kw)
+		return
+	case TermRegex:
+		b.WriteByte('/')
+		b.WriteString(term.Phrase)
+		b.WriteByte('/')
+		return
+	case TermFuzzy:
+		formatMarked(b, term.Phrase, string(fuzzyMark)+strconv.Itoa(term.Distance), term.Field == "", kw)
+		return
+	}
+	if term.Kind == TermContains {
+		formatPhrase(b, term.Phrase, term.Field == "", kw)
+		return
+	}
+	formatQuoted(b, term.Phrase, kw)
+}
+
+// formatQuoted writes the phrase of a term other than a plain phrase, quoting it if needed.
+// Escapes would stop such terms being read back, so only plain phrases have them.
+func formatQuoted(b *strings.Builder, phrase string, kw operatorWords) {
+	if needsQuotes(phrase, kw) {
+		b.WriteByte('"')
+		b.WriteString(phrase)
+		b.WriteByte('"')
+	} else {
+		b.WriteString(phrase)
+	}
+}
+
+// formatField writes the name of a field, quoting it if it holds characters that would otherwise end it, as in "release date",
+// a ! that would be read as part of a negated separator such as tag!:draft, or a leading +, - or ? that would be read as
+// applying to the term, as in "-tag":draft
+func formatField(b *strings.Builder, field string, kw operatorWords) {
+	negatable := strings.HasSuffix(field, "!") || strings.Contains(field, "!=")
+	if !negatable && !strings.ContainsAny(field[:1], "+-?") && !needsQuotes(field, kw) &&
+		strings.IndexFunc(field, func(char rune) bool { return char == ':' || isQuote(char) }) < 0 {
+		b.WriteString(field)
+		return
+	}
+	writeQuoted(b, field)
+}
+
+// formatMarked writes the phrase of a fuzzy or wildcard term followed by its mark, as in whale~1 or boa*, quoting the phrase
+// as in "it's beetle"~2 if it would not otherwise be read back as the same term
+func formatMarked(b *strings.Builder, phrase, mark string, bare bool, kw operatorWords) {
+	if needsQuotes(phrase, kw) || escapePhrase(phrase, bare) != phrase {
+		writeQuoted(b, phrase)
+	} else {
+		b.WriteString(phrase)
+	}
+	b.WriteString(mark)
+}
+
+// writeQuoted writes the text in double quotes, escaping any backslashes and quotes within it
+func writeQuoted(b *strings.Builder, text string) {
+	b.WriteByte('"')
+	for _, char := range text {
+		if char == '\\' || isQuote(char) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(char)
+	}
+	b.WriteByte('"')
+}
+
+// formatPhrase writes a plain phrase, escaping and quoting it as needed, with bare set if it has no field
+func formatPhrase(b *strings.Builder, phrase string, bare bool, kw operatorWords) {
+	escaped := escapePhrase(phrase, bare)
+	if bare && len(escaped) == 1 {
+		// A single character on its own is dropped by the lexer, so it is escaped as in \5 or \( to be kept
+		b.WriteByte('\\')
+		b.WriteString(escaped)
+		return
+	}
+	if needsQuotes(phrase, kw) {
+		b.WriteByte('"')
+		b.WriteString(escaped)
+		b.WriteByte('"')
+	} else {
+		b.WriteString(escaped)
+	}
+}
+
+// escapePhrase adds backslashes before the characters of the phrase that would otherwise be read as part of the query:
+// backslashes, quotes, a trailing * and a ~ or ^ that would make the phrase fuzzy or boosted, for a phrase without a field any colon, the = of !=
+// and a leading +, - or ?, and for the value of a field a leading character that would start a value list, comparison or range
+func escapePhrase(phrase string, bare bool) string {
+	fuzzyAt := -1
+	if _, _, isFuzzy := splitFuzzy(phrase); isFuzzy {
+		fuzzyAt = strings.LastIndexByte(phrase, fuzzyMark)
+	}
+	boostAt := -1
+	if _, _, isBoosted := splitBoost(phrase); isBoosted {
+		boostAt = strings.LastIndexByte(phrase, boostMark)
+	}
+	var b strings.Builder
+	for i, char := range phrase {
+		special := char == '\\' || isQuote(char) || i == fuzzyAt || i == boostAt ||
+			char == wildcard && i == len(phrase)-1 && i > 0 ||
+			bare && (char == ':' || char == '=' && i > 1 && phrase[i-1] == '!' || i == 0 && strings.ContainsRune("+-?", char)) ||
+			!bare && i == 0 && strings.ContainsRune("(<>^[{", char)
+		if special {
+			if b.Len() == 0 {
+				b.WriteString(phrase[:i])
+			}
+			b.WriteByte('\\')
+		}
+		if special || b.Len() > 0 {
+			b.WriteRune(char)
+		}
+	}
+	if b.Len() == 0 {
+		return phrase
+	}
+	return b.String()
+}
+
+// needsQuotes returns true if the phrase would not be read back as a single phrase without quotes
+func needsQuotes(phrase string, kw operatorWords) bool {
+	return kw.has(phrase) || strings.IndexFunc(phrase, func(char rune) bool {
+		return unicode.IsSpace(char) || char == '(' || char == ')'
+	}) >= 0
+}
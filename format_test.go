@@ -0,0 +1,100 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryString(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Text      string
+	}{
+		{"boat   whale OR shark", "boat whale OR shark"},
+		{`title:"merry time" NOT (frog battle)`, `title:"merry time" NOT (frog battle)`},
+		{"boat (whale OR shark) OR (frog fought)", "boat whale OR shark OR (frog fought)"},
+		{"tag:(book,leaflet) whale", "tag:book OR tag:leaflet whale"},
+		{"NOT tag:(book,leaflet)", "NOT (tag:book OR tag:leaflet)"},
+		{`created:>2020-01-01 size:<=1MB ?tag:featured _field_:ti*`, `created:>2020-01-01 size:<=1MB ?tag:featured _field_:ti*`},
+		{`"merry OR"`, `"merry OR"`},
+		{`title:(merry OR battle)`, `title:merry OR title:battle`},
+		{`"it's beetle"~ boa* title:"boa con"*`, `"it\'s beetle"~2 boa* title:"boa con"*`},
+		{`whale~1 "OR"~1 merry~1~1`, `whale~1 "OR"~1 "merry~1"~1`},
+		{`tag:book^2 "floating boat"^1.5 ?whale^3 title:"a b"^2 boa*^2`, `tag:book^2 "floating boat"^1.5 ?whale^3 title:"a b"^2 boa*^2`},
+		{`book\^2 title:^="Once" x^0 y^`, `book\^2 title:^=Once x^0 y^`},
+		{"-a +a ?a whale OR -b", "whale"},
+		{"", ""},
+	} {
+		if text := QueryParser(test.Condition).String(); text != test.Text {
+			t.Errorf("Expected %v for %v, got %v\n", test.Text, test.Condition, text)
+		}
+	}
+}
+
+func TestQueryStringRoundTrip(t *testing.T) {
+	for _, test := range testCases {
+		text := QueryParser(test.Condition).String()
+		reparsed := QueryParser(text)
+		if result := reparsed.Search(test.Records); result != test.Result {
+			t.Errorf("Expected %v for %v, written as %v, got %v\n", test.Result, test.Condition, text, result)
+		}
+		if again := reparsed.String(); again != text {
+			t.Errorf("Expected %v to be unchanged when reparsed, got %v\n", text, again)
+		}
+	}
+
+	// Single characters and fields starting with a sign are written so that they are read back as the same term
+	for _, test := range []struct {
+		Condition string
+		Text      string
+	}{
+		{`\5`, `\5`},
+		{`\@ whale`, `\@ whale`},
+		{`\( OR \)`, `\( OR \)`},
+		{`-\5`, `NOT \5`},
+		{`'-_exists_':x`, `"-_exists_":x`},
+		{`'+tag':x '?tag':y`, `"+tag":x "?tag":y`},
+	} {
+		q := QueryParser(test.Condition)
+		text := q.String()
+		if text != test.Text {
+			t.Errorf("Expected %v for %v, got %v\n", test.Text, test.Condition, text)
+		}
+		if !reflect.DeepEqual(Canonicalize(QueryParser(text)).(*query).root, Canonicalize(q).(*query).root) {
+			t.Errorf("Expected %v to be read back as the same query as %v\n", text, test.Condition)
+		}
+	}
+}
+
+func TestQueryStringNotScope(t *testing.T) {
+	// With NotRestOfClause a NOT keyword would take in the terms after it, so a single negated term is written with a sign
+	opts := Options{NotScope: NotRestOfClause}
+	for _, test := range []struct {
+		Condition string
+		Text      string
+	}{
+		{"-aa bb", "-aa bb"},
+		{"tag!:aa bb", "-tag:aa bb"},
+		{"NOT aa bb", "-aa -bb"},
+		{"NOT 55 bb", "NOT (55) -bb"},
+		{"NOT (aa OR cc) bb", "NOT (aa OR cc) bb"},
+	} {
+		q, err := QueryParserOptions(test.Condition, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		text := q.String()
+		if text != test.Text {
+			t.Errorf("Expected %v for %v, got %v\n", test.Text, test.Condition, text)
+		}
+		reparsed, err := QueryParserOptions(text, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", text, err)
+		}
+		for _, record := range []string{"aa", "bb", "tag aa bb", "55 cc"} {
+			if reparsed.MatchString(record) != q.MatchString(record) {
+				t.Errorf("Expected %v to match %q as %v does\n", text, record, test.Condition)
+			}
+		}
+	}
+}
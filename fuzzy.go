@@ -0,0 +1,177 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fuzzyMark ends an unquoted term or follows a quoted one to match words within an edit distance, as in whale~1 or "it's"~1
+const fuzzyMark = '~'
+
+// defaultFuzzyDistance is the edit distance of a term ending in a bare ~, such as whale~
+const defaultFuzzyDistance = 2
+
+/*
+FuzzyMatchable is an optional interface for Searchable objects that can find
+words that are close to a phrase, used for terms such as `whale~1` that match
+`whales` or `wbale`.  A quoted phrase may be followed by the ~ as well, as in
+`"it's"~1`.
+
+Searchable objects that implement MatchSearchable, such as SearchableMatchString
+and SearchableMatchStringSlice, are instead given a MatchFunc made by FuzzyMatch, so
+only need FuzzyMatchable if they match in some other way.  Others, including
+SearchableString and SearchableStringSlice, are asked using Contains, so only
+the exact phrase matches; use SearchableMatchString or
+SearchableMatchStringSlice to search strings with fuzzy terms.
+*/
+type FuzzyMatchable interface {
+	Searchable
+	/*
+		FuzzyContains returns true if the named field has words within
+		maxDistance edits of the phrase.  A field of "" means any field.
+	*/
+	FuzzyContains(field, phrase string, maxDistance int) (present bool)
+}
+
+/*
+Levenshtein returns the number of single character insertions, deletions and
+substitutions needed to turn a into b.  Characters are runes, so "café" and
+"cafe" are one substitution apart.
+*/
+func Levenshtein(a, b string) (distance int) {
+	return levenshtein(a, b, false)
+}
+
+// levenshtein returns the Levenshtein distance, comparing characters in lower case if lower is set
+func levenshtein(a, b string, lower bool) (distance int) {
+	if a == b {
+		return 0
+	}
+	// Short words, which are the most common, are measured without allocating
+	var targetBuffer [32]rune
+	var rowBuffer [2 * (len(targetBuffer) + 1)]int
+	target := targetBuffer[:0]
+	for _, char := range b {
+		if lower {
+			char = unicode.ToLower(char)
+		}
+		target = append(target, char)
+	}
+	var previous, current []int
+	if len(target) < len(targetBuffer) {
+		previous, current = rowBuffer[:len(target)+1], rowBuffer[len(target)+1:2*(len(target)+1)]
+	} else {
+		previous, current = make([]int, len(target)+1), make([]int, len(target)+1)
+	}
+	for j := range previous {
+		previous[j] = j
+	}
+	i := 0
+	for _, char := range a {
+		if lower {
+			char = unicode.ToLower(char)
+		}
+		i++
+		current[0] = i
+		for j, other := range target {
+			cost := 1
+			if char == other {
+				cost = 0
+			}
+			current[j+1] = min3(previous[j+1]+1, current[j]+1, previous[j]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
+
+// min3 returns the smallest of three numbers
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+/*
+FuzzyMatch returns a MatchFunc that reports phrase as found in text when the
+words of text include words within maxDistance edits of it, as measured by
+Levenshtein.  Words are found as they are by ContainsWholeWords, and for a
+phrase of several words the edits to each word are added together.  A phrase
+without any letters or digits is matched using strings.Contains.
+*/
+func FuzzyMatch(maxDistance int) MatchFunc {
+	return fuzzyMatch(maxDistance, false)
+}
+
+// fuzzyMatch returns the MatchFunc of FuzzyMatch, ignoring case if lower is set
+func fuzzyMatch(maxDistance int, lower bool) MatchFunc {
+	return func(text, phrase string) bool {
+		if start, _ := nextWord(phrase, 0); start < 0 && lower {
+			return ContainsLower(text, phrase)
+		} else if start < 0 {
+			return strings.Contains(text, phrase)
+		}
+		for start, end := nextWord(text, 0); start >= 0; start, end = nextWord(text, end) {
+			if wordsNearFrom(text, start, phrase, maxDistance, lower) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// wordsNearFrom returns true if the words of text starting at pos are within maxDistance edits of the words of phrase
+func wordsNearFrom(text string, pos int, phrase string, maxDistance int, lower bool) bool {
+	distance := 0
+	for phraseStart, phraseEnd := nextWord(phrase, 0); phraseStart >= 0; phraseStart, phraseEnd = nextWord(phrase, phraseEnd) {
+		start, end := nextWord(text, pos)
+		if start < 0 {
+			return false
+		}
+		word, want := text[start:end], phrase[phraseStart:phraseEnd]
+		// Words whose lengths differ by more than the edits left can't be close enough
+		if diff := utf8.RuneCountInString(word) - utf8.RuneCountInString(want); diff > maxDistance-distance || -diff > maxDistance-distance {
+			return false
+		}
+		if distance += levenshtein(word, want, lower); distance > maxDistance {
+			return false
+		}
+		pos = end
+	}
+	return true
+}
+
+// splitFuzzy separates the phrase and edit distance of an unquoted value such as whale~1, ok is false if it is not fuzzy
+func splitFuzzy(value string) (phrase string, distance int, ok bool) {
+	mark := strings.LastIndexByte(value, fuzzyMark)
+	if mark <= 0 {
+		return "", 0, false
+	}
+	if mark == len(value)-1 {
+		return value[:mark], defaultFuzzyDistance, true
+	}
+	distance, err := strconv.Atoi(value[mark+1:])
+	if err != nil || distance < 0 || value[mark+1] == '+' || value[mark+1] == '-' {
+		return "", 0, false
+	}
+	return value[:mark], distance, true
+}
+
+// mustBeNear returns true if the Searchable has words within distance edits of the phrase
+func mustBeNear(field, phrase string, distance int, fuzzy MatchFunc) filter {
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case FuzzyMatchable:
+			return s.FuzzyContains(field, phrase, distance)
+		case MatchSearchable:
+			return s.ContainsMatch(field, phrase, fuzzy)
+		}
+		return s.Contains(field, phrase)
+	}
+}
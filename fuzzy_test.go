@@ -0,0 +1,121 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+// testFuzzyRecord finds close words through FuzzyContains, only checking the first word of its title
+type testFuzzyRecord struct {
+	Title string
+}
+
+func (r testFuzzyRecord) Contains(field, phrase string) (present bool) {
+	return strings.Contains(r.Title, phrase)
+}
+
+func (r testFuzzyRecord) FuzzyContains(field, phrase string, maxDistance int) (present bool) {
+	return Levenshtein(strings.Fields(r.Title)[0], phrase) <= maxDistance
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, test := range []struct {
+		A, B     string
+		Distance int
+	}{
+		{"whale", "whale", 0},
+		{"whale", "whales", 1},
+		{"whale", "wbale", 1},
+		{"whale", "hwale", 2},
+		{"whale", "", 5},
+		{"", "whale", 5},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+		{"😀😀", "😀", 1},
+	} {
+		if distance := Levenshtein(test.A, test.B); distance != test.Distance {
+			t.Errorf("Expected a distance of %v from %v to %v, got %v\n", test.Distance, test.A, test.B, distance)
+		}
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Title     string
+		Match     bool
+		Fuzzy     bool
+	}{
+		{"whale~1", "whales in the sea", true, true},
+		{"whale~1", "a wbale in the sea", true, false},
+		{"whale~1", "some wbales in the sea", false, false},
+		{"whale~", "some wbales in the sea", true, false},
+		{"whale~2", "hwale", true, true},
+		{"whale~0", "whales", false, false},
+		{"whale~0", "whale", true, true},
+		{"title:whale~1", "wbale", true, true},
+		{"NOT whale~1", "wbale", false, false},
+		{"whale~1 OR shark", "wbale", true, true},
+		{"merry~1", "very merry", true, false},
+		{`"whale~1"`, "wbale", false, false},
+		{`"whale~1"`, "whale~1", true, true},
+		{"whale~x", "whale~x", true, true},
+		{"whale~-1", "whale~-1", true, true},
+	} {
+		q, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.Search(SearchableMatchString(test.Title)); result != test.Match {
+			t.Errorf("Expected %v for %v in %v, got %v\n", test.Match, test.Condition, test.Title, result)
+		}
+		if result := q.Search(testFuzzyRecord{test.Title}); result != test.Fuzzy {
+			t.Errorf("Expected %v for %v in %v using FuzzyContains, got %v\n", test.Fuzzy, test.Condition, test.Title, result)
+		}
+	}
+
+	// The matching string Searchables match fuzzy terms against their words without further help
+	if !QueryParser("whale~1").Search(SearchableMatchString("wbale")) || !QueryParser("whale~1").Search(SearchableMatchStringSlice([]string{"shark", "a wbale"})) {
+		t.Errorf("Expected whale~1 to find wbale in SearchableMatchString and SearchableMatchStringSlice\n")
+	}
+
+	// SearchableString and SearchableStringSlice can only be asked for the phrase, so fuzzy matching needs the Match variants
+	if QueryParser("whale~1").Search(SearchableString("wbale")) || QueryParser("whale~1").Search(SearchableStringSlice([]string{"shark", "a wbale"})) {
+		t.Errorf("Expected whale~1 not to find wbale in SearchableString and SearchableStringSlice\n")
+	}
+	if !QueryParser("whale~1").Search(SearchableString("a whale")) {
+		t.Errorf("Expected whale~1 to find the exact phrase in SearchableString\n")
+	}
+
+	// Searchables without either interface only match the exact phrase
+	record := SearchableFunc(func(field, phrase string) bool { return phrase == "whale" })
+	if QueryParser("whle~1").Search(record) || !QueryParser("whale~1").Search(record) {
+		t.Errorf("Expected Contains to be asked for the exact phrase\n")
+	}
+
+	q, err := QueryParserOptions("WHALE~1", Options{CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !q.Search(SearchableMatchString("a Wbale")) {
+		t.Errorf("Expected fuzzy matching to ignore case with CaseInsensitive\n")
+	}
+
+	for _, condition := range []string{"whale~1", "whale~", `"whale~1"`, "title:whale~3"} {
+		text := QueryParser(condition).String()
+		again := QueryParser(text).(*query).root.Children[0].(*Term)
+		term := QueryParser(condition).(*query).root.Children[0].(*Term)
+		if again.Kind != term.Kind || again.Phrase != term.Phrase || again.Distance != term.Distance {
+			t.Errorf("Expected %v to be read back from %v\n", condition, text)
+		}
+	}
+}
+
+func BenchmarkFuzzyMatch(b *testing.B) {
+	text := strings.Repeat("a beetle battle fought in a bottle ", 20)
+	match := FuzzyMatch(2)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		match(text, "whale")
+	}
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const zeroWidthJoiner = '\u200d'
+
+/*
+ContainsGraphemes is a MatchFunc that only reports phrase as found in text when
+the match starts and ends on a grapheme cluster boundary.
+
+This stops a query for a single emoji, such as 👩, from matching part of a
+larger cluster, such as the family emoji 👨‍👩‍👧 or a skin toned 👩🏽.
+
+The boundaries are a close approximation of the Unicode extended grapheme
+cluster rules of UAX #29, covering combining marks, variation selectors, emoji
+modifiers, zero width joiner sequences and regional indicator flags.
+golang.org/x/text does not provide grapheme segmentation, so the rules are
+written out here and some are left out: conjoining Hangul jamo, prepended
+characters and Indic conjuncts may be split where UAX #29 would keep them
+together, and Extended_Pictographic is approximated by the symbol category and
+the emoji blocks.  Text that needs the full rules should be segmented before
+it is searched.
+*/
+func ContainsGraphemes(text, phrase string) (found bool) {
+	if phrase == "" {
+		return true
+	}
+	for start := 0; start <= len(text)-len(phrase); {
+		index := strings.Index(text[start:], phrase)
+		if index < 0 {
+			return false
+		}
+		index += start
+		if graphemeBoundary(text, index) && graphemeBoundary(text, index+len(phrase)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[index:])
+		start = index + size
+	}
+	return false
+}
+
+// graphemeBoundary returns true if a grapheme cluster boundary lies at byte offset pos in text
+func graphemeBoundary(text string, pos int) bool {
+	if pos <= 0 || pos >= len(text) {
+		return true
+	}
+	before, _ := utf8.DecodeLastRuneInString(text[:pos])
+	after, _ := utf8.DecodeRuneInString(text[pos:])
+	switch {
+	case before == '\r' && after == '\n':
+		return false
+	case graphemeExtend(after) || after == zeroWidthJoiner:
+		return false
+	case before == zeroWidthJoiner && pictographic(after):
+		return false
+	case regionalIndicator(before) && regionalIndicator(after):
+		// Flags are pairs of regional indicators, so only break after an even number of them
+		count := 0
+		for rest := text[:pos]; rest != ""; count++ {
+			r, size := utf8.DecodeLastRuneInString(rest)
+			if !regionalIndicator(r) {
+				break
+			}
+			rest = rest[:len(rest)-size]
+		}
+		return count%2 == 0
+	}
+	return true
+}
+
+// graphemeExtend returns true for runes that attach to the previous rune in a grapheme cluster
+func graphemeExtend(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F:
+		// Variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		// Emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F:
+		// Tag characters used by subdivision flags
+		return true
+	}
+	return false
+}
+
+// pictographic approximates the Extended_Pictographic property used to join emoji sequences
+func pictographic(r rune) bool {
+	return unicode.Is(unicode.So, r) || (r >= 0x1F000 && r <= 0x1FAFF)
+}
+
+// regionalIndicator returns true for the letters used to build flag emoji
+func regionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,55 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestContainsGraphemes(t *testing.T) {
+	for _, test := range []struct {
+		Text   string
+		Phrase string
+		Found  bool
+	}{
+		{"A family 👨‍👩‍👧 day out", "👨‍👩‍👧", true},
+		{"A family 👨‍👩‍👧 day out", "👩", false},
+		{"A family 👨‍👩‍👧 day out", "👨", false},
+		{"A family 👨‍👩‍👧 day out", "👧", false},
+		{"A family 👨‍👩‍👧 then 👩 alone", "👩", true},
+		{"Thumbs 👍🏽 up", "👍", false},
+		{"Thumbs 👍🏽 up", "👍🏽", true},
+		{"Flags 🇬🇧🇫🇷", "🇧🇫", false},
+		{"Flags 🇬🇧🇫🇷", "🇫🇷", true},
+		{"Cafe\u0301 au lait", "Cafe", false},
+		{"Cafe\u0301 au lait", "Cafe\u0301", true},
+		{"plain text", "text", true},
+		{"plain text", "frog", false},
+	} {
+		if found := ContainsGraphemes(test.Text, test.Phrase); found != test.Found {
+			t.Errorf("ContainsGraphemes(%q, %q) expected %v, got %v\n", test.Text, test.Phrase, test.Found, found)
+		}
+	}
+}
+
+func TestGraphemeAwareOption(t *testing.T) {
+	record := SearchableMatchString("A family 👨‍👩‍👧 day out")
+
+	if !QueryParser("family 👩").Search(record) {
+		t.Errorf("Expected component emoji to match without GraphemeAware\n")
+	}
+
+	query, err := QueryParserOptions("family 👩", Options{GraphemeAware: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if query.Search(record) {
+		t.Errorf("Expected component emoji not to match with GraphemeAware\n")
+	}
+
+	query, err = QueryParserOptions("family 👨‍👩‍👧", Options{GraphemeAware: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(record) {
+		t.Errorf("Expected whole emoji to match with GraphemeAware\n")
+	}
+}
@@ -0,0 +1,174 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Span is part of a text, given as the byte offsets of its start and end.  The
+end is exclusive.
+*/
+type Span struct {
+	Start, End int
+}
+
+// highlightTerms collects the spans of text matched by each term beneath n that is not negated
+func highlightTerms(n Node, negated bool, text string, opts Options, spans []Span) []Span {
+	switch n := n.(type) {
+	case *Term:
+		if negated {
+			return spans
+		}
+		return append(spans, termSpans(n, text, opts)...)
+	case *NotNode:
+		return highlightTerms(n.Child, !negated, text, opts, spans)
+	}
+	for _, child := range children(n) {
+		spans = highlightTerms(child, negated, text, opts, spans)
+	}
+	return spans
+}
+
+// termSpans returns the spans of text that the term matches.
+// Terms that compare values, such as price:>5, never highlight anything.
+func termSpans(n *Term, text string, opts Options) (spans []Span) {
+	boundary := anywhere
+	switch {
+	case opts.WholeWord:
+		boundary = wordBoundary
+	case opts.GraphemeAware:
+		boundary = graphemeBoundary
+	}
+	switch n.Kind {
+	case TermContains:
+		return phraseSpans(text, n.Phrase, opts.CaseInsensitive, boundary)
+	case TermWildcard:
+		// Each word starting with the phrase is highlighted to its end
+		for _, span := range phraseSpans(text, n.Phrase, opts.CaseInsensitive, anywhere) {
+			if !wordBoundary(text, span.Start) {
+				continue
+			}
+			for span.End < len(text) && !wordBoundary(text, span.End) {
+				_, size := utf8.DecodeRuneInString(text[span.End:])
+				span.End += size
+			}
+			spans = append(spans, span)
+		}
+		return spans
+	case TermFuzzy:
+		return fuzzySpans(text, n.Phrase, n.Distance, opts.CaseInsensitive)
+	case TermNear, TermNearOrdered:
+		phrases, near := nearPhrases(n), text
+		if opts.CaseInsensitive {
+			near = strings.ToLower(text)
+			for i, phrase := range phrases {
+				phrases[i] = strings.ToLower(phrase)
+			}
+		}
+		if !WordsNear(near, phrases, n.Distance, n.Kind == TermNearOrdered) {
+			return nil
+		}
+		for _, phrase := range phrases {
+			spans = append(spans, phraseSpans(text, phrase, opts.CaseInsensitive, wordBoundary)...)
+		}
+		return spans
+	case TermRegex:
+		for _, loc := range n.Regexp.FindAllStringIndex(text, -1) {
+			if loc[0] < loc[1] {
+				spans = append(spans, Span{Start: loc[0], End: loc[1]})
+			}
+		}
+		return spans
+	}
+	return nil
+}
+
+// phraseSpans returns each place phrase is found in text that starts and ends on a boundary, ignoring case if fold is set
+func phraseSpans(text, phrase string, fold bool, boundary func(text string, pos int) bool) (spans []Span) {
+	if phrase == "" {
+		return nil
+	}
+	for start := 0; start+len(phrase) <= len(text); {
+		candidate := text[start : start+len(phrase)]
+		if (candidate == phrase || fold && strings.EqualFold(candidate, phrase)) &&
+			boundary(text, start) && boundary(text, start+len(phrase)) {
+			spans = append(spans, Span{Start: start, End: start + len(phrase)})
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		start += size
+	}
+	return spans
+}
+
+// anywhere is the boundary used when a phrase may start and end at any position
+func anywhere(text string, pos int) bool {
+	return true
+}
+
+// fuzzySpans returns each run of words in text within maxDistance edits of the words of phrase, as FuzzyMatch finds them
+func fuzzySpans(text, phrase string, maxDistance int, fold bool) (spans []Span) {
+	if fold {
+		phrase = strings.ToLower(phrase)
+	}
+	want := strings.FieldsFunc(phrase, notWordChar)
+	if len(want) == 0 {
+		return nil
+	}
+	words := wordSpans(text)
+	for start := 0; start+len(want) <= len(words); start++ {
+		distance := 0
+		for i, word := range want {
+			found := text[words[start+i].Start:words[start+i].End]
+			if fold {
+				found = strings.ToLower(found)
+			}
+			if distance += Levenshtein(found, word); distance > maxDistance {
+				break
+			}
+		}
+		if distance <= maxDistance {
+			spans = append(spans, Span{Start: words[start].Start, End: words[start+len(want)-1].End})
+		}
+	}
+	return spans
+}
+
+// wordSpans returns the spans of the words of text, as strings.FieldsFunc with notWordChar splits them
+func wordSpans(text string) (spans []Span) {
+	start := -1
+	for pos, char := range text {
+		switch {
+		case notWordChar(char) && start >= 0:
+			spans = append(spans, Span{Start: start, End: pos})
+			start = -1
+		case !notWordChar(char) && start < 0:
+			start = pos
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, Span{Start: start, End: len(text)})
+	}
+	return spans
+}
+
+// mergeSpans sorts the spans and merges those that overlap or touch
+func mergeSpans(spans []Span) []Span {
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].Start < spans[j].Start
+	})
+	merged := spans[:1]
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if span.Start > last.End {
+			merged = append(merged, span)
+		} else if span.End > last.End {
+			last.End = span.End
+		}
+	}
+	return merged
+}
@@ -0,0 +1,56 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Opts      Options
+		Text      string
+		Result    string
+	}{
+		{"whale", Options{}, "a whale and another whale", "a [whale] and another [whale]"},
+		{`"merry time"`, Options{}, "a merry time was had", "a [merry time] was had"},
+		{"whale NOT shark", Options{}, "a whale and a shark", "a [whale] and a shark"},
+		{"whale OR shark", Options{}, "a whale and a shark", "a [whale] and a [shark]"},
+		{`"merry time" "time was"`, Options{}, "a merry time was had", "a [merry time was] had"},
+		{"erry merry", Options{}, "a merry time", "a [merry] time"},
+		{"title:whale ?boat", Options{}, "whale boat", "[whale] [boat]"},
+		{"price:>5 whale", Options{}, "6 whales", "6 [whale]s"},
+		{"Whale", Options{}, "a whale", "a whale"},
+		{"Whale", Options{CaseInsensitive: true}, "a WHALE", "a [WHALE]"},
+		{"cat", Options{WholeWord: true}, "the cat in a category", "the [cat] in a category"},
+		{"boa*", Options{}, "a boat, two boats and a rowboat", "a [boat], two [boats] and a rowboat"},
+		{"whale~1", Options{}, "some wbales and a whale", "some wbales and a [whale]"},
+		{"whale~", Options{}, "some wbales and a whale", "some [wbales] and a [whale]"},
+		{"whale NEAR/1 boat", Options{}, "a whale by the boat", "a whale by the boat"},
+		{"whale NEAR/2 boat", Options{}, "a whale by the boat", "a [whale] by the [boat]"},
+		{"whale", Options{}, "", ""},
+	} {
+		q, err := QueryParserOptions(test.Condition, test.Opts)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.HighlightString(test.Text, "[", "]"); result != test.Result {
+			t.Errorf("Expected %v for %v in %v, got %v\n", test.Result, test.Condition, test.Text, result)
+		}
+	}
+}
+
+func TestHighlightSpans(t *testing.T) {
+	spans := QueryParser("whale OR whales").Highlight("two whales")
+	if len(spans) != 1 || spans[0] != (Span{Start: 4, End: 10}) {
+		t.Errorf("Expected overlapping matches to be merged, got %v\n", spans)
+	}
+	if spans := QueryParser("NOT whale").Highlight("a whale"); spans != nil {
+		t.Errorf("Expected no spans for a negated term, got %v\n", spans)
+	}
+}
+
+func TestHighlightRegex(t *testing.T) {
+	if result := QueryParser(`text:/wh?ale|b[aeiou]+t/`).HighlightString("a whale on a boat", "[", "]"); result != "a [whale] on a [boat]" {
+		t.Errorf("Expected the matches of the pattern to be highlighted, got %v\n", result)
+	}
+}
@@ -0,0 +1,17 @@
+package search
+
+// indexableTerms collects the terms beneath n that every matching record must contain and that can be looked up
+// in an index of the given fields.  The branches of an OR are left out, as a record need only match one of them.
+func indexableTerms(n Node, indexed map[string]bool, results []Term) []Term {
+	switch n := n.(type) {
+	case *Term:
+		if !n.Optional && n.Kind == TermContains && indexed[n.Field] {
+			results = append(results, *n)
+		}
+	case *AndNode:
+		for _, child := range n.Children {
+			results = indexableTerms(child, indexed, results)
+		}
+	}
+	return results
+}
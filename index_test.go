@@ -0,0 +1,39 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestIndexableTerms(t *testing.T) {
+	opts := Options{IndexedFields: []string{"tag", "author"}}
+	query, err := QueryParserOptions("boat tag:book (author:jones tag:(leaflet,pamphlet)) NOT author:smith ?tag:guide", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	terms := query.IndexableTerms()
+	expected := []Term{
+		{Field: "tag", Phrase: "book"},
+		{Field: "author", Phrase: "jones"},
+	}
+	if len(terms) != len(expected) {
+		t.Fatalf("Expected %v indexable terms, got %v: %v\n", len(expected), len(terms), terms)
+	}
+	for i, term := range terms {
+		if term.Field != expected[i].Field || term.Phrase != expected[i].Phrase {
+			t.Errorf("Expected %v:%v, got %v:%v\n", expected[i].Field, expected[i].Phrase, term.Field, term.Phrase)
+		}
+	}
+
+	// A record matching title:whale need not have the tag book, so no term narrows the search
+	query, err = QueryParserOptions("tag:book OR title:whale", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if terms := query.IndexableTerms(); len(terms) != 0 {
+		t.Errorf("Expected no indexable terms for alternatives, got %v\n", terms)
+	}
+
+	if terms := QueryParser("tag:book").IndexableTerms(); len(terms) != 0 {
+		t.Errorf("Expected no indexable terms without IndexedFields, got %v\n", terms)
+	}
+}
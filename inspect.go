@@ -0,0 +1,104 @@
+package search
+
+/*
+TokenKind identifies the kinds of Token found in a query.
+*/
+type TokenKind int
+
+const (
+	// TokenPhrase is a word, quoted phrase or field term such as boat, "floating boat" or title:merry
+	TokenPhrase TokenKind = iota
+	// TokenKeyword is one of the operators AND, OR or NOT, a NEAR/5 or ONEAR/5, or the sign before a group such as -(
+	TokenKeyword
+	// TokenOpen is an opening bracket, or the start of a field group such as title:(
+	TokenOpen
+	// TokenClose is a closing bracket
+	TokenClose
+)
+
+/*
+Token is one element of the text of a query, as used for syntax highlighting.
+Start and End are byte offsets into the query, with the span of a quoted
+phrase including its quotes.  Text is the phrase without its quotes, or the
+brackets and field of a group.
+*/
+type Token struct {
+	Kind       TokenKind
+	Text       string
+	Start, End int
+}
+
+/*
+Inspection holds everything known about a query from parsing it, for
+building advanced search interfaces.
+
+Query is the query as QueryParser returns it, and Root is the tree it was
+parsed into.  Err is the first problem found, as returned by QueryParserErr,
+while Diagnostics and Warnings are those of Query.Diagnostics and Lint.
+Fields lists the fields that terms are restricted to, and Keywords the
+result of Query.Keywords(false), both in the order they appear in the query.
+*/
+type Inspection struct {
+	Tokens      []Token
+	Query       Query
+	Root        *AndNode
+	Err         error
+	Diagnostics []*ParseError
+	Warnings    []Warning
+	Fields      []string
+	Keywords    []string
+}
+
+/*
+Inspect parses the query, as QueryParser does, and returns an Inspection of
+it.
+*/
+func Inspect(query string) Inspection {
+	root, err := parseQuery(query, Options{})
+	q := newQuery(root, Options{})
+	return Inspection{
+		Tokens:      tokensOf(query),
+		Query:       q,
+		Root:        q.root,
+		Err:         err,
+		Diagnostics: q.Diagnostics(),
+		Warnings:    Lint(q),
+		Fields:      fieldsOf(q.root),
+		Keywords:    q.Keywords(false),
+	}
+}
+
+// tokensOf returns the tokens found by the lexer in the query
+func tokensOf(query string) (tokens []Token) {
+	l := lex(query)
+	for tok, ok := l.token(); ok; tok, ok = l.token() {
+		switch tok.kind {
+		case tokenPhrase:
+			kind := TokenPhrase
+			if tok.valueStart == tok.start && isKeyword(tok.text) {
+				kind = TokenKeyword
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: tok.text, Start: tok.start, End: tok.end})
+		case tokenOpen:
+			tokens = append(tokens, Token{Kind: TokenOpen, Text: query[tok.start : tok.pos+1], Start: tok.start, End: tok.pos + 1})
+		case tokenClose:
+			tokens = append(tokens, Token{Kind: TokenClose, Text: ")", Start: tok.start, End: tok.end})
+		case tokenSign:
+			tokens = append(tokens, Token{Kind: TokenKeyword, Text: tok.text, Start: tok.start, End: tok.end})
+		}
+	}
+	return tokens
+}
+
+// fieldsOf returns the distinct fields that the terms beneath n are restricted to
+func fieldsOf(n Node) (fields []string) {
+	seen := make(map[string]bool)
+	Walk(n, func(n Node) bool {
+		if term, ok := n.(*Term); ok && term.Field != "" && !seen[term.Field] {
+			seen[term.Field] = true
+			fields = append(fields, term.Field)
+		}
+		return true
+	})
+	return fields
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	inspection := Inspect(`boat AND "merry time" title:(whale OR *fish) NOT tag:book`)
+	expected := []Token{
+		{TokenPhrase, "boat", 0, 4},
+		{TokenKeyword, "AND", 5, 8},
+		{TokenPhrase, "merry time", 9, 21},
+		{TokenOpen, "title:(", 22, 29},
+		{TokenPhrase, "whale", 29, 34},
+		{TokenKeyword, "OR", 35, 37},
+		{TokenPhrase, "*fish", 38, 43},
+		{TokenClose, ")", 43, 44},
+		{TokenKeyword, "NOT", 45, 48},
+		{TokenPhrase, "tag:book", 49, 57},
+	}
+	if !reflect.DeepEqual(inspection.Tokens, expected) {
+		t.Errorf("Expected tokens %v, got %v\n", expected, inspection.Tokens)
+	}
+	if inspection.Err != nil {
+		t.Errorf("Unexpected error: %v\n", inspection.Err)
+	}
+	if len(inspection.Root.Children) != 4 {
+		t.Errorf("Expected 4 nodes, got %v\n", len(inspection.Root.Children))
+	}
+	if !inspection.Query.Search(&testSearchObject{Title: "a whale", Body: "boat merry time"}) {
+		t.Errorf("Expected the query to match\n")
+	}
+	if fields := []string{"title", "tag"}; !reflect.DeepEqual(inspection.Fields, fields) {
+		t.Errorf("Expected fields %v, got %v\n", fields, inspection.Fields)
+	}
+	if keywords := []string{"boat", "merry time"}; !reflect.DeepEqual(inspection.Keywords, keywords) {
+		t.Errorf("Expected keywords %v, got %v\n", keywords, inspection.Keywords)
+	}
+	if len(inspection.Warnings) != 1 || inspection.Warnings[0].Start != 38 {
+		t.Errorf("Expected a warning for the leading wildcard, got %v\n", inspection.Warnings)
+	}
+	if len(inspection.Diagnostics) != 0 {
+		t.Errorf("Unexpected diagnostics: %v\n", inspection.Diagnostics)
+	}
+
+	inspection = Inspect(`(boat "whale`)
+	if perr, ok := inspection.Err.(*ParseError); !ok || perr.Pos != 6 {
+		t.Errorf("Expected a *ParseError at 6, got %v\n", inspection.Err)
+	}
+	if inspection.Query == nil || len(inspection.Tokens) != 3 {
+		t.Errorf("Expected a usable query and tokens for a malformed query, got %v\n", inspection.Tokens)
+	}
+
+	inspection = Inspect("cc -(aa bb)")
+	if len(inspection.Tokens) != 6 || inspection.Tokens[1] != (Token{TokenKeyword, "-", 3, 4}) {
+		t.Errorf("Expected the sign of the group as a keyword, got %v\n", inspection.Tokens)
+	}
+}
@@ -0,0 +1,256 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+SearchableJSON makes a JSON document Searchable.
+
+Fields are dotted paths into the document, so `author.name:Smith` searches the
+`name` key of the `author` object.  Where a path passes through an array, such
+as `items.name:widget` for a document with an array of `items` objects, the
+field matches if the path matches in any element of the array.  Each string or
+number in the document is matched on its own, so a phrase never matches across
+two array elements.  Terms of a query whose paths pass through the same array
+must match within the same element, so `items.name:widget items.colour:red`
+needs a red widget rather than a widget and something red.  A path that ends
+at an object searches everything beneath it.
+
+A term without a field searches every string and number in the document.
+Numbers are matched using their text in the JSON document.  Booleans only
+match the phrases true or false, as in `active:true`, in the same way as
+SearchableTypedRow, and nulls never match.
+*/
+func SearchableJSON(data []byte) (Searchable, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return newJSONSearchable(doc), nil
+}
+
+/*
+SearchableMap makes a map Searchable, such as a JSON document decoded into a
+map[string]interface{}.
+
+Fields are dotted paths through nested maps, and arrays match if any element
+matches, as described for SearchableJSON, with terms whose paths pass through
+the same array matching within the same element.  Numbers of any Go numeric type are
+matched using their decimal text, so the float64 1042 decoded from JSON is
+searched as 1042.  Booleans only match the phrases true or false and nil
+values never match.  Other values are formatted with fmt.Sprint.
+*/
+func SearchableMap(m map[string]interface{}) Searchable {
+	return newJSONSearchable(m)
+}
+
+// jsonSearchable is the Searchable returned by SearchableJSON and SearchableMap
+type jsonSearchable struct {
+	SearchableMatchFunc
+	doc interface{}
+}
+
+// newJSONSearchable makes a decoded JSON value Searchable, with every element of its arrays searched
+func newJSONSearchable(doc interface{}) *jsonSearchable {
+	return &jsonSearchable{SearchableMatchFunc: searchableJSONValue(doc, nil), doc: doc}
+}
+
+// anyScope returns true if f is true for the document with the arrays at the paths pinned to one of their elements,
+// trying each element in turn.  Paths are pinned in order, so each should follow the shorter paths it starts with.
+func (js *jsonSearchable) anyScope(paths []string, f filter) bool {
+	return js.pinned(paths, make(map[string]int, len(paths)), f)
+}
+
+// pinned does the work of anyScope, with pins holding the element chosen for each array already pinned
+func (js *jsonSearchable) pinned(paths []string, pins map[string]int, f filter) bool {
+	if len(paths) == 0 {
+		return f(&jsonSearchable{SearchableMatchFunc: searchableJSONValue(js.doc, pins), doc: js.doc})
+	}
+	array, isArray := jsonAt(js.doc, paths[0], pins).([]interface{})
+	elements := jsonElements(array, nil)
+	if !isArray || len(elements) == 0 {
+		// Nothing to pin, as the path does not reach an array with elements
+		return js.pinned(paths[1:], pins, f)
+	}
+	for i := range elements {
+		pins[paths[0]] = i
+		if js.pinned(paths[1:], pins, f) {
+			return true
+		}
+	}
+	delete(pins, paths[0])
+	return false
+}
+
+// searchableJSONValue makes a decoded JSON value Searchable.
+// Terms with a field only search the element given by pins of an array at a path in pins.
+func searchableJSONValue(doc interface{}, pins map[string]int) SearchableMatchFunc {
+	return func(field, phrase string, match MatchFunc) bool {
+		var path []string
+		pinned := pins
+		if field != "" {
+			path = strings.Split(field, ".")
+		} else {
+			pinned = nil
+		}
+		return anyJSONLeaf(doc, "", path, pinned, func(leaf interface{}) bool {
+			return jsonLeafContains(leaf, phrase, match)
+		})
+	}
+}
+
+// anyJSONLeaf calls found for each value reached by the path through value until it returns true.
+// An empty path reaches every value beneath value.  at is the path that reached value, which is only
+// followed if there are pins to check.
+func anyJSONLeaf(value interface{}, at string, path []string, pins map[string]int, found func(leaf interface{}) bool) bool {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if len(path) == 0 {
+			for key, child := range value {
+				if anyJSONLeaf(child, jsonPath(at, key, pins), nil, pins, found) {
+					return true
+				}
+			}
+			return false
+		}
+		child, present := value[path[0]]
+		return present && anyJSONLeaf(child, jsonPath(at, path[0], pins), path[1:], pins, found)
+	case []interface{}:
+		if index, pinned := pins[at]; pinned {
+			elements := jsonElements(value, nil)
+			return index < len(elements) && anyJSONLeaf(elements[index], at, path, pins, found)
+		}
+		// Arrays are transparent to the path, any element can match
+		for _, element := range value {
+			if anyJSONLeaf(element, at, path, pins, found) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	}
+	return len(path) == 0 && found(value)
+}
+
+// jsonPath returns the path to the key beneath the path at, or at unchanged if there are no pins to check
+func jsonPath(at, key string, pins map[string]int) string {
+	switch {
+	case pins == nil:
+		return at
+	case at == "":
+		return key
+	}
+	return at + "." + key
+}
+
+// jsonAt returns the value at the dotted path through doc, following the pinned element of each array on the way
+func jsonAt(doc interface{}, path string, pins map[string]int) interface{} {
+	value, at := doc, ""
+	for _, key := range strings.Split(path, ".") {
+		if array, isArray := value.([]interface{}); isArray {
+			index, pinned := pins[at]
+			elements := jsonElements(array, nil)
+			if !pinned || index >= len(elements) {
+				return nil
+			}
+			value = elements[index]
+		}
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			return nil
+		}
+		value, at = object[key], jsonPath(at, key, pins)
+	}
+	return value
+}
+
+// jsonElements appends the elements of an array to elements, with those of any arrays nested within it in their place
+func jsonElements(array []interface{}, elements []interface{}) []interface{} {
+	for _, element := range array {
+		if nested, isArray := element.([]interface{}); isArray {
+			elements = jsonElements(nested, elements)
+		} else {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+/*
+elementScoper is implemented by the Searchable objects of the package whose
+fields may pass through arrays, such as those returned by SearchableJSON, so
+that the terms of a query sharing an array match within the same element.
+*/
+type elementScoper interface {
+	Searchable
+	// anyScope returns true if f is true with the arrays at the dotted paths narrowed to one of their elements
+	anyScope(paths []string, f filter) (match bool)
+}
+
+// scoped returns a filter that searches elementScopers with the arrays at the paths narrowed to one element at a time
+func scoped(paths []string, fs filters) filter {
+	return func(s Searchable) bool {
+		if call, isCall := s.(*searchCall); isCall {
+			// The elements are searched as part of the same call
+			if scoper, ok := call.Searchable.(elementScoper); ok {
+				return scoper.anyScope(paths, func(element Searchable) bool {
+					return fs.Search(call.with(element))
+				})
+			}
+			return fs.Search(s)
+		}
+		if scoper, ok := s.(elementScoper); ok {
+			return scoper.anyScope(paths, fs.Search)
+		}
+		return fs.Search(s)
+	}
+}
+
+// sharedPaths returns the dotted paths that the fields of two or more terms beneath n pass through, shortest first,
+// such as items for items.name:widget items.colour:red.  The whole field is never shared, so tag:book tag:leaflet
+// may match different elements of an array of tags.
+func sharedPaths(n Node) (paths []string) {
+	counts := make(map[string]int)
+	Walk(n, func(n Node) bool {
+		if term, ok := n.(*Term); ok {
+			for end := strings.IndexByte(term.Field, '.'); end > 0; {
+				if counts[term.Field[:end]]++; counts[term.Field[:end]] == 2 {
+					paths = append(paths, term.Field[:end])
+				}
+				next := strings.IndexByte(term.Field[end+1:], '.')
+				if next < 0 {
+					break
+				}
+				end += 1 + next
+			}
+		}
+		return true
+	})
+	sort.SliceStable(paths, func(i, j int) bool {
+		return strings.Count(paths[i], ".") < strings.Count(paths[j], ".")
+	})
+	return paths
+}
+
+// jsonLeafContains returns true if the phrase is present in a string, number or boolean held in a document
+func jsonLeafContains(leaf interface{}, phrase string, match MatchFunc) bool {
+	switch leaf := leaf.(type) {
+	case string:
+		return match(leaf, phrase)
+	case json.Number:
+		return match(leaf.String(), phrase)
+	case float64:
+		return match(strconv.FormatFloat(leaf, 'f', -1, 64), phrase)
+	case float32:
+		return match(strconv.FormatFloat(float64(leaf), 'f', -1, 32), phrase)
+	}
+	return containsValue(leaf, phrase, match)
+}
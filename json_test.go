@@ -0,0 +1,138 @@
+package search
+
+import (
+	"testing"
+)
+
+const testJSONOrder = `{
+	"id": 1042,
+	"customer": {"name": "A. Smith", "address": {"town": "Bristol"}},
+	"items": [
+		{"name": "blue gadget", "colour": "blue", "quantity": 2},
+		{"name": "widget", "colour": "red", "quantity": 10}
+	],
+	"notes": ["fragile", "gift wrap"]
+}`
+
+func TestSearchableJSONArrays(t *testing.T) {
+	record, err := SearchableJSON([]byte(testJSONOrder))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	for _, test := range []struct {
+		Condition string
+		Result    bool
+	}{
+		{"items.name:widget", true},
+		{"items.name:gadget", true},
+		{"items.name:sprocket", false},
+		{"items.colour:red", true},
+		{"items.quantity:10", true},
+		// Terms sharing an array must match within the same element
+		{"items.name:widget items.name:gadget", false},
+		{"items.name:widget items.colour:red", true},
+		{"items.name:widget items.colour:blue", false},
+		{"items.name:widget OR items.colour:blue", true},
+		{"items.name:widget NOT items.colour:blue", true},
+		{"items.name:widget items.quantity:10 gadget", true},
+		{"items.name:gadget items:blue", true},
+		// Terms on the array itself may match different elements
+		{"notes:fragile notes:gift", true},
+		// A phrase must be found within a single element
+		{`items.name:"gadget widget"`, false},
+		{`items.name:"blue gadget"`, true},
+		// Paths do not leak into other keys of the element
+		{"items.name:red", false},
+		{"notes:gift", true},
+		{"customer.name:Smith", true},
+		{"customer.address.town:Bristol", true},
+		{"customer.town:Bristol", false},
+		{"customer:Bristol", true},
+		{"id:1042", true},
+		{"Bristol widget", true},
+		{"missing.path:widget", false},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+	}
+
+	if _, err := SearchableJSON([]byte(`{"broken":`)); err == nil {
+		t.Errorf("Expected an error for invalid JSON\n")
+	}
+}
+
+func TestSearchableMap(t *testing.T) {
+	record := SearchableMap(map[string]interface{}{
+		"id":       float64(1042),
+		"price":    9.5,
+		"count":    3,
+		"gift":     true,
+		"note":     nil,
+		"customer": map[string]interface{}{"name": "A. Smith", "address": map[string]interface{}{"town": "Bristol"}},
+		"items": []interface{}{
+			map[string]interface{}{"name": "blue gadget", "fragile": false},
+			map[string]interface{}{"name": "widget", "fragile": true},
+		},
+	})
+	for _, test := range []struct {
+		Condition string
+		Result    bool
+	}{
+		{"author.name:Smith", false},
+		{"customer.name:Smith", true},
+		{"customer.address.town:Bristol", true},
+		{"customer:Bristol", true},
+		{"items.name:widget", true},
+		{`items.name:"gadget widget"`, false},
+		{"id:1042", true},
+		{"id:1042.0", false},
+		{"price:9.5", true},
+		{"count:3", true},
+		{"Smith 1042", true},
+		{"gift:true", true},
+		{"gift:false", false},
+		{"gift:tru", false},
+		{"items.fragile:false", true},
+		{"items.name:widget items.fragile:true", true},
+		{"items.name:gadget items.fragile:true", false},
+		{"note:nil", false},
+		{"note:null", false},
+		{"null", false},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+	}
+
+	// Arrays within the elements of a shared array are narrowed in turn
+	shelves, err := SearchableJSON([]byte(`{"shelves": [
+		{"label": "fiction", "books": [{"title": "Moby Dick", "author": "Melville"}, {"title": "Emma", "author": "Austen"}]},
+		{"label": "poetry", "books": [[{"title": "Odes", "author": "Keats"}]]}
+	]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	for condition, expected := range map[string]bool{
+		"shelves.books.title:Emma shelves.books.author:Austen":                         true,
+		"shelves.books.title:Emma shelves.books.author:Melville":                       false,
+		"shelves.label:poetry shelves.books.author:Keats":                              true,
+		"shelves.label:poetry shelves.books.author:Austen":                             false,
+		"shelves.label:fiction shelves.books.author:Melville shelves.books.title:Emma": false,
+		"shelves.label:fiction Keats":                                                  true,
+	} {
+		if result := QueryParser(condition).Search(shelves); result != expected {
+			t.Errorf("Expected %v for %v in nested arrays, got %v\n", expected, condition, result)
+		}
+	}
+
+	document, err := SearchableJSON([]byte(`{"gift": true, "note": null}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	for condition, expected := range map[string]bool{"gift:true": true, "gift:false": false, "note:null": false, "true": true} {
+		if result := QueryParser(condition).Search(document); result != expected {
+			t.Errorf("Expected %v for %v in a JSON document, got %v\n", expected, condition, result)
+		}
+	}
+}
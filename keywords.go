@@ -0,0 +1,84 @@
+package search
+
+/*
+keywords collects the phrases of the positive terms beneath n, in query order.
+
+Terms beneath a NOT are skipped, as are terms restricted to a field unless
+includeFieldValues is set.  Each phrase is only included once.
+*/
+func keywords(n Node, negated, includeFieldValues bool, seen map[string]bool, results []string) []string {
+	switch n := n.(type) {
+	case *Term:
+		if negated || n.Kind != TermContains || (n.Field != "" && !includeFieldValues) || seen[n.Phrase] {
+			return results
+		}
+		seen[n.Phrase] = true
+		return append(results, n.Phrase)
+	case *NotNode:
+		return keywords(n.Child, !negated, includeFieldValues, seen, results)
+	}
+	for _, child := range children(n) {
+		results = keywords(child, negated, includeFieldValues, seen, results)
+	}
+	return results
+}
+
+// keywordsOf returns the keywords of the query tree beneath n
+func keywordsOf(n Node, includeFieldValues bool) []string {
+	return keywords(n, false, includeFieldValues, make(map[string]bool), []string{})
+}
+
+/*
+SimpleTerms returns the words and quoted phrases of query for backends that
+can only search for "all of these words".
+
+Operators, brackets and fields are ignored, so `title:"merry time" (boat OR
+whale)` gives ["merry time", "boat", "whale"].  Negated terms are left out, as
+such backends have no way to exclude them.  The query is parsed forgivingly, as
+by QueryParser.
+*/
+func SimpleTerms(query string) (terms []string) {
+	root, _ := parseQuery(query, Options{})
+	return keywordsOf(root, true)
+}
+
+/*
+ReferencedFields returns the fields that query refers to, so that they can be
+checked against those a schema allows before searching.
+
+Fields are found in every term, including those inside brackets and beneath OR
+and NOT, so `merry (title:time OR NOT tag:(book,leaflet)) _exists_:summary`
+gives ["title", "tag", "summary"].  Terms without a field, and the patterns of
+_field_ terms, are not included.  Each field is listed once, in the order it
+first appears in the query.  A *ParseError is returned if the query is
+malformed, as by QueryParserErr.
+*/
+func ReferencedFields(query string) (fields []string, err error) {
+	root, err := parseQuery(query, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return referencedFields(root, make(map[string]bool), []string{}), nil
+}
+
+// referencedFields collects the fields of the terms beneath n, in query order, skipping those already seen
+func referencedFields(n Node, seen map[string]bool, results []string) []string {
+	if term, ok := n.(*Term); ok {
+		for ; term != nil; term = term.Near {
+			field := term.Field
+			if term.Kind == TermFieldExists {
+				field = term.Phrase
+			}
+			if field == "" || term.Kind == TermFieldName || seen[field] {
+				continue
+			}
+			seen[field] = true
+			results = append(results, field)
+		}
+		return results
+	}
+	for _, child := range children(n) {
+		results = referencedFields(child, seen, results)
+	}
+	return results
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeywords(t *testing.T) {
+	for _, test := range []struct {
+		Condition  string
+		Keywords   []string
+		WithFields []string
+	}{
+		{
+			"boat whale OR shark NOT dead tag:book",
+			[]string{"boat", "whale", "shark"},
+			[]string{"boat", "whale", "shark", "book"},
+		},
+		{
+			`"floating boat" (whale OR NOT shark) title:"merry time"`,
+			[]string{"floating boat", "whale"},
+			[]string{"floating boat", "whale", "merry time"},
+		},
+		{
+			"NOT (boat whale) NOT tag:book shark",
+			[]string{"shark"},
+			[]string{"shark"},
+		},
+		{
+			"NOT (boat NOT whale)",
+			[]string{"whale"},
+			[]string{"whale"},
+		},
+		{
+			"boat boat tag:boat any(title,body):merry _field_:author",
+			[]string{"boat"},
+			[]string{"boat", "merry"},
+		},
+		{
+			"",
+			[]string{},
+			[]string{},
+		},
+	} {
+		query := QueryParser(test.Condition)
+		if keywords := query.Keywords(false); !reflect.DeepEqual(keywords, test.Keywords) {
+			t.Errorf("Expected keywords %q for %v, got %q\n", test.Keywords, test.Condition, keywords)
+		}
+		if keywords := query.Keywords(true); !reflect.DeepEqual(keywords, test.WithFields) {
+			t.Errorf("Expected keywords with fields %q for %v, got %q\n", test.WithFields, test.Condition, keywords)
+		}
+	}
+}
+
+func TestSimpleTerms(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Terms     []string
+	}{
+		{
+			`title:"merry time" (boat OR whale) NOT shark`,
+			[]string{"merry time", "boat", "whale"},
+		},
+		{
+			`"floating boat" OR (tag:(book,leaflet) 'sea shanty'`,
+			[]string{"floating boat", "book", "leaflet", "sea shanty"},
+		},
+		{
+			"NOT (boat whale)",
+			[]string{},
+		},
+	} {
+		if terms := SimpleTerms(test.Condition); !reflect.DeepEqual(terms, test.Terms) {
+			t.Errorf("Expected terms %q for %v, got %q\n", test.Terms, test.Condition, terms)
+		}
+	}
+}
+
+func TestReferencedFields(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Fields    []string
+	}{
+		{
+			`merry (title:time OR NOT tag:(book,leaflet)) _exists_:summary`,
+			[]string{"title", "tag", "summary"},
+		},
+		{
+			`boat "floating whale" NOT shark`,
+			[]string{},
+		},
+		{
+			`title:(merry OR battle) body!:beetle any(title,subject):time _missing_:body`,
+			[]string{"title", "body", "subject"},
+		},
+		{
+			`"release date":>2020-01-01 price:[5 TO 20] _field_:auth* body:(merry NEAR/3 time)`,
+			[]string{"release date", "price", "body"},
+		},
+	} {
+		fields, err := ReferencedFields(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if !reflect.DeepEqual(fields, test.Fields) {
+			t.Errorf("Expected fields %q for %v, got %q\n", test.Fields, test.Condition, fields)
+		}
+	}
+
+	if fields, err := ReferencedFields("title:(merry"); err == nil || fields != nil {
+		t.Errorf("Expected an error and no fields for an unclosed bracket, got %q and %v\n", fields, err)
+	}
+}
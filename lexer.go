@@ -0,0 +1,507 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind identifies the kinds of token found by the lexer
+type tokenKind int
+
+const (
+	// tokenPhrase is a word, quoted phrase, field term or keyword such as boat, "floating boat", title:merry or OR
+	tokenPhrase tokenKind = iota
+	// tokenOpen is an opening bracket, or the start of a field group such as title:(
+	tokenOpen
+	// tokenClose is a closing bracket
+	tokenClose
+	// tokenSign is a +, - or ? directly before an opening bracket, such as the - of -(boat whale)
+	tokenSign
+)
+
+/*
+token is a single element of a query found by the lexer.
+
+Positions are byte offsets into the query given to lex.  For phrases, text
+starts at valueStart, after any opening quote, while start includes the quote
+and end is the position after the phrase.  For brackets, pos is the position
+of the bracket itself, and start includes the field of a field group.
+*/
+type token struct {
+	kind       tokenKind
+	text       string
+	valueStart int
+	start, end int
+	pos        int
+	// field is the field given to a field group such as title:(merry OR battle)
+	field      string
+	fieldGroup bool
+	// negated is set for a field group that must not match, written tag!:(book OR leaflet)
+	negated bool
+}
+
+// lexState is what the lexer is in the middle of reading, which decides what the next character means
+type lexState int
+
+const (
+	// stateBetween is between phrases, where whitespace is skipped and brackets are tokens of their own
+	stateBetween lexState = iota
+	// statePhrase is within a phrase, which whitespace or a closing bracket ends
+	statePhrase
+	// stateQuote is within quotes, where whitespace and brackets are part of the phrase
+	stateQuote
+	// stateRegex is within the slashes of a regular expression, where everything is part of the phrase
+	stateRegex
+	// stateEscape follows a backslash, making the next character part of the phrase whatever it is
+	stateEscape
+)
+
+/*
+lexer splits a query into tokens one character at a time.
+
+The lexer moves between the lexStates as it reads.  Between phrases a quote or
+any other character other than whitespace starts a phrase, while brackets, and
+a sign directly before an opening bracket, are tokens.  Whitespace ends a
+phrase unless it is inside quotes, and brackets end a phrase unless they
+belong to a value list such as tag:(book,leaflet).  The spaces of a range such
+as price:[5 TO 20] are part of the phrase, as is everything between the
+slashes of a regular expression such as title:/^Once .* time$/.  A backslash
+makes the character after it part of the phrase, whatever it is, as in
+tag\:special.
+*/
+type lexer struct {
+	query string
+	// pos is the position of the next character to read, done is set once the final phrase has been emitted
+	pos  int
+	done bool
+	// pending holds tokens found but not yet returned, a character can end a phrase and close a bracket
+	pending      [2]token
+	pendingCount int
+	state        lexState
+	// resume is the state to return to after the character following a backslash
+	resume lexState
+	// phraseStart and phraseEnd are the first and last bytes of the current phrase, excluding any opening quote
+	phraseStart, phraseEnd int
+	// tokenStart is the start of the current phrase including any quotes
+	tokenStart int
+	// inList and inRange are set while a phrase is within a value list or range
+	inList  bool
+	inRange bool
+	// escapedPos is the position of the last character escaped by a backslash
+	escapedPos int
+	// quotePos is the position of the quote that opened a phrase or field value, or -1 once it is closed.
+	// Quotes within words, such as the apostrophe in it's, are not recorded.
+	quotePos int
+	// quoteChar is the quote that opened the current quoted phrase or field value
+	quoteChar rune
+	// joinComparisons joins comparisons written with spaces, such as price >= 50, into a single phrase
+	joinComparisons bool
+	// keywords are the operator words, which cannot be the field or value of a spaced comparison
+	keywords operatorWords
+	// ahead holds tokens read while looking for a spaced comparison that turned out not to be one
+	ahead      [2]token
+	aheadCount int
+	// groups records for each byte of the query whether a field:( before it starts a group, found by fieldGroup
+	groups []bool
+	// rangeClose is the position of the next ] or } found by rangeCloses, or the length of the query if there is none
+	rangeClose int
+}
+
+// lex returns a lexer for the tokens of the query
+func lex(query string) *lexer {
+	return &lexer{query: query, quotePos: -1, escapedPos: -1, keywords: defaultKeywords}
+}
+
+// token returns the next token of the query, ok is false once there are none left
+func (l *lexer) token() (tok token, ok bool) {
+	tok, ok = l.read()
+	if !ok || !l.joinComparisons || !isSpacedField(tok, l.keywords) {
+		return tok, ok
+	}
+	op, ok := l.read()
+	if !ok {
+		return tok, true
+	}
+	value, ok := l.read()
+	if !ok || !isSpacedOp(op) || !isSpacedValue(value, l.keywords) || !l.spaceBetween(tok, op) || !l.spaceBetween(op, value) {
+		// Not a comparison, so the tokens read ahead are returned in turn
+		l.unread(op)
+		if ok {
+			l.unread(value)
+		}
+		return tok, true
+	}
+	// price >= 50 is treated as price:>=50, with any quotes around the value removed
+	tok.text = tok.text + ":" + op.text + value.text
+	tok.end = value.end
+	return tok, true
+}
+
+// spaceBetween returns true if only whitespace separates the tokens
+func (l *lexer) spaceBetween(first, second token) bool {
+	return first.end <= second.start && strings.TrimSpace(l.query[first.end:second.start]) == ""
+}
+
+// unread returns a token to be read again after any others already returned
+func (l *lexer) unread(tok token) {
+	l.ahead[l.aheadCount] = tok
+	l.aheadCount++
+}
+
+// read returns the next token from those read ahead or the query
+func (l *lexer) read() (tok token, ok bool) {
+	if l.aheadCount > 0 {
+		tok = l.ahead[0]
+		l.ahead[0] = l.ahead[1]
+		l.aheadCount--
+		return tok, true
+	}
+	for l.pendingCount == 0 {
+		if l.pos >= len(l.query) {
+			if l.done {
+				return token{}, false
+			}
+			// End of all phrases, spit it out.
+			l.done = true
+			l.emitPhrase(len(l.query))
+			continue
+		}
+		char, size := utf8.DecodeRuneInString(l.query[l.pos:])
+		l.next(l.pos, char)
+		l.pos += size
+	}
+	tok = l.pending[0]
+	l.pending[0] = l.pending[1]
+	l.pendingCount--
+	return tok, true
+}
+
+// emit queues a token to be returned
+func (l *lexer) emit(tok token) {
+	l.pending[l.pendingCount] = tok
+	l.pendingCount++
+}
+
+// unclosedQuote returns the position of a quote opening a phrase or field value that has no closing quote, or -1.
+// It is only known once every token has been read.
+func (l *lexer) unclosedQuote() int {
+	if l.state != stateQuote {
+		return -1
+	}
+	return l.quotePos
+}
+
+// emitPhrase adds the current phrase, if there is one, as a token ending at end.
+// Single characters are dropped, other than a bare colon which is kept so that the parser can report it,
+// and <, > or a digit when they may be part of a spaced comparison such as rating > 4.
+func (l *lexer) emitPhrase(end int) {
+	kept := false
+	if l.phraseStart == l.phraseEnd && l.phraseStart == l.tokenStart && l.phraseStart < len(l.query) {
+		char := l.query[l.phraseStart]
+		kept = char == ':' || l.joinComparisons && (char == '<' || char == '>' || '0' <= char && char <= '9')
+	}
+	if l.phraseStart < l.phraseEnd || kept {
+		l.emit(token{
+			kind:       tokenPhrase,
+			text:       l.query[l.phraseStart : l.phraseEnd+1],
+			valueStart: l.phraseStart,
+			start:      l.tokenStart,
+			end:        end,
+		})
+	}
+}
+
+// endPhrase adds the current phrase as a token ending at end, leaving the lexer between phrases
+func (l *lexer) endPhrase(end int) {
+	l.emitPhrase(end)
+	l.leavePhrase()
+}
+
+// leavePhrase moves the lexer between phrases, outside any value list or range
+func (l *lexer) leavePhrase() {
+	l.state = stateBetween
+	l.inList = false
+	l.inRange = false
+}
+
+// closeBracket ends the current phrase and adds the closing bracket at pos
+func (l *lexer) closeBracket(pos int) {
+	l.endPhrase(pos)
+	l.phraseStart = pos + 1
+	l.emit(token{kind: tokenClose, pos: pos, start: pos, end: pos + 1})
+}
+
+// next moves the lexer on by the character at pos
+func (l *lexer) next(pos int, char rune) {
+	size := utf8.RuneLen(char)
+	if l.state == stateEscape {
+		l.state = l.resume
+		l.escapedPos = pos
+		l.phraseEnd = pos + size - 1
+		return
+	}
+	if char == '\\' && pos+size < len(l.query) {
+		if l.state == stateBetween {
+			l.startPhrase(pos)
+		}
+		l.resume, l.state = l.state, stateEscape
+		l.phraseEnd = pos
+		return
+	}
+	switch l.state {
+	case stateBetween:
+		l.nextBetween(pos, char, size)
+	case stateQuote:
+		l.nextQuoted(pos, char, size)
+	case stateRegex:
+		// Everything up to the closing slash of a regular expression is part of the phrase, e.g. title:/^(Once|Twice) upon/
+		if char == '/' {
+			l.state = statePhrase
+		}
+		l.phraseEnd = pos + size - 1
+	default:
+		l.nextInPhrase(pos, char, size)
+	}
+}
+
+// startPhrase begins a phrase with the character at pos
+func (l *lexer) startPhrase(pos int) {
+	l.state = statePhrase
+	l.tokenStart = pos
+}
+
+// nextBetween moves the lexer on by a character between phrases, where a phrase starts after any quote or bracket
+func (l *lexer) nextBetween(pos int, char rune, size int) {
+	switch {
+	case unicode.IsSpace(char):
+		l.phraseStart = pos + size
+		return
+	case isQuote(char):
+		l.startPhrase(pos)
+		l.state = stateQuote
+		l.quotePos = pos
+		l.quoteChar = char
+		l.phraseStart = pos + size
+	case char == '(':
+		l.emit(token{kind: tokenOpen, pos: pos, start: pos})
+		l.phraseStart = pos + size
+	case (char == '+' || char == '-' || char == '?') && pos+1 < len(l.query) && l.query[pos+1] == '(':
+		// A sign applying to the group that follows, as in -(boat whale)
+		l.emit(token{kind: tokenSign, text: l.query[pos : pos+1], pos: pos, start: pos, end: pos + 1})
+		l.phraseStart = pos + size
+	case char == ')':
+		l.phraseEnd = pos - 1
+		l.closeBracket(pos)
+	default:
+		l.startPhrase(pos)
+	}
+	l.phraseEnd = pos + size - 1
+}
+
+// nextQuoted moves the lexer on by a character within quotes
+func (l *lexer) nextQuoted(pos int, char rune, size int) {
+	switch {
+	case isQuote(char) && (l.quotePos < 0 || sameQuoteStyle(l.quoteChar, char)):
+		// Quotes opening a phrase or field value are only closed by one of the same style, while those within words close on any quote.
+		// The phrase ends with the last byte before the quote, which may be shorter than the quote itself.
+		l.state = statePhrase
+		l.phraseEnd = pos - 1
+	case unicode.IsSpace(char):
+		l.phraseEnd = pos
+	default:
+		l.phraseEnd = pos + size - 1
+	}
+}
+
+// nextInPhrase moves the lexer on by a character within a phrase that is not quoted
+func (l *lexer) nextInPhrase(pos int, char rune, size int) {
+	switch {
+	case unicode.IsSpace(char):
+		if l.inRange || l.inList && !l.fieldGroup(pos) {
+			// Spaces within a range, or beside the commas of a list such as tag:(book, leaflet), are part of the phrase
+			l.phraseEnd = pos
+			return
+		}
+		l.endPhrase(pos)
+		l.phraseStart = pos + size
+	case isQuote(char):
+		// Quote part way through the phrase, e.g. title:"A book"
+		l.state = stateQuote
+		l.quotePos = -1
+		l.quoteChar = char
+		if l.afterSeparator(pos) {
+			l.quotePos = pos
+		}
+	case char == '/' && l.afterSeparator(pos) && unescapedIndex(l.query[pos+1:], '/') >= 0:
+		// Start of a regular expression, which may hold spaces, brackets and quotes, e.g. title:/^Once .* time$/
+		l.state = stateRegex
+		l.phraseEnd = pos
+	case rangeOpen(char) && l.afterSeparator(pos) && l.rangeCloses(pos):
+		// Start of a range whose bounds are separated by spaces, e.g. price:[5 TO 20]
+		l.inRange = true
+		l.phraseEnd = pos
+	case rangeClose(char) && l.inRange:
+		l.inRange = false
+		l.phraseEnd = pos
+	case char == '(' && l.afterSeparator(pos) && l.fieldGroup(pos+1):
+		// Start of a group of terms sharing a field, e.g. title:(merry OR battle)
+		field := l.query[l.phraseStart : pos-1]
+		negated := false
+		if quoted, colon := quotedField(l.query[l.phraseStart:pos]); l.phraseStart != l.tokenStart && colon == len(field) {
+			// A quoted field such as "release date":(2020 OR 2021)
+			field = unescape(quoted)
+		} else if pos-2 > l.phraseStart && l.query[pos-2] == '!' && l.escapedPos != pos-2 {
+			// A field that must not match, such as tag!:(book OR leaflet)
+			field = field[:len(field)-1]
+			negated = true
+		}
+		l.emit(token{kind: tokenOpen, pos: pos, start: l.tokenStart, field: field, fieldGroup: true, negated: negated})
+		l.leavePhrase()
+		l.phraseStart = pos + 1
+		l.phraseEnd = pos
+	case char == '(' && (l.query[l.phraseStart:pos] == "any" || l.afterSeparator(pos)):
+		// Start of a field or value list, e.g. any(title,body):merry or tag:(book,leaflet)
+		l.inList = true
+		l.phraseEnd = pos
+	case char == ')' && l.inList:
+		// End of the list rather than a closing bracket
+		l.inList = false
+		l.phraseEnd = pos
+	case char == ')':
+		// phraseEnd already marks the last character of the phrase,
+		// which excludes any closing quote, e.g. (frog OR "battle fought")
+		l.closeBracket(pos)
+	default:
+		l.phraseEnd = pos + size - 1
+	}
+}
+
+// sameQuoteStyle returns true if both quotes are single quotes, such as ' and ’, or both are double quotes, such as " and ”,
+// so that "it's" is a single phrase while the mismatched quotes of "text' leave the phrase unclosed
+func sameQuoteStyle(open, close rune) bool {
+	return singleQuote(open) == singleQuote(close)
+}
+
+// singleQuote returns true for the quotation marks used singly, rather than as doubles such as " and “
+func singleQuote(char rune) bool {
+	switch char {
+	case '\'', '‘', '’', '‚', '‛', '‹', '›', '「', '」', '﹁', '﹂', '＇', '｢', '｣':
+		return true
+	}
+	return false
+}
+
+// fieldGroup returns true if the text from start, following field:(, holds a group of terms such as
+// title:(merry OR battle) rather than a list of values such as tag:(book,leaflet).
+// Lists have no whitespace outside of quotes other than beside their commas, as in tag:(book, leaflet),
+// so the first other space or closing bracket outside quotes decides.
+// The answer for every position is found in a single pass backwards through the query the first time it is needed,
+// so that a query with many brackets is not scanned again for each of them.
+func (l *lexer) fieldGroup(start int) bool {
+	if l.groups == nil {
+		l.groups = make([]bool, len(l.query)+1)
+		// The answer from each position if it is outside or inside quotes, with the end of the query making a group
+		unquoted, quoted := true, true
+		l.groups[len(l.query)] = true
+		// after is the nearest character after end that is not whitespace, and commaSpace is set while within
+		// whitespace that has a comma on either side of it
+		var after rune
+		inSpace, commaSpace := false, false
+		for end := len(l.query); end > 0; {
+			char, size := utf8.DecodeLastRuneInString(l.query[:end])
+			end -= size
+			space := unicode.IsSpace(char)
+			if space && !inSpace {
+				commaSpace = after == ',' || strings.HasSuffix(strings.TrimRightFunc(l.query[:end], unicode.IsSpace), ",")
+			}
+			inSpace = space
+			switch {
+			case isQuote(char):
+				unquoted, quoted = quoted, unquoted
+			case char == ')':
+				unquoted = false
+			case space && !commaSpace:
+				unquoted = true
+			}
+			if !space {
+				after = char
+			}
+			l.groups[end] = unquoted
+		}
+	}
+	return l.groups[start]
+}
+
+// rangeCloses returns true if a ] or } follows the range opening at pos.
+// The position found is kept until the lexer passes it, so the query is only searched once however many ranges it opens.
+func (l *lexer) rangeCloses(pos int) bool {
+	if l.rangeClose <= pos {
+		if index := strings.IndexAny(l.query[pos+1:], "]}"); index >= 0 {
+			l.rangeClose = pos + 1 + index
+		} else {
+			l.rangeClose = len(l.query)
+		}
+	}
+	return l.rangeClose < len(l.query)
+}
+
+// afterSeparator returns true if the character at pos follows a colon that is not escaped,
+// or the != between a field and its value such as tag!=draft
+func (l *lexer) afterSeparator(pos int) bool {
+	switch l.query[pos-1] {
+	case ':':
+		return l.escapedPos != pos-1
+	case '=':
+		return pos-2 > l.phraseStart && l.query[pos-2] == '!' && l.escapedPos != pos-1 && l.escapedPos != pos-2
+	}
+	return false
+}
+
+// isSpacedField returns true if the token may be the field of a spaced comparison, a bare word such as price
+func isSpacedField(tok token, kw operatorWords) bool {
+	return tok.kind == tokenPhrase && tok.valueStart == tok.start && !kw.has(tok.text) &&
+		strings.IndexByte(tok.text, ':') < 0
+}
+
+// isSpacedOp returns true if the token is the operator of a spaced comparison
+func isSpacedOp(tok token) bool {
+	op, rest := splitComparison(tok.text)
+	return tok.kind == tokenPhrase && tok.valueStart == tok.start && op != 0 && rest == ""
+}
+
+// isSpacedValue returns true if the token may be the value of a spaced comparison
+func isSpacedValue(tok token, kw operatorWords) bool {
+	return tok.kind == tokenPhrase && (tok.valueStart != tok.start || !kw.has(tok.text))
+}
+
+// isKeyword returns true for the default words that are operators when not quoted
+func isKeyword(text string) bool {
+	return defaultKeywords.has(text)
+}
+
+// operatorWords holds the words that are operators when not quoted, compared ignoring case if fold is set
+type operatorWords struct {
+	and, or, not string
+	fold         bool
+}
+
+// defaultKeywords are the operator words used unless Options gives others
+var defaultKeywords = operatorWords{and: "AND", or: "OR", not: "NOT"}
+
+// is returns true if text is the keyword
+func (kw operatorWords) is(text, keyword string) bool {
+	if kw.fold {
+		return strings.EqualFold(text, keyword)
+	}
+	return text == keyword
+}
+
+// has returns true if text is any of the keywords, or a NEAR operator
+func (kw operatorWords) has(text string) bool {
+	if kw.is(text, kw.and) || kw.is(text, kw.or) || kw.is(text, kw.not) {
+		return true
+	}
+	_, _, near := splitNear(text)
+	return near
+}
@@ -0,0 +1,85 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLex(t *testing.T) {
+	for _, test := range []struct {
+		Query  string
+		Tokens string
+	}{
+		{"boat whale", "[boat@0-4 whale@5-10]"},
+		{`"floating boat" OR whale`, "[floating boat@0-15 OR@16-18 whale@19-24]"},
+		{"boat (whale shark)", "[boat@0-4 (@5 whale@6-11 shark@12-17 )@17]"},
+		{"title:(merry OR battle)", "[title:(@6 merry@7-12 OR@13-15 battle@16-22 )@22]"},
+		{"tag:(book,leaflet) any(title,body):merry", "[tag:(book,leaflet)@0-18 any(title,body):merry@19-40]"},
+		{"tag:(book, leaflet) tag:(book ,leaflet)", "[tag:(book, leaflet)@0-19 tag:(book ,leaflet)@20-39]"},
+		{"title:(merry, battle fought)", "[title:(@6 merry,@7-13 battle@14-20 fought@21-27 )@27]"},
+		{`(frog OR "battle fought")`, "[(@0 frog@1-5 OR@6-8 battle fought@9-24 )@24]"},
+		{"a boat", "[boat@2-6]"},
+		{`title:/^(Once|Twice) "upon"/ boat`, `[title:/^(Once|Twice) "upon"/@0-28 boat@29-33]`},
+		{`title:/a\/b c/`, `[title:/a\/b c/@0-14]`},
+		{"path:/usr/bin boat", "[path:/usr/bin@0-13 boat@14-18]"},
+		{"path:/usr boat", "[path:/usr@0-9 boat@10-14]"},
+		{`"" boat`, "[boat@3-7]"},
+		{"price:[5 TO 20) boat whale]", "[price:[5 TO 20@0-14 )@14 boat@16-20 whale]@21-27]"},
+		{"cc -(aa bb) +(dd)", "[cc@0-2 -@3 (@4 aa@5-7 bb@8-10 )@10 +@12 (@13 dd@14-16 )@16]"},
+		{"-aa ?(bb) -a(b)", "[-aa@0-3 ?@4 (@5 bb@6-8 )@8 -a(b@10-14 )@14]"},
+	} {
+		var tokens []string
+		l := lex(test.Query)
+		for tok, ok := l.token(); ok; tok, ok = l.token() {
+			switch tok.kind {
+			case tokenPhrase:
+				tokens = append(tokens, fmt.Sprintf("%v@%v-%v", tok.text, tok.start, tok.end))
+			case tokenOpen:
+				if tok.fieldGroup {
+					tokens = append(tokens, fmt.Sprintf("%v:(@%v", tok.field, tok.pos))
+				} else {
+					tokens = append(tokens, fmt.Sprintf("(@%v", tok.pos))
+				}
+			case tokenClose:
+				tokens = append(tokens, fmt.Sprintf(")@%v", tok.pos))
+			case tokenSign:
+				tokens = append(tokens, fmt.Sprintf("%v@%v", tok.text, tok.pos))
+			}
+		}
+		if fmt.Sprint(tokens) != test.Tokens {
+			t.Errorf("Expected tokens %v for %v, got %v\n", test.Tokens, test.Query, tokens)
+		}
+	}
+}
+
+func TestLexFieldGroupScales(t *testing.T) {
+	testParseScales(t, "a:(b,")
+}
+
+func TestLexRangeScales(t *testing.T) {
+	testParseScales(t, "a:[")
+}
+
+// testParseScales fails if parsing a query of many repeats takes much more than linear time,
+// comparing the quickest of a few parses at two lengths so that a slow run does not fail the test
+func testParseScales(t *testing.T, repeat string) {
+	quickest := func(count int) time.Duration {
+		query := strings.Repeat(repeat, count)
+		var best time.Duration
+		for i := 0; i < 3; i++ {
+			start := time.Now()
+			QueryParser(query)
+			if took := time.Since(start); i == 0 || took < best {
+				best = took
+			}
+		}
+		return best
+	}
+	short, long := quickest(4000), quickest(32000)
+	// Eight times the length would take sixty four times as long if parsing were quadratic
+	if long > 24*short+10*time.Millisecond {
+		t.Errorf("Expected parsing %v to scale linearly, took %v for 4000 repeats and %v for 32000\n", repeat, short, long)
+	}
+}
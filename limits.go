@@ -0,0 +1,27 @@
+package search
+
+import (
+	"fmt"
+)
+
+/*
+limitOrBranches drops the alternatives beyond max from every OR beneath n.
+
+A ParseError is added to diagnostics for each OR that is cut short, positioned
+at the first alternative dropped.
+*/
+func limitOrBranches(n Node, max int, diagnostics []*ParseError) []*ParseError {
+	if or, ok := n.(*OrNode); ok && len(or.Children) > max {
+		dropped, _ := or.Children[max].Span()
+		diagnostics = append(diagnostics, &ParseError{
+			Pos:     dropped,
+			Message: fmt.Sprintf("OR has %v alternatives, only the first %v are used", len(or.Children), max),
+		})
+		or.Children = or.Children[:max]
+		_, or.End = or.Children[max-1].Span()
+	}
+	for _, child := range children(n) {
+		diagnostics = limitOrBranches(child, max, diagnostics)
+	}
+	return diagnostics
+}
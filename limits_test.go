@@ -0,0 +1,121 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMaxOrBranches(t *testing.T) {
+	branches := make([]string, 100)
+	for i := range branches {
+		branches[i] = fmt.Sprintf("word%03d", i)
+	}
+	condition := strings.Join(branches, " OR ")
+
+	q, err := QueryParserOptions(condition, Options{MaxOrBranches: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	or := q.(*query).root.Children[0].(*OrNode)
+	if len(or.Children) != 10 {
+		t.Errorf("Expected the OR to be cut to 10 alternatives, got %v\n", len(or.Children))
+	}
+	if _, end := or.Span(); end != strings.Index(condition, "word009")+len("word009") {
+		t.Errorf("Expected the OR to end after the last alternative kept, got %v\n", end)
+	}
+
+	diagnostics := q.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("Expected a single diagnostic, got %v\n", diagnostics)
+	}
+	if diagnostics[0].Pos != strings.Index(condition, "word010") {
+		t.Errorf("Expected the diagnostic at the first alternative dropped, got %v\n", diagnostics[0].Pos)
+	}
+
+	if !q.MatchString("word009") {
+		t.Errorf("Expected an alternative within the limit to match\n")
+	}
+	if q.MatchString("word010") {
+		t.Errorf("Expected an alternative beyond the limit not to match\n")
+	}
+}
+
+func TestMaxOrBranchesWithinLimit(t *testing.T) {
+	for _, condition := range []string{"boat OR whale", "tag:(book,leaflet) (boat OR whale)", "boat whale shark"} {
+		query, err := QueryParserOptions(condition, Options{MaxOrBranches: 2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if diagnostics := query.Diagnostics(); len(diagnostics) != 0 {
+			t.Errorf("Expected no diagnostics for %v, got %v\n", condition, diagnostics)
+		}
+	}
+
+	query, err := QueryParserOptions("tag:(book,leaflet,flyer) NOT (boat OR whale OR shark)", Options{MaxOrBranches: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if diagnostics := query.Diagnostics(); len(diagnostics) != 2 {
+		t.Errorf("Expected a diagnostic for each OR cut short, got %v\n", diagnostics)
+	}
+	if diagnostics := QueryParser("boat OR whale OR shark").Diagnostics(); len(diagnostics) != 0 {
+		t.Errorf("Expected no limit by default, got %v\n", diagnostics)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Depth     int
+		Pos       int
+	}{
+		{"((boat))", 2, -1},
+		{"((boat))", 1, 1},
+		{"boat (whale (shark (frog)))", 2, 19},
+		{"(boat) (whale) title:(merry)", 1, -1},
+		{"(boat (whale)) shark)", 1, 6},
+		{strings.Repeat("(", 10000) + "boat" + strings.Repeat(")", 10000), 100, 100},
+	} {
+		root, err := parseQuery(test.Condition, Options{MaxDepth: test.Depth})
+		if test.Pos < 0 {
+			if err != nil {
+				t.Errorf("Unexpected error for %.20v: %v\n", test.Condition, err)
+			}
+			continue
+		}
+		parseErr, ok := err.(*ParseError)
+		if !ok || parseErr.Pos != test.Pos || !strings.Contains(parseErr.Message, fmt.Sprintf("more than %v deep", test.Depth)) {
+			t.Errorf("Expected a depth error at %v for %.20v, got %v\n", test.Pos, test.Condition, err)
+		}
+		if !newQuery(root, Options{}).MatchString(test.Condition) {
+			t.Errorf("Expected the terms of groups beyond the limit to be kept for %.20v\n", test.Condition)
+		}
+	}
+}
+
+func TestMaxTerms(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+		Result    string
+	}{
+		{"boat whale shark", -1, "boat whale shark"},
+		{"boat whale shark frog", 17, "boat whale shark"},
+		{"boat whale OR shark OR frog", 23, "boat whale OR shark"},
+		{"boat tag:(book,leaflet)", -1, "boat tag:book OR tag:leaflet"},
+		{"boat whale tag:(book,leaflet)", 11, "boat whale"},
+		{"any(title,body):merry frog shark", 27, "title:merry OR body:merry frog"},
+	} {
+		root, err := parseQuery(test.Condition, Options{MaxTerms: 3})
+		if test.Pos < 0 && err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if parseErr, ok := err.(*ParseError); test.Pos >= 0 && (!ok || parseErr.Pos != test.Pos || !strings.Contains(parseErr.Message, "more than 3 terms")) {
+			t.Errorf("Expected a terms error at %v for %v, got %v\n", test.Pos, test.Condition, err)
+		}
+		if result := newQuery(root, Options{}).String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+	}
+}
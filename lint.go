@@ -0,0 +1,71 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Warning describes part of a query that is likely to be slow or to not do what
+the user intended.  Start and End are the span of the query text concerned.
+*/
+type Warning struct {
+	Start, End int
+	Message    string
+}
+
+/*
+Lint looks for common inefficiencies in q, so that users can be guided towards
+better queries.  Warnings are given for a leading wildcard such as `*ing`, which
+is searched for literally, or in a _field_ pattern has to check every field
+name; a query that only excludes records, such as `NOT boat`, which has to
+check every record; a single character term such as `title:a`, which matches
+most records; and a term that repeats an earlier one, such as the second `boat`
+in `boat whale boat`.
+
+Lint only understands queries returned by this package, for others it returns
+nil.
+*/
+func Lint(q Query) (warnings []Warning) {
+	parsed, ok := q.(*query)
+	if !ok {
+		return nil
+	}
+	root := parsed.root
+
+	if len(root.Children) > 0 {
+		allNegative := true
+		for _, child := range root.Children {
+			if _, isNot := child.(*NotNode); !isNot {
+				allNegative = false
+			}
+		}
+		if allNegative {
+			warnings = append(warnings, Warning{Start: root.Start, End: root.End, Message: "query only excludes records, so every record has to be checked"})
+		}
+	}
+
+	seen := make(map[Term]bool)
+	Walk(root, func(n Node) bool {
+		term, ok := n.(*Term)
+		if !ok {
+			return true
+		}
+		if strings.HasPrefix(term.Phrase, "*") {
+			warnings = append(warnings, Warning{Start: term.Start, End: term.End, Message: fmt.Sprintf("leading wildcard in %q", term.Phrase)})
+		}
+		if term.Kind == TermContains && utf8.RuneCountInString(term.Phrase) == 1 {
+			warnings = append(warnings, Warning{Start: term.Start, End: term.End, Message: fmt.Sprintf("single character term %q matches most records", term.Phrase)})
+		}
+		// Terms are compared without their position in the query
+		key := *term
+		key.Start, key.End = 0, 0
+		if seen[key] {
+			warnings = append(warnings, Warning{Start: term.Start, End: term.End, Message: fmt.Sprintf("duplicate term %q", term.Phrase)})
+		}
+		seen[key] = true
+		return true
+	})
+	return warnings
+}
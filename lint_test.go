@@ -0,0 +1,25 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Warnings  []Warning
+	}{
+		{"boat whale", nil},
+		{"*ing boat", []Warning{{0, 4, `leading wildcard in "*ing"`}}},
+		{"_field_:*name", []Warning{{0, 13, `leading wildcard in "*name"`}}},
+		{"boat title:a", []Warning{{5, 12, `single character term "a" matches most records`}}},
+		{"NOT boat NOT (whale shark)", []Warning{{0, 26, "query only excludes records, so every record has to be checked"}}},
+		{"boat whale boat title:boat", []Warning{{11, 15, `duplicate term "boat"`}}},
+		{"boat OR (whale boat)", []Warning{{15, 19, `duplicate term "boat"`}}},
+	} {
+		if warnings := Lint(QueryParser(test.Condition)); !reflect.DeepEqual(warnings, test.Warnings) {
+			t.Errorf("Expected %v for %v, got %v\n", test.Warnings, test.Condition, warnings)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build xtext
+// +build xtext
+
+package search
+
+import (
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+/*
+Normalize prepares text for matching that ignores case and accents.  The text
+is decomposed into base characters and combining marks using Unicode NFD, the
+marks are removed and the result is case folded, so that `Café` becomes `cafe`
+and `STRASSE` and `straße` both become `strasse`.
+
+It is only built with the xtext build tag, so that the package does not
+otherwise depend on golang.org/x/text.  Use it as Options.Normalizer, and apply
+it to the text of records with SearchableNormalized or TransformStringSlice.
+*/
+func Normalize(text string) string {
+	// Transformers keep state between calls, so each call chains its own
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), cases.Fold())
+	normalized, _, err := transform.String(t, text)
+	if err != nil {
+		return text
+	}
+	return normalized
+}
+
+/*
+SearchableNormalized makes a string Searchable after applying Normalize to it,
+for use with queries parsed with Normalize as their Options.Normalizer.
+
+The string is normalised once, when SearchableNormalized is called, rather than
+on every search.
+*/
+func SearchableNormalized(record string) Searchable {
+	return SearchableMatchString(Normalize(record))
+}
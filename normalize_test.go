@@ -0,0 +1,49 @@
+//go:build xtext
+// +build xtext
+
+package search
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, test := range []struct {
+		Text       string
+		Normalized string
+	}{
+		{"café", "cafe"},
+		{"Café", "cafe"},
+		{"STRASSE", "strasse"},
+		{"straße", "strasse"},
+		{"Ångström", "angstrom"},
+		{"plain text", "plain text"},
+	} {
+		if normalized := Normalize(test.Text); normalized != test.Normalized {
+			t.Errorf("Expected %q for %q, got %q\n", test.Normalized, test.Text, normalized)
+		}
+	}
+}
+
+func TestSearchableNormalized(t *testing.T) {
+	record := SearchableNormalized("A café on the Hauptstraße")
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"cafe", true},
+		{"CAFÉ", true},
+		{"HAUPTSTRASSE", true},
+		{`"cafe on"`, true},
+		{"NOT cafe", false},
+		{"tea", false},
+	} {
+		q, err := QueryParserOptions(test.Condition, Options{Normalizer: Normalize})
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
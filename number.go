@@ -0,0 +1,157 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Comparable is an optional interface for Searchable objects that hold numbers.
+
+Queries such as `price:>10` or `size:>1MB` call Compare, which should return
+true if the number in the field compares to value using op, e.g. the field is
+more than value for OpGreater.  Searchable objects that do not implement
+Comparable but do implement FieldValuer have the value of the field parsed as
+a number, with the same units as the query.  Others are asked whether the
+field contains the text of the comparison, such as `>10`.
+*/
+type Comparable interface {
+	Searchable
+	Compare(field string, op Op, value float64) (match bool)
+}
+
+// units are the suffixes allowed after numbers, with the power of Options.UnitBase they multiply by
+var units = map[string]int{
+	"B":  0,
+	"KB": 1,
+	"MB": 2,
+	"GB": 3,
+	"TB": 4,
+}
+
+// numberEnd returns the length of the number at the start of value, which may have a sign and decimal point
+func numberEnd(value string) int {
+	end := 0
+	if end < len(value) && (value[end] == '-' || value[end] == '+') {
+		end++
+	}
+	digits := false
+	for end < len(value) && (value[end] >= '0' && value[end] <= '9' || value[end] == '.') {
+		digits = digits || value[end] != '.'
+		end++
+	}
+	if !digits {
+		return 0
+	}
+	return end
+}
+
+// parseNumber parses a number with an optional unit such as 1.5MB.
+// isNumber is false if the value is not written as a number, err is set if it is but can't be understood.
+func (opts Options) parseNumber(value string) (n float64, isNumber bool, err error) {
+	end := numberEnd(value)
+	if end == 0 {
+		return 0, false, nil
+	}
+	suffix := value[end:]
+	for _, char := range suffix {
+		if !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z') {
+			return 0, false, nil
+		}
+	}
+	n, parseErr := strconv.ParseFloat(value[:end], 64)
+	if parseErr != nil {
+		return 0, true, fmt.Errorf("invalid number %q", value)
+	}
+	if suffix == "" {
+		return n, true, nil
+	}
+	power, known := units[strings.ToUpper(suffix)]
+	if !known {
+		return 0, true, unitError(suffix)
+	}
+	base := float64(opts.UnitBase)
+	if base == 0 {
+		base = 1024
+	}
+	for i := 0; i < power; i++ {
+		n *= base
+	}
+	return n, true, nil
+}
+
+// unitError reports a number whose unit is not one of units, such as the XB of 1XB
+type unitError string
+
+func (e unitError) Error() string {
+	return fmt.Sprintf("invalid unit %q", string(e))
+}
+
+/*
+checkUnits adds a ParseError to diagnostics for each term beneath n that is a
+comparison or range of numbers with a unit that is not known, such as
+size:>1XB.  The parser leaves these terms to be searched for as text.
+*/
+func checkUnits(n Node, opts Options, diagnostics []*ParseError) []*ParseError {
+	if term, ok := n.(*Term); ok {
+		if term.Kind != TermContains || term.Field == "" {
+			return diagnostics
+		}
+		var numbers []string
+		if op, rest := splitComparison(term.Phrase); op != 0 {
+			numbers = []string{rest}
+		} else if _, low, _, high, isRange := splitRange(term.Phrase); isRange {
+			numbers = []string{low, high}
+		}
+		for _, number := range numbers {
+			if _, _, err := opts.parseNumber(number); isUnitError(err) {
+				return append(diagnostics, &ParseError{
+					Pos:     term.Start,
+					Message: fmt.Sprintf("%v in %q, searching for it as text", err, term.Phrase),
+				})
+			}
+		}
+		return diagnostics
+	}
+	for _, child := range children(n) {
+		diagnostics = checkUnits(child, opts, diagnostics)
+	}
+	return diagnostics
+}
+
+// isUnitError returns true if err reports an unknown unit
+func isUnitError(err error) bool {
+	_, ok := err.(unitError)
+	return ok
+}
+
+// compareNumberText returns true if text, such as the value of a field, is a number that compares to value using op
+func (opts Options) compareNumberText(text string, op Op, value float64) bool {
+	n, isNumber, err := opts.parseNumber(strings.TrimSpace(text))
+	if !isNumber || err != nil {
+		return false
+	}
+	compared := 0
+	if n < value {
+		compared = -1
+	} else if n > value {
+		compared = 1
+	}
+	return compareOp(compared, op)
+}
+
+// mustCompareNumber returns true if the Searchable's number in the field compares to value using op
+func mustCompareNumber(field string, op Op, value float64, phrase string, opts Options, match MatchFunc) filter {
+	literal := op.String() + phrase
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case Comparable:
+			return s.Compare(field, op, value)
+		case FieldValuer:
+			text, present := s.FieldValue(field)
+			return present && opts.compareNumberText(text, op, value)
+		}
+		return contains(s, field, literal, match)
+	}
+}
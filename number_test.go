@@ -0,0 +1,121 @@
+package search
+
+import (
+	"testing"
+)
+
+type testSizedRecord map[string]float64
+
+func (r testSizedRecord) Contains(field, phrase string) (present bool) {
+	return false
+}
+
+func (r testSizedRecord) Compare(field string, op Op, value float64) (match bool) {
+	n, present := r[field]
+	if !present {
+		return false
+	}
+	switch op {
+	case OpLess:
+		return n < value
+	case OpLessEqual:
+		return n <= value
+	case OpGreater:
+		return n > value
+	case OpGreaterEqual:
+		return n >= value
+	}
+	return false
+}
+
+func TestNumberUnits(t *testing.T) {
+	large := testSizedRecord{"size": 2097152}
+	small := testSizedRecord{"size": 500 * 1024}
+	for _, test := range []struct {
+		Condition string
+		Large     bool
+		Small     bool
+	}{
+		{"size:>1MB", true, false},
+		{"size:>1mb", true, false},
+		{"size:<=2MB", true, true},
+		{"size:<2MB", false, true},
+		{"size:>=500KB", true, true},
+		{"size:>0.5GB", false, false},
+		{"size:>1048576", true, false},
+		{"size:>1MB OR size:<1KB", true, false},
+		{"NOT size:>1MB", false, true},
+	} {
+		query, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+			continue
+		}
+		if query.Search(large) != test.Large {
+			t.Errorf("Expected %v for %v against the large record\n", test.Large, test.Condition)
+		}
+		if query.Search(small) != test.Small {
+			t.Errorf("Expected %v for %v against the small record\n", test.Small, test.Condition)
+		}
+	}
+
+	query, err := QueryParserOptions("size:>2MB", Options{UnitBase: 1000})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(large) {
+		t.Errorf("Expected 2MB in decimal units to be less than 2097152\n")
+	}
+
+	if _, err := QueryParserErr("size:>1.2.3MB"); err == nil || err.(*ParseError).Pos != 5 {
+		t.Errorf("Expected a *ParseError at position 5 for size:>1.2.3MB, got %v\n", err)
+	}
+
+	// An unknown unit is reported as a diagnostic, and the comparison searched for as text
+	query, err = QueryParserErr("boat size:>1XB")
+	if err != nil {
+		t.Fatalf("Unexpected error for an unknown unit: %v\n", err)
+	}
+	if diagnostics := query.Diagnostics(); len(diagnostics) != 1 || diagnostics[0].Pos != 5 {
+		t.Errorf("Expected a diagnostic at position 5 for the unknown unit, got %v\n", diagnostics)
+	}
+	if !query.Search(SearchableString("boat size >1XB")) || query.Search(large) {
+		t.Errorf("Expected size:>1XB to be searched for as text\n")
+	}
+
+	// Searchables that can't compare numbers are asked for the text of the comparison
+	if !QueryParser("size:>1MB").Search(SearchableString("size >1MB")) {
+		t.Errorf("Expected the comparison text to be searched for\n")
+	}
+}
+
+func TestNumberFieldValues(t *testing.T) {
+	// Searchables with FieldValue have the value of the field parsed as a number
+	record := SearchableFields(map[string]string{"price": " 10 ", "size": "1.5MB", "title": "price >5"})
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"price:>5", true},
+		{"price:<5", false},
+		{"price:>=10", true},
+		{"price:>10", false},
+		{"price:[5 TO 10]", true},
+		{"price:[5 TO 10}", false},
+		{"price:{-2.5 TO 20]", true},
+		{"size:>1MB", true},
+		{"size:<1MB", false},
+		{"title:>5", false},
+		{"missing:>5", false},
+		{"NOT price:<5", true},
+	} {
+		query, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+			continue
+		}
+		if result := query.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
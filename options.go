@@ -0,0 +1,466 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+Options control how QueryParserOptions interprets a query.
+
+The zero value gives the same behaviour as QueryParser.
+*/
+type Options struct {
+	/*
+		FieldValueSets restricts fields to a closed vocabulary.
+
+		The key is the field name and the value lists every phrase that may be
+		searched for in that field.  A query such as `status:bogus` where `status`
+		only allows `active` and `pending` results in a ParseError.  Fields that
+		are not present in the map are unrestricted.
+	*/
+	FieldValueSets map[string][]string
+
+	/*
+		GraphemeAware only matches phrases that start and end on a grapheme
+		cluster boundary in the record, using ContainsGraphemes.  This stops an
+		emoji in a query matching part of a larger emoji sequence.
+
+		This is only applied to Searchable objects that implement MatchSearchable,
+		such as those returned by SearchableMatchString and SearchableMatchStringSlice.
+	*/
+	GraphemeAware bool
+
+	/*
+		CaseInsensitive ignores case when matching, so that `Merry` finds
+		`merry`.  Searchable objects that implement MatchSearchable are given a
+		MatchFunc that lower cases the text and phrase, such as ContainsLower,
+		while those that implement FoldSearchable are asked using
+		ContainsFold.  Others are asked using Contains, with the phrase as
+		written.
+	*/
+	CaseInsensitive bool
+
+	/*
+		WholeWord only matches phrases against whole words, using
+		ContainsWholeWords, so that `cat` finds `the cat sat` but not
+		`category`.  Quoted phrases must match whole words next to each other.
+		When set, GraphemeAware has no further effect as words never end part
+		way through a grapheme cluster.
+
+		As with GraphemeAware, this is only applied to Searchable objects that
+		implement MatchSearchable.
+	*/
+	WholeWord bool
+
+	/*
+		NormalizeWhitespace treats any run of whitespace, including line
+		breaks, as a single space in both the phrases of the query and the
+		text of records, so that `"goes here. More"` finds a record with a line
+		break after `here.`.  Combine it with WholeWord so that `"battle
+		fought"` does not also find `battle foughten`.
+
+		The phrases of the query are normalised for every Searchable, while the
+		text is only normalised by those that implement MatchSearchable.
+	*/
+	NormalizeWhitespace bool
+
+	/*
+		StrictLists rejects value lists with empty items, such as
+		`tag:(book,,leaflet,)`, with a ParseError.  By default empty items are
+		ignored, so the example searches for either `book` or `leaflet`.
+	*/
+	StrictLists bool
+
+	/*
+		DateLayouts are the layouts, as used by time.Parse, that dates in
+		comparisons such as `created:>01/15/2020` may be written in.  Each layout
+		is tried in turn.  A date that matches none of the layouts, or that
+		matches layouts giving different dates, results in a ParseError.
+
+		If DateLayouts is nil, DefaultDateLayouts is used.
+	*/
+	DateLayouts []string
+
+	/*
+		Now returns the time that relative dates, such as `created:>-7d`, are
+		measured from.  They are resolved once, when the query is parsed, so a
+		query kept for a long time should be parsed again to move them on.
+
+		If Now is nil, time.Now is used.
+	*/
+	Now func() time.Time
+
+	/*
+		IndexedFields lists the fields that have an index, such as an inverted
+		index of posting lists.  Terms searching these fields are returned by
+		Query.IndexableTerms, while others need a full scan.
+	*/
+	IndexedFields []string
+
+	/*
+		UnitBase is the multiplier between the units that may follow numbers in
+		comparisons such as `size:>1MB`, which are B, KB, MB, GB and TB.  Set it
+		to 1000 for decimal units.  A number with any other unit, such as
+		`size:>1XB`, is searched for as text and reported by Query.Diagnostics.
+
+		If UnitBase is zero, 1024 is used.
+	*/
+	UnitBase int
+
+	/*
+		Collator orders text in comparisons against words, such as `name:>m`,
+		so that accented letters sort as the language expects.  If Collator and
+		NewCollator are both nil, text is compared in byte order.
+
+		Every search of the query uses the same Collator, so it must be safe
+		for concurrent use if several goroutines search with the query at
+		once.  *collate.Collator is not, so use NewCollator for it instead.
+	*/
+	Collator Collator
+
+	/*
+		NewCollator returns a new Collator to order text as Collator does, for
+		collators that are not safe for concurrent use such as
+		`collate.New(language.Swedish)`.  Each comparison borrows a collator
+		that no other search is using, calling NewCollator when all of those
+		made so far are in use.  Only one of Collator and NewCollator may be
+		set.
+	*/
+	NewCollator func() Collator
+
+	/*
+		SpacedComparisons allows comparisons to be written with spaces around
+		the operator, so that `price >= 50` is the same as `price:>=50`.  This
+		is not the default as it changes the meaning of queries that search
+		for the words either side of an operator.
+	*/
+	SpacedComparisons bool
+
+	/*
+		EmptyMatchesNone makes a query with nothing to search for match no
+		records, rather than every record.  Queries are empty when they are
+		blank or only hold operators and empty groups, such as `  OR  NOT  ` or
+		`() OR`, as an OR or NOT without a term to apply to is ignored.
+	*/
+	EmptyMatchesNone bool
+
+	/*
+		NamedQueries are saved queries that can be included in others by name,
+		so that `@saved:books whale` must match the query saved as books and
+		contain whale.  The included query is searched using the options of the
+		query that includes it, but its terms are not changed again by options
+		such as Normalizer, DefaultField or FieldTransforms, which were applied
+		when it was parsed.  MaxTerms and MaxDepth count the terms and brackets
+		of the included queries along with those of the query itself.  A name
+		that is not in the map, a Query not returned by this package, or a saved
+		query that includes itself, results in a ParseError.  References are
+		allowed whatever AllowedFields holds.
+	*/
+	NamedQueries map[string]Query
+
+	/*
+		MaxSearchDepth limits how deeply slices returned by SearchableSliceOf,
+		and the slices nested within them, are searched.  Items nested more
+		deeply than the limit never match.
+
+		If MaxSearchDepth is zero, DefaultMaxSearchDepth is used.
+	*/
+	MaxSearchDepth int
+
+	/*
+		MaxOrBranches limits the number of alternatives in a single OR, such as
+		`a OR b OR c` or `tag:(a,b,c)`, to cap the work a query can ask for.
+		Alternatives beyond the limit are dropped and reported by
+		Query.Diagnostics rather than failing the query.
+
+		If MaxOrBranches is zero there is no limit.
+	*/
+	MaxOrBranches int
+
+	/*
+		MaxDepth limits how deeply brackets may be nested, so that `((a))` has
+		a depth of 2, to protect services that parse queries from untrusted
+		users.  Brackets nested beyond the limit result in a ParseError, found
+		before the groups inside them are built.
+
+		If MaxDepth is zero there is no limit.
+	*/
+	MaxDepth int
+
+	/*
+		MaxTerms limits the number of terms in a query, counting each item of
+		a value list such as `tag:(book,leaflet)` and each field of
+		`any(title,body):merry`.  A query with more terms results in a
+		ParseError.
+
+		If MaxTerms is zero there is no limit.
+	*/
+	MaxTerms int
+
+	/*
+		TreatMissingFieldAsMatch controls how terms restricted to a field, such as
+		`title:merry`, treat records that do not have the field at all.
+
+		By default a positive term does not match a record without the field and
+		a negated term, such as `NOT title:merry`, does.  When set, a term on a
+		missing field is treated as present, so `title:merry` matches and
+		`NOT title:merry` does not.  This is only applied to Searchable objects
+		that implement FieldExister, others are always asked using Contains.
+	*/
+	TreatMissingFieldAsMatch bool
+
+	/*
+		NormalizePunctuation replaces typographic punctuation in the phrases of
+		the query, such as the curly apostrophe in `it’s` or the dash in
+		`1990–2000`, with plain ASCII using NormalizePunctuation.  Records should
+		be normalised in the same way to match.
+	*/
+	NormalizePunctuation bool
+
+	/*
+		Normalizer is applied to the phrases of the query before searching, so
+		that a search for `cafe` can find `café`.  Normalize, built with the
+		xtext build tag, decomposes accents, removes them and folds case.
+		Records should be normalised in the same way to match, for example with
+		SearchableNormalized.  Phrases are normalised after NormalizePunctuation.
+	*/
+	Normalizer func(text string) string
+
+	/*
+		FieldTransforms are applied to the phrases of terms in the named field
+		before searching, such as strings.ToLower for an email field so that
+		`email:Bob@Example.com` finds `bob@example.com`.  The transform with the
+		key "" is applied to terms without a field.  Fields without a transform
+		are searched for as written.
+
+		The values of the fields in records need the same transforms applied,
+		for example by the getters given to FieldSearchableBuilder.
+	*/
+	FieldTransforms map[string]func(phrase string) string
+
+	/*
+		DefaultField is the field searched by terms written without one, so
+		that `merry` is the same as `title:merry` when DefaultField is "title".
+		This suits search boxes for a single field.  Wildcard, fuzzy and NEAR
+		terms are restricted in the same way, while terms written with a field
+		keep it.  If DefaultField is empty, terms without a field search the
+		whole record, as they do without Options.
+
+		DefaultField may not be set along with DefaultFields or ImplicitField,
+		which QueryParserOptions reports as an error.  Where options are not
+		checked, as by QueryFromFormOptions, DefaultFields is used before
+		DefaultField, and DefaultField before ImplicitField.
+	*/
+	DefaultField string
+
+	/*
+		ImplicitField is the earlier name for DefaultField, and is only used if
+		neither DefaultField nor DefaultFields is set.  It may not be set along
+		with either of them.
+
+		Deprecated: use DefaultField.
+	*/
+	ImplicitField string
+
+	/*
+		DefaultFields are the fields searched by terms written without one,
+		any of which may match, so that `merry` is the same as
+		`any(title,body):merry` when DefaultFields is ["title", "body"].
+		Fields left out, such as internal notes, are only searched by terms
+		that name them.  Operators apply to the term as a whole, so
+		`NOT merry` excludes records with `merry` in either field.
+
+		A nil or empty slice is the same as not setting it, leaving terms
+		without a field to DefaultField or to search the whole record.  When
+		DefaultFields has any fields, DefaultField and ImplicitField may not be
+		set, which QueryParserOptions reports as an error.  Where options are
+		not checked DefaultFields is used instead of either of them.
+	*/
+	DefaultFields []string
+
+	/*
+		FieldMultiValueMode chooses how QueryFromFormOptions combines several
+		values given for the same field.  Fields set to BoolAnd must contain
+		every value, such as the words a record is required to have, while
+		fields that are missing or set to BoolOr may contain any of them, such
+		as a choice of tags.
+	*/
+	FieldMultiValueMode map[string]BoolOp
+
+	/*
+		StopWords are dropped from the query, so that `the cat in the hat`
+		only needs `cat` and `hat`.  The keys are lower case words, and a term
+		is dropped if it is one of them once lowered.  Only terms written
+		without a field or quotes are dropped, so `"the cat"`, `title:the` and
+		`+the` are kept, as is the phrase after a NEAR.  DefaultEnglishStopWords
+		holds a set of common English words.
+	*/
+	StopWords map[string]bool
+
+	/*
+		StopWordsMatchNone makes a query written only of stop words, such as
+		`the`, match no records.  Otherwise it matches every record, as an
+		empty query does.
+	*/
+	StopWordsMatchNone bool
+
+	/*
+		Stemmer reduces each word of the terms in the query to its stem, such
+		as PorterStem, so that `running` finds `run` and `boats` finds `boat`.
+		Quoted phrases, and terms such as wildcards and comparisons, are
+		searched for as written.  Records need their text stemmed in the same
+		way, for example with SearchableStemmed.
+	*/
+	Stemmer func(word string) string
+
+	/*
+		AndKeyword, OrKeyword and NotKeyword are the words used as operators,
+		so that setting OrKeyword to `||` makes `merry || battle` find either
+		word.  Keywords are case sensitive unless CaseInsensitive is set, and
+		are only operators when not quoted.  Query.String writes the keywords
+		set here, so its text parses back with the same Options.
+
+		If a keyword is empty, AND, OR or NOT is used.  The three keywords
+		must differ, so QueryParserOptions returns an error if OrKeyword is
+		set to NOT while NotKeyword is left empty.
+	*/
+	AndKeyword string
+	OrKeyword  string
+	NotKeyword string
+
+	/*
+		AllowedFields lists the only fields a query may refer to, so that users
+		can not probe internal fields with a query such as `ssn:123`.  A query
+		that refers to any other field results in a ParseError naming the
+		field.  Terms without a field are always allowed, and the field of
+		`_exists_:title` is the one it names, while `_field_` patterns are only
+		allowed if _field_ is listed.
+
+		If AllowedFields is nil any field may be used, while an empty slice
+		allows no fields at all.
+	*/
+	AllowedFields []string
+
+	/*
+		NotScope chooses how many of the terms after a NOT it applies to.  By
+		default, NotSingleTerm, `NOT a b` excludes records with `a` and must
+		contain `b`, while NotRestOfClause excludes records with either.
+	*/
+	NotScope NotScope
+}
+
+// NotScope chooses the terms a NOT keyword applies to, see Options.NotScope
+type NotScope int
+
+const (
+	// NotSingleTerm applies NOT to the term or bracketed group that follows it, so `NOT a b` must contain `b`
+	NotSingleTerm NotScope = iota
+	// NotRestOfClause applies NOT to every term that follows it until the next OR or bracket, so `NOT a b` must
+	// contain neither `a` nor `b`.  OR binds more tightly than AND, so `NOT a b OR c` must not contain `a`, and either
+	// not contain `b` or contain `c`.  A NOT before a bracket applies to the bracketed group alone.
+	NotRestOfClause
+)
+
+// check returns an error if the options set conflict with each other
+func (opts Options) check() error {
+	if opts.Collator != nil && opts.NewCollator != nil {
+		return errors.New("search: only one of Options.Collator and Options.NewCollator may be set")
+	}
+	defaults := 0
+	for _, set := range []bool{len(opts.DefaultFields) > 0, opts.DefaultField != "", opts.ImplicitField != ""} {
+		if set {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return errors.New("search: only one of Options.DefaultFields, Options.DefaultField and Options.ImplicitField may be set")
+	}
+	kw := opts.operatorWords()
+	if kw.is(kw.and, kw.or) || kw.is(kw.and, kw.not) || kw.is(kw.or, kw.not) {
+		return fmt.Errorf("search: Options keywords %q, %q and %q must all differ", kw.and, kw.or, kw.not)
+	}
+	return nil
+}
+
+// matchFunc returns the MatchFunc needed for the options, or nil if the default matching should be used
+func (opts Options) matchFunc() MatchFunc {
+	match := opts.wordMatchFunc()
+	if !opts.NormalizeWhitespace {
+		return match
+	}
+	if match == nil {
+		match = strings.Contains
+	}
+	return func(text, phrase string) bool {
+		return match(collapseWhitespace(text), collapseWhitespace(phrase))
+	}
+}
+
+// wordMatchFunc returns the MatchFunc for the case and word options, or nil if the default matching should be used
+func (opts Options) wordMatchFunc() MatchFunc {
+	var match MatchFunc
+	switch {
+	case opts.WholeWord:
+		match = ContainsWholeWords
+	case opts.GraphemeAware:
+		match = ContainsGraphemes
+	}
+	switch {
+	case opts.CaseInsensitive && match == nil:
+		return ContainsLower
+	case opts.CaseInsensitive && opts.WholeWord:
+		return containsWholeWordsLower
+	case opts.CaseInsensitive:
+		return func(text, phrase string) bool {
+			return match(strings.ToLower(text), strings.ToLower(phrase))
+		}
+	}
+	return match
+}
+
+// operatorWords returns the operator words for the options
+func (opts Options) operatorWords() operatorWords {
+	kw := defaultKeywords
+	kw.fold = opts.CaseInsensitive
+	if opts.AndKeyword != "" {
+		kw.and = opts.AndKeyword
+	}
+	if opts.OrKeyword != "" {
+		kw.or = opts.OrKeyword
+	}
+	if opts.NotKeyword != "" {
+		kw.not = opts.NotKeyword
+	}
+	return kw
+}
+
+// allowedField returns false if the fields a query may refer to are restricted and do not include field.
+// References to NamedQueries, such as @saved:books, are always allowed.
+func (opts Options) allowedField(field string) bool {
+	if opts.AllowedFields == nil || field == "" || field == savedField && opts.NamedQueries != nil {
+		return true
+	}
+	for _, allowed := range opts.AllowedFields {
+		if allowed == field {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedFieldValue returns false if the field has a restricted set of values that does not include value
+func (opts Options) allowedFieldValue(field, value string) bool {
+	allowed, restricted := opts.FieldValueSets[field]
+	if !restricted || field == "" {
+		return true
+	}
+	for _, candidate := range allowed {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestFieldValueSets(t *testing.T) {
+	opts := Options{
+		FieldValueSets: map[string][]string{
+			"status": {"active", "pending"},
+		},
+	}
+	tn := &TestNote{Body: "A note about boats", Label: "active"}
+
+	query, err := QueryParserOptions("boats status:active", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error for allowed field value: %v\n", err)
+	}
+	if !query.Search(tn) {
+		t.Errorf("Expected allowed field value to match\n")
+	}
+
+	// Fields without a value set are unrestricted
+	if _, err := QueryParserOptions("boats other:anything", opts); err != nil {
+		t.Errorf("Unexpected error for unrestricted field: %v\n", err)
+	}
+
+	query, err = QueryParserOptions("boats OR status:bogus", opts)
+	if query != nil {
+		t.Errorf("Expected no query for disallowed field value\n")
+	}
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Expected a *ParseError, got %v\n", err)
+	}
+	if perr.Pos != 16 {
+		t.Errorf("Expected error at position 16, got %v\n", perr.Pos)
+	}
+	if perr.Error() != `search: "bogus" is not an allowed value for field status at position 16` {
+		t.Errorf("Unexpected error message %q\n", perr.Error())
+	}
+}
+
+func TestStrictLists(t *testing.T) {
+	if _, err := QueryParserOptions("tag:(book,leaflet)", Options{StrictLists: true}); err != nil {
+		t.Errorf("Unexpected error for a well formed list: %v\n", err)
+	}
+	for _, condition := range []string{"tag:(book, leaflet)", "tag:(book ,leaflet)", "tag:(book , leaflet)"} {
+		query, err := QueryParserOptions(condition, Options{StrictLists: true})
+		if err != nil {
+			t.Errorf("Unexpected error for spaces around commas in %v: %v\n", condition, err)
+		} else if text := query.String(); text != "tag:book OR tag:leaflet" {
+			t.Errorf("Expected the list of %v to be tag:book OR tag:leaflet, got %q\n", condition, text)
+		}
+	}
+	if _, err := QueryParserOptions("tag:(book,,leaflet,)", Options{}); err != nil {
+		t.Errorf("Unexpected error for empty items without StrictLists: %v\n", err)
+	}
+	for _, condition := range []string{"tag:(book,,leaflet)", "tag:(book,leaflet,)", "tag:(,)", "tag:(book, ,leaflet)"} {
+		_, err := QueryParserOptions(condition, Options{StrictLists: true})
+		if perr, ok := err.(*ParseError); !ok || perr.Pos != 4 {
+			t.Errorf("Expected a *ParseError at position 4 for %v, got %v\n", condition, err)
+		}
+	}
+}
+
+func TestOperatorKeywords(t *testing.T) {
+	opts := Options{OrKeyword: "||", AndKeyword: "&&", NotKeyword: "SANS"}
+	for _, test := range []struct {
+		Condition string
+		Record    string
+		Expected  bool
+	}{
+		{"merry || battle", "a battle fought", true},
+		{"merry || battle", "a sunny day", false},
+		{"merry && battle", "a merry battle", true},
+		{"merry && battle", "a battle fought", false},
+		{"battle SANS merry", "a merry battle", false},
+		{"battle SANS merry", "a battle fought", true},
+		// The default keywords are words like any other
+		{"merry OR battle", "a battle fought", false},
+		{"merry OR battle", "merry OR battle", true},
+		// Quoted keywords are searched for
+		{`"||" battle`, "a battle || fought", true},
+		// Keywords are case sensitive
+		{"battle sans merry", "a merry battle sans", true},
+	} {
+		query, err := QueryParserOptions(test.Condition, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := query.MatchString(test.Record); result != test.Expected {
+			t.Errorf("Expected %v for %v against %v, got %v\n", test.Expected, test.Condition, test.Record, result)
+		}
+	}
+
+	query, err := QueryParserOptions("battle sans merry", Options{NotKeyword: "SANS", CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if query.MatchString("a merry battle") {
+		t.Errorf("Expected sans to be NOT with CaseInsensitive\n")
+	}
+
+	// A configured keyword is never the field or value of a spaced comparison, while the default words may be
+	spaced := Options{NotKeyword: "SANS", SpacedComparisons: true}
+	for condition, expected := range map[string]string{
+		"battle SANS < 50": "battle SANS 50",
+		"battle NOT > 50":  "battle NOT:>50",
+	} {
+		query, err := QueryParserOptions(condition, spaced)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", condition, err)
+		}
+		if text := query.String(); text != expected {
+			t.Errorf("Expected %q for %v, got %q\n", expected, condition, text)
+		}
+	}
+
+	// Keywords that clash with each other, set or left as the defaults, are rejected
+	for _, clash := range []Options{
+		{OrKeyword: "NOT"},
+		{AndKeyword: "||", OrKeyword: "||"},
+		{NotKeyword: "or", CaseInsensitive: true},
+	} {
+		if _, err := QueryParserOptions("merry battle", clash); err == nil {
+			t.Errorf("Expected an error for keywords %+v\n", clash)
+		}
+	}
+	if _, err := QueryParserOptions("merry battle", Options{NotKeyword: "or"}); err != nil {
+		t.Errorf("Unexpected error for a keyword differing only in case: %v\n", err)
+	}
+
+	// The text of the query uses the keywords so that it parses back the same
+	for condition, expected := range map[string]string{
+		"merry || battle SANS fought": "merry || battle SANS fought",
+		`"||" "OR"`:                   `"||" OR`,
+	} {
+		query, err := QueryParserOptions(condition, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", condition, err)
+		}
+		if text := query.String(); text != expected {
+			t.Errorf("Expected %q for %v, got %q\n", expected, condition, text)
+		}
+	}
+}
+
+func TestAllowedFields(t *testing.T) {
+	opts := Options{AllowedFields: []string{"title", "body"}}
+	for _, condition := range []string{
+		"merry title:time",
+		"body:(beetle OR battle) NOT title:merry",
+		"any(title,body):merry _exists_:title _missing_:body",
+		"title!:merry",
+	} {
+		if _, err := QueryParserOptions(condition, opts); err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", condition, err)
+		}
+	}
+
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+		Field     string
+	}{
+		{"merry ssn:123", 6, "ssn"},
+		{"merry (title:time OR NOT ssn:123)", 25, "ssn"},
+		{"any(title,ssn):123", 0, "ssn"},
+		{"tag:(book,leaflet)", 0, "tag"},
+		{"merry -ssn:(123 OR 456)", 7, "ssn"},
+		{"ssn!:123", 0, "ssn"},
+		{"_exists_:ssn", 9, "ssn"},
+		{"_field_:ss*", 0, "_field_"},
+	} {
+		_, err := QueryParserOptions(test.Condition, opts)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Expected a *ParseError for %v, got %v\n", test.Condition, err)
+			continue
+		}
+		if perr.Pos != test.Pos || perr.Message != "field "+test.Field+" is not allowed" {
+			t.Errorf("Expected field %v not to be allowed at %v for %v, got %v\n", test.Field, test.Pos, test.Condition, perr)
+		}
+	}
+
+	if _, err := QueryParserOptions("title:merry", Options{AllowedFields: []string{}}); err == nil {
+		t.Errorf("Expected an error when no fields are allowed\n")
+	}
+	if _, err := QueryParserOptions("merry", Options{AllowedFields: []string{}}); err != nil {
+		t.Errorf("Unexpected error for a term without a field: %v\n", err)
+	}
+}
+
+func TestNotScope(t *testing.T) {
+	for _, test := range []struct {
+		Condition   string
+		SingleTerm  string
+		RestOfScope string
+	}{
+		{"NOT merry battle", "NOT merry battle", "-merry -battle"},
+		{"NOT merry battle OR time", "NOT merry battle OR time", "-merry -battle OR time"},
+		{"NOT merry OR battle time", "NOT merry OR battle time", "-merry OR battle time"},
+		{"NOT (merry battle) time", "NOT (merry battle) time", "NOT (merry battle) time"},
+		{"NOT merry (battle time) fought", "NOT merry battle time fought", "-merry battle time fought"},
+		{"(NOT merry battle) time", "NOT merry battle time", "-merry -battle time"},
+		{"beetle NOT merry -battle +time", "beetle NOT merry NOT battle time", "beetle -merry -battle -time"},
+	} {
+		for scope, expected := range map[NotScope]string{NotSingleTerm: test.SingleTerm, NotRestOfClause: test.RestOfScope} {
+			q, err := QueryParserOptions(test.Condition, Options{NotScope: scope})
+			if err != nil {
+				t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+			}
+			if text := q.String(); text != expected {
+				t.Errorf("Expected %v for %v with NotScope %v, got %v\n", expected, test.Condition, scope, text)
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		Record      string
+		SingleTerm  bool
+		RestOfScope bool
+	}{
+		{"battle", true, false},
+		{"battle time", true, true},
+		{"time", true, true},
+		{"merry time", false, false},
+		{"nothing here", false, true},
+	} {
+		for scope, expected := range map[NotScope]bool{NotSingleTerm: test.SingleTerm, NotRestOfClause: test.RestOfScope} {
+			q, err := QueryParserOptions("NOT merry battle OR time", Options{NotScope: scope})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			if result := q.MatchString(test.Record); result != expected {
+				t.Errorf("Expected %v for NOT merry battle OR time against %v with NotScope %v, got %v\n", expected, test.Record, scope, result)
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build go1.18
+// +build go1.18
+
+package search
+
+/*
+Predicate turns the query into a function reporting whether a value of type T
+matches, for use with functions such as slices.DeleteFunc.
+
+contains is called in the same way as Searchable.Contains, with the value being
+searched, so values do not need to be wrapped in a Searchable by the caller.
+*/
+func Predicate[T any](q Query, contains func(value T, field, phrase string) (present bool)) func(value T) (match bool) {
+	return func(value T) bool {
+		return q.Search(SearchableFunc(func(field, phrase string) bool {
+			return contains(value, field, phrase)
+		}))
+	}
+}
@@ -0,0 +1,52 @@
+//go:build go1.18
+// +build go1.18
+
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+type testNovel struct {
+	Title  string
+	Author string
+}
+
+func testNovelContains(novel testNovel, field, phrase string) bool {
+	switch field {
+	case "title":
+		return strings.Contains(novel.Title, phrase)
+	case "author":
+		return strings.Contains(novel.Author, phrase)
+	}
+	return strings.Contains(novel.Title, phrase) || strings.Contains(novel.Author, phrase)
+}
+
+func TestPredicate(t *testing.T) {
+	novels := []testNovel{
+		{Title: "Moby Dick", Author: "Herman Melville"},
+		{Title: "Billy Budd", Author: "Herman Melville"},
+		{Title: "The Whale Rider", Author: "Witi Ihimaera"},
+	}
+	for _, test := range []struct {
+		Condition string
+		Titles    []string
+	}{
+		{"author:Melville", []string{"Moby Dick", "Billy Budd"}},
+		{"author:Melville NOT Budd", []string{"Moby Dick"}},
+		{"Whale OR Moby", []string{"Moby Dick", "The Whale Rider"}},
+		{"title:Melville", nil},
+	} {
+		matches := Predicate(QueryParser(test.Condition), testNovelContains)
+		var titles []string
+		for _, novel := range novels {
+			if matches(novel) {
+				titles = append(titles, novel.Title)
+			}
+		}
+		if strings.Join(titles, ",") != strings.Join(test.Titles, ",") {
+			t.Errorf("Expected %v for %v, got %v\n", test.Titles, test.Condition, titles)
+		}
+	}
+}
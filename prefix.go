@@ -0,0 +1,49 @@
+package search
+
+import (
+	"strings"
+)
+
+// prefixOp starts the value of a term that must be at the start of the field, as in title:^="Once upon"
+const prefixOp = "^="
+
+/*
+PrefixSearchable is an optional interface for Searchable objects that can
+check the start of a field, used for terms such as `title:^="Once upon"`.
+
+Searchable objects that implement MatchSearchable are instead given a
+MatchFunc that checks the start of the text, so only need PrefixSearchable if
+they match in some other way.  Others are asked using Contains, so the phrase
+may be found anywhere in the field.
+*/
+type PrefixSearchable interface {
+	Searchable
+	/*
+		StartsWith returns true if the named field starts with the phrase.
+	*/
+	StartsWith(field, phrase string) (present bool)
+}
+
+// prefixFunc returns a MatchFunc that reports whether text starts with phrase, applying the matching options
+func (opts Options) prefixFunc() MatchFunc {
+	return func(text, phrase string) bool {
+		if opts.CaseInsensitive {
+			text, phrase = strings.ToLower(text), strings.ToLower(phrase)
+		}
+		return strings.HasPrefix(text, phrase) && (!opts.GraphemeAware || graphemeBoundary(text, len(phrase))) &&
+			(!opts.WholeWord || wordBoundary(text, len(phrase)))
+	}
+}
+
+// mustStartWith returns true if the Searchable's field starts with the phrase
+func mustStartWith(field, phrase string, prefix MatchFunc) filter {
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case PrefixSearchable:
+			return s.StartsWith(field, phrase)
+		case MatchSearchable:
+			return s.ContainsMatch(field, phrase, prefix)
+		}
+		return s.Contains(field, phrase)
+	}
+}
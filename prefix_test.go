@@ -0,0 +1,76 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+// testPrefixRecord only checks the start of its title through StartsWith
+type testPrefixRecord struct {
+	Title string
+}
+
+func (r testPrefixRecord) Contains(field, phrase string) (present bool) {
+	return strings.Contains(r.Title, phrase)
+}
+
+func (r testPrefixRecord) StartsWith(field, phrase string) (present bool) {
+	return field == "title" && strings.HasPrefix(r.Title, phrase)
+}
+
+func TestStartsWith(t *testing.T) {
+	title := "Once upon a very merry time"
+	fields := NewFieldSearchable().Field("title", func() string { return title }).Build()
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{`title:^="Once upon"`, true},
+		{`title:^="upon a"`, false},
+		{`title:"upon a"`, true},
+		{`title:^=Once`, true},
+		{`NOT title:^="upon a"`, true},
+		{`title:^="once upon"`, false},
+		{`title:(^=merry OR ^=Once)`, true},
+		{`body:^=Once`, false},
+	} {
+		query, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := query.Search(fields); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+		if result := query.Search(testPrefixRecord{title}); result != test.Match {
+			t.Errorf("Expected %v for %v using StartsWith, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+
+	query, err := QueryParserOptions(`title:^="once upon"`, Options{CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(fields) {
+		t.Errorf("Expected case to be ignored with CaseInsensitive\n")
+	}
+
+	term := QueryParser(`title:^="Once upon"`).NodeAt(0).(*Term)
+	if term.Kind != TermPrefix || term.Phrase != "Once upon" {
+		t.Errorf("Expected a TermPrefix for Once upon, got %v %v\n", term.Kind, term.Phrase)
+	}
+	if text := QueryParser(`title:^="Once upon"`).String(); text != `title:^="Once upon"` {
+		t.Errorf("Expected the prefix to be written back, got %v\n", text)
+	}
+
+	// Within quotes ^= is part of the phrase
+	term = QueryParser(`title:"^=x"`).NodeAt(0).(*Term)
+	if term.Kind != TermContains || term.Phrase != "^=x" {
+		t.Errorf("Expected a TermContains for ^=x, got %v %v\n", term.Kind, term.Phrase)
+	}
+	if !QueryParser(`title:"^=x"`).Search(SearchableFields(map[string]string{"title": "a ^=x b"})) {
+		t.Errorf("Expected a quoted ^= to be searched for as written\n")
+	}
+	if text := QueryParser(`title:"^=x"`).String(); text != `title:\^=x` {
+		t.Errorf("Expected the ^= to be escaped when written back, got %v\n", text)
+	}
+}
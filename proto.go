@@ -0,0 +1,102 @@
+//go:build protobuf
+// +build protobuf
+
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+/*
+SearchableProto makes a protocol buffer message Searchable.  It is only built
+with the protobuf build tag, so that the package does not otherwise depend on
+google.golang.org/protobuf.
+
+Fields are dotted paths of proto field names into the message, so
+`author.name:Smith` searches the `name` field of the `author` message.  Where
+a path passes through a repeated field the field matches if the path matches
+in any element, and a path through a map field matches in any of its values.
+A path that ends at a message searches every field set beneath it.
+
+A term without a field searches every field set in the message.  Enums are
+matched using the names of their values and other scalars using their text
+as formatted by fmt.Sprint.
+*/
+func SearchableProto(msg proto.Message) Searchable {
+	m := msg.ProtoReflect()
+	return SearchableMatchFunc(func(field, phrase string, match MatchFunc) bool {
+		var path []string
+		if field != "" {
+			path = strings.Split(field, ".")
+		}
+		return anyProtoLeaf(m, path, func(text string) bool {
+			return match(text, phrase)
+		})
+	})
+}
+
+// anyProtoLeaf calls found for each value reached by the path through the message until it returns true.
+// An empty path reaches every value set beneath the message.
+func anyProtoLeaf(m protoreflect.Message, path []string, found func(text string) bool) bool {
+	if len(path) == 0 {
+		result := false
+		m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			result = anyProtoField(fd, v, nil, found)
+			return !result
+		})
+		return result
+	}
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if fd == nil || !m.Has(fd) {
+		return false
+	}
+	return anyProtoField(fd, m.Get(fd), path[1:], found)
+}
+
+// anyProtoField calls found for each value reached by the path through the value of the field
+func anyProtoField(fd protoreflect.FieldDescriptor, v protoreflect.Value, path []string, found func(text string) bool) bool {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		for i := 0; i < list.Len(); i++ {
+			if anyProtoValue(fd, list.Get(i), path, found) {
+				return true
+			}
+		}
+		return false
+	case fd.IsMap():
+		result := false
+		v.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+			result = anyProtoValue(fd.MapValue(), value, path, found)
+			return !result
+		})
+		return result
+	}
+	return anyProtoValue(fd, v, path, found)
+}
+
+// anyProtoValue calls found for a single value of the field, or each value beneath it if it is a message
+func anyProtoValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, path []string, found func(text string) bool) bool {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return anyProtoLeaf(v.Message(), path, found)
+	}
+	if len(path) > 0 {
+		// The path continues beyond a scalar, so can not match
+		return false
+	}
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if value := fd.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return found(string(value.Name()))
+		}
+		return found(fmt.Sprint(int32(v.Enum())))
+	case protoreflect.BytesKind:
+		return found(string(v.Bytes()))
+	}
+	return found(fmt.Sprint(v.Interface()))
+}
@@ -0,0 +1,51 @@
+//go:build protobuf
+// +build protobuf
+
+package search
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestSearchableProto(t *testing.T) {
+	msg := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("library.proto"),
+		Package: proto.String("library"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Book"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("title"), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+					{Name: proto.String("pages"), Number: proto.Int32(42)},
+				},
+			},
+			{Name: proto.String("Author")},
+		},
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/library")},
+	}
+	record := SearchableProto(msg)
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"name:library.proto", true},
+		{"message_type.name:Author", true},
+		{"message_type.name:Shelf", false},
+		{"message_type.field.name:pages", true},
+		{"message_type.field.number:42", true},
+		{"message_type.field.type:TYPE_STRING", true},
+		{"options.go_package:example.com", true},
+		{"options:example.com", true},
+		{"name.missing:library", false},
+		{"nothing:library", false},
+		{"Author TYPE_STRING", true},
+		{"NOT message_type.name:Book", false},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The operators that join phrases that must be close together, as in whale NEAR/5 boat and whale ONEAR/5 boat
+const (
+	nearOperator        = "NEAR/"
+	orderedNearOperator = "ONEAR/"
+)
+
+/*
+Proximitable is an optional interface for Searchable objects that can check
+how close phrases are to each other, used for queries such as
+`"climate change" NEAR/5 policy`.
+
+Searchable objects that implement MatchSearchable, such as SearchableMatchString
+and SearchableMatchStringSlice, are instead given a MatchFunc that checks the words
+of their text using WordsNear, so only need Proximitable if they match in some
+other way.  Others, including SearchableString and SearchableStringSlice, are
+asked whether they contain each of the phrases, wherever they are; use
+SearchableMatchString or SearchableMatchStringSlice to check the distance
+between words in strings.
+*/
+type Proximitable interface {
+	Searchable
+	/*
+		Near returns true if each of the terms is found in the named field in
+		either order, with at most distance words between it and the term before
+		it in the list.  A field of "" means any field.
+	*/
+	Near(field string, terms []string, distance int) (present bool)
+}
+
+/*
+OrderedProximitable is an optional interface, like Proximitable, for
+Searchable objects that can check that phrases appear in order, used for
+queries such as `climate ONEAR/2 policy`.
+*/
+type OrderedProximitable interface {
+	Searchable
+	/*
+		NearInOrder returns true if each of the terms is found in the named field
+		after the term before it in the list, with at most distance words
+		between them.  A field of "" means any field.
+	*/
+	NearInOrder(field string, terms []string, distance int) (present bool)
+}
+
+/*
+WordsNear returns true if each of the phrases is found in text with at most
+distance words between it and the phrase before it in the list, so for
+"climate change" and "policy" with a distance of 5 up to five words may come
+between `change` and `policy`.  If ordered is true each phrase must come after
+the one before it, otherwise they may be in either order.  The words of a
+phrase must be next to each other and phrases can not share words.
+
+Words are found as they are by ContainsWholeWords.  Phrases without any
+letters or digits are never found.
+*/
+func WordsNear(text string, phrases []string, distance int, ordered bool) (found bool) {
+	words := strings.FieldsFunc(text, notWordChar)
+	// starts holds the positions in words of each phrase, and lengths how many words they have
+	starts := make([][]int, len(phrases))
+	lengths := make([]int, len(phrases))
+	for i, phrase := range phrases {
+		want := strings.FieldsFunc(phrase, notWordChar)
+		if len(want) == 0 {
+			return false
+		}
+		lengths[i] = len(want)
+		for start := 0; start+len(want) <= len(words); start++ {
+			if wordsAt(words, start, want) {
+				starts[i] = append(starts[i], start)
+			}
+		}
+		if len(starts[i]) == 0 {
+			return false
+		}
+	}
+	for _, start := range starts[0] {
+		if nearFrom(starts, lengths, 1, start, distance, ordered) {
+			return true
+		}
+	}
+	return false
+}
+
+// wordsAt returns true if want matches words from start
+func wordsAt(words []string, start int, want []string) bool {
+	for i, word := range want {
+		if words[start+i] != word {
+			return false
+		}
+	}
+	return true
+}
+
+// nearFrom returns true if phrase i and those after it can be found close enough, given the previous phrase is at previous
+func nearFrom(starts [][]int, lengths []int, i, previous, distance int, ordered bool) bool {
+	if i == len(starts) {
+		return true
+	}
+	previousEnd := previous + lengths[i-1]
+	for _, start := range starts[i] {
+		end := start + lengths[i]
+		var gap int
+		switch {
+		case start >= previousEnd:
+			gap = start - previousEnd
+		case !ordered && end <= previous:
+			gap = previous - end
+		default:
+			// Before the previous phrase when order matters, or sharing its words
+			continue
+		}
+		if gap <= distance && nearFrom(starts, lengths, i+1, start, distance, ordered) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitNear returns the distance of a NEAR/5 or ONEAR/5 operator, ok is false if text is not one
+func splitNear(text string) (distance int, ordered bool, ok bool) {
+	rest := strings.TrimPrefix(text, nearOperator)
+	if rest == text {
+		if rest = strings.TrimPrefix(text, orderedNearOperator); rest == text {
+			return 0, false, false
+		}
+		ordered = true
+	}
+	distance, err := strconv.Atoi(rest)
+	if err != nil || distance < 0 || rest[0] == '+' || rest[0] == '-' {
+		return 0, false, false
+	}
+	return distance, ordered, true
+}
+
+// joinNear joins the term n to the plain phrase or NEAR term before it, as in whale NEAR/5 boat.
+// ok is false if either is not a phrase that can be joined, such as a negated or optional term.
+func joinNear(previous, n Node, distance int, ordered bool) (near *Term, ok bool) {
+	kind := TermNear
+	if ordered {
+		kind = TermNearOrdered
+	}
+	first, isTerm := previous.(*Term)
+	second, isSecondTerm := n.(*Term)
+	if !isTerm || !isSecondTerm || first.Optional || second.Optional || second.Kind != TermContains || first.Field != second.Field {
+		return nil, false
+	}
+	switch {
+	case first.Kind == TermContains:
+		near = &Term{Field: first.Field, Phrase: first.Phrase, Start: first.Start}
+	case first.Kind == kind && first.Distance == distance:
+		// A chain such as whale NEAR/5 boat NEAR/5 shark
+		near = first
+	default:
+		return nil, false
+	}
+	near.Kind, near.Distance, near.End = kind, distance, second.End
+	last := near
+	for last.Near != nil {
+		last = last.Near
+	}
+	last.Near = second
+	return near, true
+}
+
+// nearPhrases returns the phrases of a NEAR term in order
+func nearPhrases(term *Term) (phrases []string) {
+	for ; term != nil; term = term.Near {
+		phrases = append(phrases, term.Phrase)
+	}
+	return phrases
+}
+
+// mustBeWithin returns true if the Searchable has the phrases within distance words of each other.
+// If lower is set the text given to a MatchFunc and the phrases are compared in lower case.
+func mustBeWithin(field string, phrases []string, distance int, ordered bool, lower bool) filter {
+	near := func(text, _ string) bool {
+		return WordsNear(text, phrases, distance, ordered)
+	}
+	if lower {
+		lowered := make([]string, len(phrases))
+		for i, phrase := range phrases {
+			lowered[i] = strings.ToLower(phrase)
+		}
+		near = func(text, _ string) bool {
+			return WordsNear(strings.ToLower(text), lowered, distance, ordered)
+		}
+	}
+	return func(s Searchable) bool {
+		if p, ok := s.(OrderedProximitable); ok && ordered {
+			return p.NearInOrder(field, phrases, distance)
+		}
+		if p, ok := s.(Proximitable); ok && !ordered {
+			return p.Near(field, phrases, distance)
+		}
+		if ms, ok := s.(MatchSearchable); ok {
+			return ms.ContainsMatch(field, phrases[0], near)
+		}
+		for _, phrase := range phrases {
+			if !s.Contains(field, phrase) {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,195 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+// testProximityRecord records the terms it is asked to find near each other
+type testProximityRecord struct {
+	asked   []string
+	ordered bool
+}
+
+func (r *testProximityRecord) Contains(field, phrase string) (present bool) {
+	return false
+}
+
+func (r *testProximityRecord) Near(field string, terms []string, distance int) (present bool) {
+	r.asked = append([]string{field}, terms...)
+	return true
+}
+
+func (r *testProximityRecord) NearInOrder(field string, terms []string, distance int) (present bool) {
+	r.asked, r.ordered = append([]string{field}, terms...), true
+	return true
+}
+
+func TestWordsNear(t *testing.T) {
+	for _, test := range []struct {
+		Text     string
+		Phrases  []string
+		Distance int
+		Ordered  bool
+		Near     bool
+	}{
+		{"climate change is the focus of new policy", []string{"climate change", "policy"}, 5, false, true},
+		{"climate change is the focus of new policy", []string{"climate change", "policy"}, 4, false, false},
+		{"policy on climate change", []string{"climate change", "policy"}, 1, false, true},
+		{"policy on climate change", []string{"climate change", "policy"}, 1, true, false},
+		{"policy on climate change", []string{"climate change", "policy"}, 0, false, false},
+		{"whale boat", []string{"whale", "boat"}, 0, true, true},
+		{"whale, boat!", []string{"whale", "boat"}, 0, true, true},
+		{"whale and boat and shark", []string{"whale", "boat", "shark"}, 1, true, true},
+		{"whale and shark and boat", []string{"whale", "boat", "shark"}, 1, true, false},
+		{"whale and shark and boat", []string{"whale", "boat", "shark"}, 3, false, true},
+		{"shark and boat and whale", []string{"whale", "boat", "shark"}, 1, false, true},
+		{"whale far far away boat whale", []string{"whale", "boat"}, 0, true, false},
+		{"whale far far away boat whale", []string{"whale", "boat"}, 0, false, true},
+		{"whale", []string{"whale", "whale"}, 5, false, false},
+		{"whale whale", []string{"whale", "whale"}, 0, false, true},
+		{"whales and boats", []string{"whale", "boat"}, 5, false, false},
+		{"whale boat", []string{"whale", "!"}, 5, false, false},
+	} {
+		if near := WordsNear(test.Text, test.Phrases, test.Distance, test.Ordered); near != test.Near {
+			t.Errorf("Expected %v for %v within %v words (ordered %v) in %v, got %v\n", test.Near, test.Phrases, test.Distance, test.Ordered, test.Text, near)
+		}
+	}
+}
+
+func TestNear(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Text      string
+		Match     bool
+	}{
+		{`"climate change" NEAR/5 policy`, "climate change is the focus of new policy", true},
+		{`"climate change" NEAR/4 policy`, "climate change is the focus of new policy", false},
+		{`"climate change" NEAR/5 policy`, "policy on climate change", true},
+		{`"climate change" ONEAR/5 policy`, "policy on climate change", false},
+		{`"climate change" ONEAR/5 policy`, "climate change policy", true},
+		{"whale NEAR/1 boat NEAR/1 shark", "shark by boat by whale", true},
+		{"whale NEAR/1 boat NEAR/1 shark", "shark by whale by the boat", false},
+		{"whale NEAR/1 boat shark", "whale by boat and a shark", true},
+		{"whale NEAR/1 boat OR shark", "whale and a boat", false},
+		{"whale NEAR/1 boat OR shark", "shark", true},
+		{"(whale NEAR/1 boat) shark", "whale by boat shark", true},
+		{"NOT (whale NEAR/1 boat)", "whale by boat", false},
+		{"title:whale NEAR/1 title:boat", "whale by boat", true},
+		{"Whale NEAR/1 BOAT", "whale by boat", false},
+		{`"NEAR/1" whale`, "NEAR/1 whale", true},
+		{"near/1 whale", "near/1 whale", true},
+	} {
+		q, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.Search(SearchableMatchString(test.Text)); result != test.Match {
+			t.Errorf("Expected %v for %v in %v, got %v\n", test.Match, test.Condition, test.Text, result)
+		}
+	}
+
+	// The matching string Searchables check the distance between the words, rather than only that each is present
+	far := "a whale swam a long way from the boat"
+	if q := QueryParser("whale NEAR/1 boat"); q.Search(SearchableMatchString(far)) || q.Search(SearchableMatchStringSlice([]string{far})) {
+		t.Errorf("Expected words far apart not to be near in SearchableMatchString and SearchableMatchStringSlice\n")
+	}
+	if q := QueryParser("whale NEAR/6 boat"); !q.Search(SearchableMatchString(far)) || !q.Search(SearchableMatchStringSlice([]string{"", far})) {
+		t.Errorf("Expected words within the distance to be near in SearchableMatchString and SearchableMatchStringSlice\n")
+	}
+
+	// SearchableString and SearchableStringSlice can only be asked for each phrase, so the distance needs the Match variants
+	if q := QueryParser("whale NEAR/1 boat"); !q.Search(SearchableString(far)) || !q.Search(SearchableStringSlice([]string{far})) {
+		t.Errorf("Expected SearchableString and SearchableStringSlice to only check that each phrase is present\n")
+	}
+
+	// Case is ignored in the phrases and text when asked
+	q, _ := QueryParserOptions("Whale NEAR/1 BOAT", Options{CaseInsensitive: true})
+	if !q.Search(SearchableMatchString("a whale by Boat")) {
+		t.Errorf("Expected a case insensitive match\n")
+	}
+}
+
+func TestNearParsing(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Result    string
+		Kind      TermKind
+		Phrases   []string
+	}{
+		{`"climate change" NEAR/5 policy`, `"climate change" NEAR/5 policy`, TermNear, []string{"climate change", "policy"}},
+		{"whale ONEAR/0 boat", "whale ONEAR/0 boat", TermNearOrdered, []string{"whale", "boat"}},
+		{"whale NEAR/2 boat NEAR/2 shark", "whale NEAR/2 boat NEAR/2 shark", TermNear, []string{"whale", "boat", "shark"}},
+		{"title:whale NEAR/2 title:boat", "title:whale NEAR/2 title:boat", TermNear, []string{"whale", "boat"}},
+		{`whale NEAR/2 tag\:special`, `whale NEAR/2 tag\:special`, TermNear, []string{"whale", "tag:special"}},
+	} {
+		q, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		root := q.(*query).root
+		term, ok := root.Children[0].(*Term)
+		if len(root.Children) != 1 || !ok {
+			t.Fatalf("Expected a single term for %v, got %v\n", test.Condition, root.Children)
+		}
+		if phrases := nearPhrases(term); term.Kind != test.Kind || strings.Join(phrases, "|") != strings.Join(test.Phrases, "|") {
+			t.Errorf("Expected kind %v with %v for %v, got kind %v with %v\n", test.Kind, test.Phrases, test.Condition, term.Kind, phrases)
+		}
+		if start, end := term.Span(); start != 0 || end != len(test.Condition) {
+			t.Errorf("Expected %v to span the query, got %v to %v\n", test.Condition, start, end)
+		}
+	}
+}
+
+func TestNearErrors(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+	}{
+		{"NEAR/5 whale", 0},
+		{"whale NEAR/5", 6},
+		{"whale NEAR/5 NOT boat", 6},
+		{"whale NEAR/5 -boat", 6},
+		{"NOT whale NEAR/5 boat", 10},
+		{"whale NEAR/5 ?boat", 6},
+		{"whale NEAR/5 (boat)", 6},
+		{"whale NEAR/5 OR boat", 6},
+		{"title:whale NEAR/5 boat", 12},
+		{"whale NEAR/5 boat~1", 6},
+		{"whale NEAR/5 boat ONEAR/5 shark", 18},
+		{"whale NEAR/5 boat NEAR/4 shark", 18},
+	} {
+		_, err := QueryParserErr(test.Condition)
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Expected a ParseError for %v, got %v\n", test.Condition, err)
+			continue
+		}
+		if parseErr.Pos != test.Pos {
+			t.Errorf("Expected an error at %v for %v, got %v\n", test.Pos, test.Condition, parseErr)
+		}
+	}
+}
+
+func TestProximitable(t *testing.T) {
+	record := &testProximityRecord{}
+	if !QueryParser("title:whale NEAR/3 title:boat").Search(record) || record.ordered {
+		t.Errorf("Expected Near to be asked\n")
+	}
+	if strings.Join(record.asked, "|") != "title|whale|boat" {
+		t.Errorf("Expected Near to be asked for whale and boat in title, got %v\n", record.asked)
+	}
+	record = &testProximityRecord{}
+	if !QueryParser("whale ONEAR/3 boat").Search(record) || !record.ordered {
+		t.Errorf("Expected NearInOrder to be asked\n")
+	}
+
+	// Searchables without either interface need every phrase
+	contains := SearchableFunc(func(field, phrase string) bool { return phrase == "whale" || phrase == "boat" })
+	if !QueryParser("whale NEAR/1 boat").Search(contains) || QueryParser("whale NEAR/1 shark").Search(contains) {
+		t.Errorf("Expected Contains to be asked for each phrase\n")
+	}
+}
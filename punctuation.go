@@ -0,0 +1,57 @@
+package search
+
+import (
+	"strings"
+)
+
+// punctuationReplacer maps typographic punctuation to the plain ASCII that is usually typed instead
+var punctuationReplacer = strings.NewReplacer(
+	"‘", "'", // left single quotation mark
+	"’", "'", // right single quotation mark
+	"‚", "'", // single low-9 quotation mark
+	"‛", "'", // single high-reversed-9 quotation mark
+	"′", "'", // prime
+	"“", `"`, // left double quotation mark
+	"”", `"`, // right double quotation mark
+	"„", `"`, // double low-9 quotation mark
+	"‟", `"`, // double high-reversed-9 quotation mark
+	"″", `"`, // double prime
+	"‐", "-", // hyphen
+	"‑", "-", // non-breaking hyphen
+	"‒", "-", // figure dash
+	"–", "-", // en dash
+	"—", "-", // em dash
+	"―", "-", // horizontal bar
+	"−", "-", // minus sign
+	"…", "...", // horizontal ellipsis
+	" ", " ", // no-break space
+)
+
+/*
+NormalizePunctuation replaces typographic punctuation, such as curly quotes,
+en and em dashes and ellipses, with the plain ASCII characters usually typed in
+their place.
+
+Options.NormalizePunctuation applies it to the phrases in a query.  Apply it to
+the text of records as well, for example with TransformStringSlice, so that
+text pasted from either side matches.
+*/
+func NormalizePunctuation(text string) string {
+	return punctuationReplacer.Replace(text)
+}
+
+// normalizeTerms applies normalize to the phrase of each term beneath n, other than those naming a field or holding a regular expression
+func normalizeTerms(n Node, normalize func(text string) string) {
+	if term, ok := n.(*Term); ok {
+		if term.Kind == TermFieldName || term.Kind == TermFieldExists || term.Kind == TermRegex {
+			return
+		}
+		for ; term != nil; term = term.Near {
+			term.Phrase = normalize(term.Phrase)
+		}
+		return
+	}
+	for _, child := range children(n) {
+		normalizeTerms(child, normalize)
+	}
+}
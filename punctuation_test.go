@@ -0,0 +1,79 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizePunctuation(t *testing.T) {
+	for _, test := range []struct {
+		Text       string
+		Normalized string
+	}{
+		{"it’s", "it's"},
+		{"“merry time”", `"merry time"`},
+		{"1990–2000 — and after…", "1990-2000 - and after..."},
+		{"plain text", "plain text"},
+	} {
+		if normalized := NormalizePunctuation(test.Text); normalized != test.Normalized {
+			t.Errorf("Expected %q for %q, got %q\n", test.Normalized, test.Text, normalized)
+		}
+	}
+}
+
+func TestOptionsNormalizePunctuation(t *testing.T) {
+	record := SearchableMatchStringSlice([]string{`He said "it's a merry-time" in 1990-2000`})
+	for _, test := range []struct {
+		Condition string
+		Plain     bool
+		Normal    bool
+	}{
+		{"it’s", false, true},
+		{"“merry—time”", false, true},
+		{"1990–2000", false, true},
+		{"merry-time it's", true, true},
+		{"NOT it’s", true, false},
+	} {
+		if QueryParser(test.Condition).Search(record) != test.Plain {
+			t.Errorf("Expected %v for %v without normalising\n", test.Plain, test.Condition)
+		}
+		query, err := QueryParserOptions(test.Condition, Options{NormalizePunctuation: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if query.Search(record) != test.Normal {
+			t.Errorf("Expected %v for %v when normalising\n", test.Normal, test.Condition)
+		}
+	}
+
+	// Records can be normalised to match a straight quoted query
+	pasted := TransformStringSlice([]string{"He said “it’s a merry—time”"}, NormalizePunctuation)
+	if !QueryParser("merry-time it's").Search(pasted) {
+		t.Errorf("Expected a normalised record to match\n")
+	}
+}
+
+func TestOptionsNormalizer(t *testing.T) {
+	record := SearchableMatchStringSlice([]string{"a merry time"})
+	q, err := QueryParserOptions("MERRY -BATTLE _exists_:Title", Options{Normalizer: strings.ToLower})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !q.Search(SearchableMatchFunc(func(field, phrase string, match MatchFunc) bool {
+		if field == "_exists_" {
+			return phrase == "Title"
+		}
+		return contains(record, field, phrase, match)
+	})) {
+		t.Errorf("Expected the phrases, but not the field name, to be normalised\n")
+	}
+	if result := q.String(); result != "merry NOT battle _exists_:Title" {
+		t.Errorf("Expected normalised phrases, got %v\n", result)
+	}
+
+	// NormalizePunctuation is applied first
+	q, _ = QueryParserOptions("“MERRY TIME”", Options{NormalizePunctuation: true, Normalizer: strings.ToLower})
+	if !q.Search(record) {
+		t.Errorf("Expected both normalisations to be applied\n")
+	}
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// rangeSeparator divides the bounds of a range such as price:[5 TO 20]
+const rangeSeparator = " TO "
+
+// rangeOpen returns true if char starts a range, [ for an inclusive lower bound and { for an exclusive one
+func rangeOpen(char rune) bool {
+	return char == '[' || char == '{'
+}
+
+// rangeClose returns true if char ends a range, ] for an inclusive upper bound and } for an exclusive one
+func rangeClose(char rune) bool {
+	return char == ']' || char == '}'
+}
+
+// splitRange separates a range such as [5 TO 20] into its bounds and their operators.
+// A bound of * is unbounded and returned as an empty string.
+func splitRange(value string) (lowOp Op, low string, highOp Op, high string, ok bool) {
+	if len(value) < 2 || !rangeOpen(rune(value[0])) || !rangeClose(rune(value[len(value)-1])) {
+		return 0, "", 0, "", false
+	}
+	bounds := strings.Split(value[1:len(value)-1], rangeSeparator)
+	if len(bounds) != 2 {
+		return 0, "", 0, "", false
+	}
+	lowOp, highOp = OpGreaterEqual, OpLessEqual
+	if value[0] == '{' {
+		lowOp = OpGreater
+	}
+	if value[len(value)-1] == '}' {
+		highOp = OpLess
+	}
+	low, high = strings.TrimSpace(bounds[0]), strings.TrimSpace(bounds[1])
+	if low == "*" {
+		low = ""
+	}
+	if high == "*" {
+		high = ""
+	}
+	return lowOp, low, highOp, high, low != "" || high != ""
+}
+
+// parseRange fills in a TermRange, or a TermNumber if only one bound is given, from a range such as [5 TO 20].
+// Ranges of dates such as [2020-01-01 TO 2020-12-31] give a TermDateRange or TermDate instead.
+func (opts Options) parseRange(term *Term, lowOp Op, low string, highOp Op, high string) error {
+	first := low
+	if first == "" {
+		first = high
+	}
+	if _, dateErr := opts.parseDate(first); dateErr == nil {
+		return opts.parseDateRange(term, lowOp, low, highOp, high)
+	}
+	lowValue, err := opts.parseBound(low)
+	if err != nil {
+		return err
+	}
+	highValue, err := opts.parseBound(high)
+	if err != nil {
+		return err
+	}
+	switch {
+	case low == "":
+		term.Kind, term.Op, term.Number, term.Phrase = TermNumber, highOp, highValue, high
+	case high == "":
+		term.Kind, term.Op, term.Number, term.Phrase = TermNumber, lowOp, lowValue, low
+	default:
+		term.Kind, term.Op, term.Number, term.UpperOp, term.Upper = TermRange, lowOp, lowValue, highOp, highValue
+	}
+	return nil
+}
+
+// parseDateRange fills in a TermDateRange, or a TermDate if only one bound is given, from a range of dates
+func (opts Options) parseDateRange(term *Term, lowOp Op, low string, highOp Op, high string) error {
+	var lowTime, highTime time.Time
+	var err error
+	if low != "" {
+		if lowTime, err = opts.parseDate(low); err != nil {
+			return err
+		}
+	}
+	if high != "" {
+		if highTime, err = opts.parseDate(high); err != nil {
+			return err
+		}
+	}
+	switch {
+	case low == "":
+		term.Kind, term.Op, term.Time, term.Phrase = TermDate, highOp, highTime, high
+	case high == "":
+		term.Kind, term.Op, term.Time, term.Phrase = TermDate, lowOp, lowTime, low
+	default:
+		term.Kind, term.Op, term.Time, term.UpperOp, term.UpperTime = TermDateRange, lowOp, lowTime, highOp, highTime
+	}
+	return nil
+}
+
+// parseBound parses one bound of a range, which is 0 if the range is unbounded
+func (opts Options) parseBound(bound string) (n float64, err error) {
+	if bound == "" {
+		return 0, nil
+	}
+	n, isNumber, err := opts.parseNumber(bound)
+	if err == nil && !isNumber {
+		err = fmt.Errorf("invalid number %q in range", bound)
+	}
+	return n, err
+}
+
+// mustBeInRange returns true if the Searchable's number in the field is within both bounds.
+// Searchable objects that are neither Comparable nor FieldValuers are asked whether they contain the range as written.
+func mustBeInRange(field string, lowOp Op, low float64, highOp Op, high float64, phrase string, opts Options, match MatchFunc) filter {
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case Comparable:
+			return s.Compare(field, lowOp, low) && s.Compare(field, highOp, high)
+		case FieldValuer:
+			text, present := s.FieldValue(field)
+			return present && opts.compareNumberText(text, lowOp, low) && opts.compareNumberText(text, highOp, high)
+		}
+		return contains(s, field, phrase, match)
+	}
+}
+
+// mustBeInDateRange returns true if the Searchable's date in the field is within both bounds.
+// Searchable objects that are neither DateComparable nor FieldValuers are asked whether they contain the range as written.
+func mustBeInDateRange(field string, lowOp Op, low time.Time, highOp Op, high time.Time, phrase string, opts Options, match MatchFunc) filter {
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case DateComparable:
+			return s.CompareDate(field, lowOp, low) && s.CompareDate(field, highOp, high)
+		case FieldValuer:
+			text, present := s.FieldValue(field)
+			return present && opts.compareDateText(text, lowOp, low) && opts.compareDateText(text, highOp, high)
+		}
+		return contains(s, field, phrase, match)
+	}
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Price     float64
+		Match     bool
+	}{
+		{"price:[5 TO 20]", 5, true},
+		{"price:[5 TO 20]", 20, true},
+		{"price:[5 TO 20]", 12.5, true},
+		{"price:[5 TO 20]", 4.99, false},
+		{"price:[5 TO 20]", 20.01, false},
+		{"price:{5 TO 20}", 5, false},
+		{"price:{5 TO 20}", 20, false},
+		{"price:{5 TO 20}", 6, true},
+		{"price:[5 TO 20}", 5, true},
+		{"price:[5 TO 20}", 20, false},
+		{"price:{5 TO 20]", 20, true},
+		{"price:[-10.5 TO -2]", -10.5, true},
+		{"price:[-10.5 TO -2]", -1, false},
+		{"price:[-10.5 TO -2]", -11, false},
+		{"price:[0.25 TO 0.75]", 0.5, true},
+		{"price:[10 TO *]", 1000, true},
+		{"price:[10 TO *]", 9, false},
+		{"price:{* TO 10}", 10, false},
+		{"price:{* TO 10}", -50, true},
+		{"price:[1KB TO 2KB]", 1500, true},
+		{"NOT price:[5 TO 20]", 12, false},
+		{"price:[5 TO 20] OR price:>100", 150, true},
+		{"(price:[5 TO 20])", 12, true},
+		{"cost:[5 TO 20]", 12, false},
+		{"price:>10", 11, true},
+		{"price:>=10", 10, true},
+		{"price:<5", 5, false},
+		{"price:<-5", -5.5, true},
+	} {
+		query, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+			continue
+		}
+		if result := query.Search(testSizedRecord{"price": test.Price}); result != test.Match {
+			t.Errorf("Expected %v for %v with price %v, got %v\n", test.Match, test.Condition, test.Price, result)
+		}
+	}
+
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+	}{
+		{"price:[cheap TO 20]", 6},
+	} {
+		_, err := QueryParserErr(test.Condition)
+		if perr, ok := err.(*ParseError); !ok || perr.Pos != test.Pos {
+			t.Errorf("Expected a *ParseError at position %v for %v, got %v\n", test.Pos, test.Condition, err)
+		}
+	}
+	unknownUnit, err := QueryParserErr("boat price:[5 TO 1XB]")
+	if err != nil {
+		t.Fatalf("Unexpected error for an unknown unit: %v\n", err)
+	}
+	if diagnostics := unknownUnit.Diagnostics(); len(diagnostics) != 1 || diagnostics[0].Pos != 5 {
+		t.Errorf("Expected a diagnostic at position 5 for the unknown unit, got %v\n", diagnostics)
+	}
+
+	// Searchables that can't compare numbers are asked for the range as written
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"price:[5 TO 20]", true},
+		{"price:[5 TO 30]", false},
+		{"price:[5 TO 20] whale", true},
+		{"[5 TO 20]", true},
+		{"price:[5", true},
+		{"price:[5 TO 30", false},
+	} {
+		if result := QueryParser(test.Condition).Search(SearchableString("a price of [5 TO 20] for a whale")); result != test.Match {
+			t.Errorf("Expected %v for %v in text, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+
+	if text := QueryParser("boat price:{5 TO 20]").String(); text != "boat price:{5 TO 20]" {
+		t.Errorf("Expected the range to be written as given, got %v\n", text)
+	}
+	term := QueryParser("price:{-5 TO 20]").(*query).root.Children[0].(*Term)
+	if term.Kind != TermRange || term.Op != OpGreater || term.Number != -5 || term.UpperOp != OpLessEqual || term.Upper != 20 {
+		t.Errorf("Expected a range from more than -5 to at most 20, got %+v\n", term)
+	}
+}
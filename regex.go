@@ -0,0 +1,65 @@
+package search
+
+import (
+	"regexp"
+)
+
+/*
+RegexMatchable is an optional interface for Searchable objects that can match
+a regular expression against a field, used for terms such as
+`title:/^Once .* time$/`.
+
+The pattern uses the syntax of the regexp package, with any / in it escaped as
+\/.  Searchable objects that do not implement RegexMatchable but do implement
+MatchSearchable, as SearchableMatchString does, are asked to match the
+expression against the text of the field using ContainsMatch.  Others,
+including SearchableString and SearchableStringSlice, are asked whether the
+field contains the pattern as written, slashes included; use
+SearchableMatchString or SearchableMatchStringSlice to match strings against
+the expression.
+*/
+type RegexMatchable interface {
+	Searchable
+	/*
+		MatchRegex returns true if re matches the text of the field, or of any
+		field if field is empty.
+	*/
+	MatchRegex(field string, re *regexp.Regexp) (match bool)
+}
+
+/*
+MatchRegex calls the SearchableMatchFunc with a MatchFunc that reports whether
+re matches the text, so string Searchables such as SearchableMatchString are
+RegexMatchable.
+*/
+func (sf SearchableMatchFunc) MatchRegex(field string, re *regexp.Regexp) (match bool) {
+	return sf(field, re.String(), func(text, pattern string) bool {
+		return re.MatchString(text)
+	})
+}
+
+// regexValue returns the pattern of a value written between slashes, such as /^Once .* time$/, and whether it is one.
+// The closing slash must end the value, so /usr/bin is not a pattern.
+func regexValue(value string) (pattern string, ok bool) {
+	if len(value) < 3 || value[0] != '/' || unescapedIndex(value[1:], '/') != len(value)-2 {
+		return "", false
+	}
+	return value[1 : len(value)-1], true
+}
+
+// mustMatchRegex returns true if the regular expression matches the Searchable's text in the field
+func mustMatchRegex(field string, re *regexp.Regexp, pattern string) filter {
+	literal := "/" + pattern + "/"
+	matchText := func(text, phrase string) bool {
+		return re.MatchString(text)
+	}
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case RegexMatchable:
+			return s.MatchRegex(field, re)
+		case MatchSearchable:
+			return s.ContainsMatch(field, pattern, matchText)
+		}
+		return s.Contains(field, literal)
+	}
+}
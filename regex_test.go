@@ -0,0 +1,103 @@
+package search
+
+import (
+	"regexp"
+	"testing"
+)
+
+// testRegexRecord records the field and pattern it is asked to match
+type testRegexRecord struct {
+	field, pattern string
+}
+
+func (r *testRegexRecord) Contains(field, phrase string) (present bool) {
+	return false
+}
+
+func (r *testRegexRecord) MatchRegex(field string, re *regexp.Regexp) (match bool) {
+	r.field, r.pattern = field, re.String()
+	return true
+}
+
+func TestRegex(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Result    string
+		Match     bool
+	}{
+		{"title:/^Once .* time$/", "title:/^Once .* time$/", true},
+		{"title:/^once/", "title:/^once/", false},
+		{"title:/(?i)^once/", "title:/(?i)^once/", true},
+		{"title:/(merry|happy) time/ body:/b[aeiou]ttle/", "title:/(merry|happy) time/ body:/b[aeiou]ttle/", true},
+		{"title:/battle/", "title:/battle/", false},
+		{"NOT title:/battle/", "NOT title:/battle/", true},
+		{`title:/"very merry"/`, `title:/"very merry"/`, false},
+		{`title:/\w+ upon/`, `title:/\w+ upon/`, true},
+		{`title:"/upon/"`, "title:/upon/", false},
+	} {
+		q, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		if result := q.Search(SearchableFields(map[string]string{"title": "Once upon a very merry time", "body": "A beetle battle"})); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+
+	// Escaped slashes are part of the pattern
+	q, err := QueryParserErr(`url:/^https:\/\/example\.com\//`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !q.MatchString("https://example.com/") || q.MatchString("http://example.com/") {
+		t.Errorf("Expected the pattern to match the URL\n")
+	}
+
+	// The matching string Searchables match the expression against their text
+	var single, slice RegexMatchable = SearchableMatchString("Once upon a time"), SearchableMatchStringSlice([]string{"a time", "Once upon"})
+	if !QueryParser("title:/^Once/").Search(single) || !QueryParser("title:/^Once/").Search(slice) {
+		t.Errorf("Expected ^Once to match SearchableMatchString and SearchableMatchStringSlice\n")
+	}
+	if QueryParser("title:/^upon/").Search(single) || QueryParser("title:/^upon/").Search(slice) {
+		t.Errorf("Expected ^upon not to match SearchableMatchString and SearchableMatchStringSlice\n")
+	}
+
+	// SearchableString and SearchableStringSlice can only be asked for the pattern as written, so matching needs the Match variants
+	if QueryParser("title:/^Once/").Search(SearchableString("Once upon a time")) || QueryParser("title:/^Once/").Search(SearchableStringSlice([]string{"Once upon"})) {
+		t.Errorf("Expected ^Once not to match SearchableString and SearchableStringSlice\n")
+	}
+	if !QueryParser("title:/^Once/").Search(SearchableString("see /^Once/")) {
+		t.Errorf("Expected SearchableString to contain the pattern as written\n")
+	}
+
+	// RegexMatchable is asked when implemented
+	record := &testRegexRecord{}
+	if !QueryParser("title:/^Once/").Search(record) || record.field != "title" || record.pattern != "^Once" {
+		t.Errorf("Expected MatchRegex to be asked for ^Once in title, got %v in %v\n", record.pattern, record.field)
+	}
+
+	// Searchables without either interface are asked for the pattern as written
+	contains := SearchableFunc(func(field, phrase string) bool { return phrase == "/^Once/" })
+	if !QueryParser("title:/^Once/").Search(contains) {
+		t.Errorf("Expected Contains to be asked for the pattern\n")
+	}
+}
+
+func TestRegexErrors(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+	}{
+		{"title:/(merry/", 6},
+		{"boat title:/a**/", 11},
+	} {
+		_, err := QueryParserErr(test.Condition)
+		parseErr, ok := err.(*ParseError)
+		if !ok || parseErr.Pos != test.Pos {
+			t.Errorf("Expected an error at %v for %v, got %v\n", test.Pos, test.Condition, err)
+		}
+	}
+}
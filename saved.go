@@ -0,0 +1,185 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// savedField is the field used in queries to include a saved query by name
+const savedField = "@saved"
+
+// savedReference returns true if the term refers to one of Options.NamedQueries, as @saved:books does
+func (opts Options) savedReference(term *Term) bool {
+	return opts.NamedQueries != nil && term.Field == savedField && term.Kind == TermContains
+}
+
+// savedReferences adds the @saved:name terms beneath n to references, along with the name each refers to.
+// The names are kept so that options applied to the terms, such as Options.Normalizer, do not change them.
+func savedReferences(n Node, opts Options, references map[*Term]string) map[*Term]string {
+	Walk(n, func(n Node) bool {
+		if term, ok := n.(*Term); ok && opts.savedReference(term) {
+			if references == nil {
+				references = make(map[*Term]string)
+			}
+			references[term] = term.Phrase
+		}
+		return true
+	})
+	return references
+}
+
+/*
+expandSaved replaces the @saved:name terms beneath n with a copy of the named
+query, spanning the term that referred to it.  references gives the names of
+the terms found by savedReferences, other terms refer to the name they hold.
+Saved queries may themselves refer to others, names lists those being expanded
+to detect cycles.
+
+The first problem found is returned, terms that can not be expanded are left
+in place.
+*/
+func expandSaved(n Node, named map[string]Query, references map[*Term]string, names []string) (err error) {
+	replace := func(child Node) Node {
+		term, ok := child.(*Term)
+		if !ok || term.Field != savedField {
+			if childErr := expandSaved(child, named, references, names); err == nil {
+				err = childErr
+			}
+			return child
+		}
+		name, found := references[term]
+		if !found {
+			name = term.Phrase
+		}
+		for _, including := range names {
+			if including == name {
+				if err == nil {
+					err = &ParseError{Pos: term.Start, Message: fmt.Sprintf("saved query %q includes itself", name)}
+				}
+				return child
+			}
+		}
+		included, found := named[name]
+		if !found {
+			if err == nil {
+				err = &ParseError{Pos: term.Start, Message: fmt.Sprintf("unknown saved query %q", name)}
+			}
+			return child
+		}
+		saved, ok := included.(*query)
+		if !ok {
+			if err == nil {
+				err = &ParseError{
+					Pos:     term.Start,
+					Message: fmt.Sprintf("saved query %q is a %T, not a Query made by this package", name, included),
+				}
+			}
+			return child
+		}
+		group := copyTree(saved.root, term.Start, term.End)
+		if groupErr := expandSaved(group, named, references, append(names, name)); err == nil {
+			err = groupErr
+		}
+		return group
+	}
+
+	switch n := n.(type) {
+	case *AndNode:
+		for i, child := range n.Children {
+			n.Children[i] = replace(child)
+		}
+	case *OrNode:
+		for i, child := range n.Children {
+			n.Children[i] = replace(child)
+		}
+	case *NotNode:
+		n.Child = replace(n.Child)
+	}
+	return err
+}
+
+/*
+checkIncluded returns a ParseError if root, with the saved queries in it
+included, has more terms than Options.MaxTerms, or brackets nested more deeply
+than Options.MaxDepth once written out as text.  The error is positioned at the
+first reference to a saved query.
+*/
+func checkIncluded(root *AndNode, opts Options, references map[*Term]string) error {
+	if len(references) == 0 || opts.MaxTerms <= 0 && opts.MaxDepth <= 0 {
+		return nil
+	}
+	pos := -1
+	for term := range references {
+		if pos < 0 || term.Start < pos {
+			pos = term.Start
+		}
+	}
+	if terms := countTerms(root); opts.MaxTerms > 0 && terms > opts.MaxTerms {
+		return &ParseError{Pos: pos, Message: fmt.Sprintf("query with its saved queries has more than %v terms", opts.MaxTerms)}
+	}
+	var text strings.Builder
+	formatNode(&text, root, true, opts.operatorWords(), opts.NotScope)
+	if depth := bracketDepth(text.String()); opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return &ParseError{
+			Pos:     pos,
+			Message: fmt.Sprintf("query with its saved queries has brackets nested more than %v deep", opts.MaxDepth),
+		}
+	}
+	return nil
+}
+
+// countTerms returns the number of terms beneath n, counting each phrase joined by NEAR
+func countTerms(n Node) (count int) {
+	Walk(n, func(n Node) bool {
+		if term, ok := n.(*Term); ok {
+			for ; term != nil; term = term.Near {
+				count++
+			}
+		}
+		return true
+	})
+	return count
+}
+
+// bracketDepth returns how deeply the brackets of a query are nested, so that ((a)) has a depth of 2
+func bracketDepth(query string) (deepest int) {
+	depth := 0
+	l := lex(query)
+	for tok, ok := l.token(); ok; tok, ok = l.token() {
+		switch tok.kind {
+		case tokenOpen:
+			if depth++; depth > deepest {
+				deepest = depth
+			}
+		case tokenClose:
+			depth--
+		}
+	}
+	return deepest
+}
+
+// copyTree returns a copy of n with every span set to start and end
+func copyTree(n Node, start, end int) Node {
+	switch n := n.(type) {
+	case *Term:
+		term := copyTerm(n)
+		term.Start, term.End = start, end
+		return term
+	case *AndNode:
+		return &AndNode{Children: copyNodes(n.Children, start, end), Start: start, End: end}
+	case *OrNode:
+		return &OrNode{Children: copyNodes(n.Children, start, end), Start: start, End: end}
+	case *NotNode:
+		return &NotNode{Child: copyTree(n.Child, start, end), Start: start, End: end}
+	}
+	panic("search: unknown node type")
+}
+
+// copyNodes copies each of the nodes using copyTree
+func copyNodes(nodes []Node, start, end int) []Node {
+	copies := make([]Node, len(nodes))
+	for i, n := range nodes {
+		copies[i] = copyTree(n, start, end)
+	}
+	return copies
+}
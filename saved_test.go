@@ -0,0 +1,144 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNamedQueries(t *testing.T) {
+	named := map[string]Query{
+		"books":   QueryParser("tag:(book,leaflet) NOT draft"),
+		"animals": QueryParser("whale OR shark"),
+	}
+	named["both"] = QueryParser("@saved:books @saved:animals")
+	opts := Options{NamedQueries: named}
+
+	book := SearchableFunc(func(field, phrase string) bool {
+		return (field == "tag" && phrase == "book") || (field == "" && phrase == "whale")
+	})
+	draft := SearchableFunc(func(field, phrase string) bool {
+		return (field == "tag" && phrase == "book") || (field == "" && (phrase == "whale" || phrase == "draft"))
+	})
+	for _, test := range []struct {
+		Condition string
+		Book      bool
+		Draft     bool
+	}{
+		{"@saved:books whale", true, false},
+		{"@saved:books shark", false, false},
+		{"NOT @saved:books whale", false, true},
+		{"frog OR @saved:books", true, false},
+		{"@saved:both", true, false},
+	} {
+		query, err := QueryParserOptions(test.Condition, opts)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+			continue
+		}
+		if query.Search(book) != test.Book {
+			t.Errorf("Expected %v for %v against the book\n", test.Book, test.Condition)
+		}
+		if query.Search(draft) != test.Draft {
+			t.Errorf("Expected %v for %v against the draft\n", test.Draft, test.Condition)
+		}
+	}
+
+	// The optional terms of an included query stay optional
+	named["featured"] = QueryParser("boat ?featured")
+	boat := SearchableFunc(func(field, phrase string) bool { return phrase == "boat" })
+	for _, condition := range []string{"@saved:featured", "whale OR @saved:featured"} {
+		query, err := QueryParserOptions(condition, opts)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", condition, err)
+		} else if !query.Search(boat) {
+			t.Errorf("Expected %v to match a record with just boat\n", condition)
+		}
+	}
+
+	query, err := QueryParserOptions("whale @saved:books", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if n := query.NodeAt(10); n == nil {
+		t.Errorf("Expected the included query to span the reference\n")
+	} else if start, end := n.Span(); start != 6 || end != 18 {
+		t.Errorf("Expected the included query to span 6 to 18, got %v to %v\n", start, end)
+	}
+}
+
+func TestNamedQueryErrors(t *testing.T) {
+	named := map[string]Query{"books": QueryParser("@saved:books tag:book")}
+	named["loop"] = QueryParser("@saved:again")
+	named["again"] = QueryParser("whale @saved:loop")
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+	}{
+		{"@saved:books", 0},
+		{"whale @saved:loop", 6},
+		{"whale @saved:missing", 6},
+	} {
+		_, err := QueryParserOptions(test.Condition, Options{NamedQueries: named})
+		if perr, ok := err.(*ParseError); !ok || perr.Pos != test.Pos {
+			t.Errorf("Expected a *ParseError at %v for %v, got %v\n", test.Pos, test.Condition, err)
+		}
+	}
+	// Without NamedQueries the reference is an ordinary field
+	if !QueryParser("@saved:books").Search(SearchableFunc(func(field, phrase string) bool { return field == "@saved" })) {
+		t.Errorf("Expected @saved to be searched as a field without NamedQueries\n")
+	}
+}
+
+func TestNamedQueryOptions(t *testing.T) {
+	// Options applied when a saved query was parsed are not applied to it again
+	transforms := map[string]func(string) string{"sku": func(phrase string) string { return "SKU-" + phrase }}
+	widget, err := QueryParserOptions("sku:123", Options{FieldTransforms: transforms})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	named := map[string]Query{
+		"Widget": widget,
+		"books":  QueryParser("tag:(book,leaflet) NOT draft"),
+		"nested": QueryParser("aa OR (bb cc)"),
+		"other":  struct{ Query }{QueryParser("whale")},
+	}
+	opts := Options{NamedQueries: named, FieldTransforms: transforms, Normalizer: strings.ToLower}
+	query, err := QueryParserOptions("@saved:Widget sku:456", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if text := query.String(); text != "(sku:SKU-123) sku:SKU-456" {
+		t.Errorf("Expected the saved query to be transformed once, got %q\n", text)
+	}
+
+	// References are allowed whatever AllowedFields holds
+	if _, err := QueryParserOptions("@saved:books", Options{NamedQueries: named, AllowedFields: []string{"title"}}); err != nil {
+		t.Errorf("Unexpected error for a reference with AllowedFields: %v\n", err)
+	}
+
+	for _, test := range []struct {
+		Condition string
+		Opts      Options
+		Pos       int
+	}{
+		{"whale @saved:books", Options{NamedQueries: named, MaxTerms: 3}, 6},
+		{"frog OR @saved:nested", Options{NamedQueries: named, MaxDepth: 1}, 8},
+		{"whale @saved:other", Options{NamedQueries: named}, 6},
+	} {
+		_, err := QueryParserOptions(test.Condition, test.Opts)
+		if perr, ok := err.(*ParseError); !ok || perr.Pos != test.Pos {
+			t.Errorf("Expected a *ParseError at %v for %v, got %v\n", test.Pos, test.Condition, err)
+		}
+	}
+	for _, test := range []struct {
+		Condition string
+		Opts      Options
+	}{
+		{"whale @saved:books", Options{NamedQueries: named, MaxTerms: 4}},
+		{"frog OR @saved:nested", Options{NamedQueries: named, MaxDepth: 2}},
+	} {
+		if _, err := QueryParserOptions(test.Condition, test.Opts); err != nil {
+			t.Errorf("Unexpected error for %v within the limits: %v\n", test.Condition, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package search
+
+import (
+	"bufio"
+)
+
+/*
+SearchableScanner reads every line from sc, searching each one with the query.
+
+The line numbers, starting at 1, of the lines that match are returned, along
+with a Searchable holding only the matching lines.  Only the matching lines are
+kept in memory, so this suits grep style searches of files too large to hold in
+memory, while still allowing the matches to be searched again.
+
+Any error from the scanner is returned along with the matches found before it.
+*/
+func SearchableScanner(q Query, sc *bufio.Scanner) (matched Searchable, lines []int, err error) {
+	var matchedLines []string
+	for lineNumber := 1; sc.Scan(); lineNumber++ {
+		line := sc.Text()
+		if q.Search(SearchableMatchString(line)) {
+			lines = append(lines, lineNumber)
+			matchedLines = append(matchedLines, line)
+		}
+	}
+	return SearchableMatchStringSlice(matchedLines), lines, sc.Err()
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchableScanner(t *testing.T) {
+	text := `Once upon a very merry time
+A beetle battle fought in a bottle
+Nothing to see here
+The merry beetle won the battle
+`
+	matched, lines, err := SearchableScanner(QueryParser("beetle battle"), bufio.NewScanner(strings.NewReader(text)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !reflect.DeepEqual(lines, []int{2, 4}) {
+		t.Errorf("Expected lines [2 4] to match, got %v\n", lines)
+	}
+	if !QueryParser("merry").Search(matched) {
+		t.Errorf("Expected the matched lines to be searchable\n")
+	}
+	if QueryParser("upon").Search(matched) {
+		t.Errorf("Expected lines that didn't match not to be kept\n")
+	}
+
+	_, lines, err = SearchableScanner(QueryParser("frog"), bufio.NewScanner(strings.NewReader(text)))
+	if err != nil || len(lines) != 0 {
+		t.Errorf("Expected no matches and no error, got %v, %v\n", lines, err)
+	}
+}
+
+type testFailingReader struct {
+	data string
+}
+
+func (r *testFailingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, errors.New("read failed")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestSearchableScannerError(t *testing.T) {
+	sc := bufio.NewScanner(&testFailingReader{data: "merry one\nmerry two\n"})
+	_, lines, err := SearchableScanner(QueryParser("merry"), sc)
+	if err == nil {
+		t.Errorf("Expected the read error to be returned\n")
+	}
+	if !reflect.DeepEqual(lines, []int{1, 2}) {
+		t.Errorf("Expected the matches before the error, got %v\n", lines)
+	}
+}
@@ -0,0 +1,144 @@
+package search
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Ranked is a record that matched a query, together with its score from
+Query.Score.
+*/
+type Ranked struct {
+	Record Searchable
+	Score  float64
+}
+
+// weightedTerm is a term that adds its weight to the score of the records it matches
+type weightedTerm struct {
+	match  filter
+	weight float64
+}
+
+// positiveTerms compiles a weightedTerm for each term beneath n that is not negated, used to score matching records
+func positiveTerms(n Node, negated bool, opts Options, results []weightedTerm) []weightedTerm {
+	switch n := n.(type) {
+	case *Term:
+		if !negated {
+			results = append(results, weightedTerm{match: compile(n, opts, nil), weight: termWeight(n)})
+		}
+		return results
+	case *NotNode:
+		return positiveTerms(n.Child, !negated, opts, results)
+	}
+	for _, child := range children(n) {
+		results = positiveTerms(child, negated, opts, results)
+	}
+	return results
+}
+
+// boostMark follows a term to give the number its weight is multiplied by, as in tag:book^2
+const boostMark = '^'
+
+// termWeight returns the number of words in the phrases of a term that searches for text, or 1 for other terms,
+// multiplied by any boost given to the term
+func termWeight(n *Term) float64 {
+	boost := n.Boost
+	if boost == 0 {
+		boost = 1
+	}
+	switch n.Kind {
+	case TermContains, TermWildcard, TermFuzzy, TermNear, TermNearOrdered:
+	default:
+		return boost
+	}
+	words := 0
+	for _, phrase := range nearPhrases(n) {
+		words += len(strings.FieldsFunc(phrase, notWordChar))
+	}
+	if words == 0 {
+		return boost
+	}
+	return float64(words) * boost
+}
+
+// splitBoost separates the boost from a value such as book^2 or book^0.5, returning false if the value has no boost.
+// The boost is a positive number of digits with an optional decimal point, so title:^="Once" and book\^2 have none.
+func splitBoost(value string) (rest string, boost float64, ok bool) {
+	mark := strings.LastIndexByte(value, boostMark)
+	if mark <= 0 || value[mark-1] == '\\' || strings.Trim(value[mark+1:], "0123456789.") != "" {
+		return value, 0, false
+	}
+	boost, err := strconv.ParseFloat(value[mark+1:], 64)
+	if err != nil || boost <= 0 {
+		return value, 0, false
+	}
+	return value[:mark], boost, true
+}
+
+// boostable returns true if the term as written ends with a boost outside any quotes, as "boat"^2 and boat^2 do,
+// rather than with a ^ that is part of the phrase, as in "boat^2".
+func boostable(written string) bool {
+	rest, _, ok := splitBoost(written)
+	if !ok {
+		return false
+	}
+	l := lex(rest)
+	for _, more := l.token(); more; _, more = l.token() {
+	}
+	return l.state != stateQuote
+}
+
+/*
+Filter returns the records that match q, in their original order.
+*/
+func Filter(q Query, records []Searchable) (matches []Searchable) {
+	for _, record := range records {
+		if q.Search(record) {
+			matches = append(matches, record)
+		}
+	}
+	return matches
+}
+
+/*
+Rank returns the records that match q, ordered by descending score.
+
+Records with the same score are kept in their original order.
+*/
+func Rank(q Query, records []Searchable) (ranked []Ranked) {
+	for _, record := range records {
+		if score := q.Score(record); score > 0 {
+			ranked = append(ranked, Ranked{Record: record, Score: score})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+/*
+SearchPage ranks the records that match q, as Rank does, and returns limit of
+them starting at offset, for showing a page of results.
+
+total is the number of records that matched, regardless of the page asked for.
+A page beyond the end of the results is empty.
+*/
+func SearchPage(q Query, records []Searchable, offset, limit int) (page []Ranked, total int) {
+	ranked := Rank(q, records)
+	total = len(ranked)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	// Compared with the records left after offset, as offset+limit can overflow for a limit such as math.MaxInt
+	if limit >= 0 && limit < end-offset {
+		end = offset + limit
+	}
+	return ranked[offset:end], total
+}
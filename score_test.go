@@ -0,0 +1,261 @@
+package search
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	query := QueryParser("boat OR whale OR shark NOT dead")
+	for _, test := range []struct {
+		Record string
+		Score  float64
+	}{
+		{"a boat", 1},
+		{"a boat and a whale", 2},
+		{"a boat, a whale and a shark", 3},
+		{"a dead whale", 0},
+		{"a fish", 0},
+	} {
+		if score := query.Score(SearchableString(test.Record)); score != test.Score {
+			t.Errorf("Expected score %v for %v, got %v\n", test.Score, test.Record, score)
+		}
+	}
+	if score := QueryParser("NOT boat").Score(SearchableString("a whale")); score != 1 {
+		t.Errorf("Expected score 1 for a match without positive terms, got %v\n", score)
+	}
+}
+
+func TestScoreWeights(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Record    string
+		Score     float64
+	}{
+		{`"climate change" OR climate`, "climate change policy", 3},
+		{`"climate change" OR climate`, "climate policy", 1},
+		{`"climate change" OR policy`, "climate change policy", 3},
+		{`"climate change" NEAR/2 "new policy"`, "climate change and new policy", 4},
+		{"climate policy~1", "climate policy", 2},
+		{`policy ?"climate change"`, "climate change policy", 3},
+		{`policy ?"climate change"`, "policy", 1},
+		{`"!!" policy`, "!! policy", 2},
+		{"climate^3 policy", "climate policy", 4},
+		{`policy ?"climate change"^0.5`, "climate change policy", 2},
+		{"climate OR policy^2", "climate", 1},
+		{`climate policy\^2`, "climate policy^2", 3},
+	} {
+		if score := QueryParser(test.Condition).Score(SearchableString(test.Record)); score != test.Score {
+			t.Errorf("Expected score %v for %v in %v, got %v\n", test.Score, test.Condition, test.Record, score)
+		}
+	}
+
+	// A caret within quotes is part of the phrase rather than a boost
+	for _, condition := range []string{`"boat^2"`, `title:"boat^2"`, `-"boat^2"`} {
+		term := QueryParser(condition).(*query).root.Children[0]
+		if not, ok := term.(*NotNode); ok {
+			term = not.Child
+		}
+		if term := term.(*Term); term.Phrase != "boat^2" || term.Boost != 0 {
+			t.Errorf("Expected a literal boat^2 without a boost for %v, got %v boosted by %v\n", condition, term.Phrase, term.Boost)
+		}
+	}
+	if QueryParser(`"boat^2"`).Search(SearchableString("a boat")) {
+		t.Errorf("Expected a quoted caret to be searched for as written\n")
+	}
+
+	// Comparisons have a weight of 1 whatever their phrase
+	for _, condition := range []string{"price:[5 TO 20]", `name:>"a b c"`, "boa*"} {
+		term := QueryParser(condition).(*query).root.Children[0].(*Term)
+		if weight := termWeight(term); weight != 1 {
+			t.Errorf("Expected a weight of 1 for %v, got %v\n", condition, weight)
+		}
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	texts := []string{
+		"a boat",
+		"a fish",
+		"a boat, a whale and a shark",
+		"a whale",
+		"a boat and a whale",
+		"a shark",
+		"a dead whale",
+	}
+	records := make([]Searchable, len(texts))
+	for i, text := range texts {
+		records[i] = SearchableString(text)
+	}
+	query := QueryParser("boat OR whale OR shark NOT dead")
+
+	if matches := Filter(query, records); len(matches) != 5 {
+		t.Errorf("Expected Filter to find 5 records, got %v\n", len(matches))
+	}
+
+	for _, test := range []struct {
+		Offset int
+		Limit  int
+		Scores []float64
+	}{
+		{0, 2, []float64{3, 2}},
+		{2, 2, []float64{1, 1}},
+		{4, 2, []float64{1}},
+		{6, 2, []float64{}},
+		{0, -1, []float64{3, 2, 1, 1, 1}},
+		{2, math.MaxInt, []float64{1, 1, 1}},
+		{math.MaxInt, math.MaxInt, []float64{}},
+		{-1, 1, []float64{3}},
+	} {
+		page, total := SearchPage(query, records, test.Offset, test.Limit)
+		if total != 5 {
+			t.Errorf("Expected a total of 5 for offset %v limit %v, got %v\n", test.Offset, test.Limit, total)
+		}
+		if len(page) != len(test.Scores) {
+			t.Errorf("Expected %v results for offset %v limit %v, got %v\n", len(test.Scores), test.Offset, test.Limit, len(page))
+			continue
+		}
+		for i, ranked := range page {
+			if ranked.Score != test.Scores[i] {
+				t.Errorf("Expected score %v at %v for offset %v limit %v, got %v\n", test.Scores[i], i, test.Offset, test.Limit, ranked.Score)
+			}
+		}
+	}
+
+	// Equal scores keep the original order of the records
+	page, _ := SearchPage(query, records, 2, 3)
+	for i, expected := range []string{"a boat", "a whale", "a shark"} {
+		if !page[i].Record.Contains("", expected) {
+			t.Errorf("Expected %v at %v\n", expected, i)
+		}
+	}
+}
+
+func TestOptionalTerms(t *testing.T) {
+	featured := &testSearchObject{Title: "boat whale", Body: "featured"}
+	plain := &testSearchObject{Title: "boat whale", Body: "ordinary"}
+	other := &testSearchObject{Title: "boat", Body: "featured"}
+
+	q := QueryParser("boat whale ?body:featured")
+	for _, test := range []struct {
+		Record *testSearchObject
+		Match  bool
+		Score  float64
+	}{
+		{featured, true, 3},
+		{plain, true, 2},
+		{other, false, 0},
+	} {
+		if q.Search(test.Record) != test.Match {
+			t.Errorf("Expected %v for %v\n", test.Match, test.Record)
+		}
+		if score := q.Score(test.Record); score != test.Score {
+			t.Errorf("Expected score %v for %v, got %v\n", test.Score, test.Record, score)
+		}
+	}
+
+	// Without required terms at least one optional term must match
+	q = QueryParser(`?featured ?"boat whale"`)
+	if !q.Search(plain) || !q.Search(other) {
+		t.Errorf("Expected a record with any of the optional terms to match\n")
+	}
+	if q.Search(&testSearchObject{Title: "shark"}) {
+		t.Errorf("Expected a record with none of the optional terms not to match\n")
+	}
+	if score := q.Score(featured); score != 3 {
+		t.Errorf("Expected score 3 for both optional terms, got %v\n", score)
+	}
+
+	term := QueryParser(`?body:"featured item"`).(*query).root.Children[0].(*Term)
+	if !term.Optional || term.Field != "body" || term.Phrase != "featured item" {
+		t.Errorf("Expected an optional body term, got %#v\n", term)
+	}
+	if terms := QueryParser("?tag:(book,leaflet) boat").(*query).filters; len(terms) != 1 {
+		t.Errorf("Expected an optional value list to be left out of the filters, got %v\n", len(terms))
+	}
+
+	// Optional terms within a group are left out of its match in the same way
+	boat := &testSearchObject{Title: "boat"}
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"(boat ?featured) OR frog", true},
+		{"frog OR (boat ?featured)", true},
+		{"frog OR (whale ?featured)", false},
+		{"frog OR (?featured ?boat)", true},
+		{"frog OR (?featured ?whale)", false},
+		{"NOT (boat ?featured)", false},
+	} {
+		q := QueryParser(test.Condition)
+		if q.Search(boat) != test.Match {
+			t.Errorf("Expected %v for %v\n", test.Match, test.Condition)
+		}
+		if q.Explain(boat).Matched != test.Match {
+			t.Errorf("Expected Explain to agree with Search for %v\n", test.Condition)
+		}
+	}
+
+	// Within quotes the ? is part of the phrase
+	q = QueryParser(`"?wing"`)
+	if q.Search(&testSearchObject{Title: "a wing"}) || !q.Search(&testSearchObject{Title: "?wing"}) {
+		t.Errorf("Expected a quoted ? to be searched for as written\n")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	filter, scorer := QueryParser("boat whale ?body:featured ?body:(new,popular) NOT shark").Split()
+	if text := filter.String(); text != "boat whale NOT shark" {
+		t.Errorf("Expected the filter to hold the required terms, got %v\n", text)
+	}
+	if text := scorer.String(); text != "?body:featured ?body:new OR ?body:popular" {
+		t.Errorf("Expected the scorer to hold the optional terms, got %v\n", text)
+	}
+
+	featured := &testSearchObject{Title: "boat whale", Body: "featured popular"}
+	plain := &testSearchObject{Title: "boat whale", Body: "ordinary"}
+	other := &testSearchObject{Title: "boat", Body: "featured"}
+	if !filter.Search(featured) || !filter.Search(plain) || filter.Search(other) {
+		t.Errorf("Expected the filter to match records with boat and whale\n")
+	}
+	if score := scorer.Score(featured); score != 2 {
+		t.Errorf("Expected a score of 2 for the featured record, got %v\n", score)
+	}
+	if scorer.Search(plain) {
+		t.Errorf("Expected the scorer not to match a record without optional terms\n")
+	}
+
+	// A query of only optional terms keeps them in the filter, as one must match
+	filter, scorer = QueryParser("?featured ?popular").Split()
+	if filter.Search(plain) || !filter.Search(other) {
+		t.Errorf("Expected the filter to need one of the optional terms\n")
+	}
+	if text := scorer.String(); text != "?featured ?popular" {
+		t.Errorf("Expected the scorer to hold the optional terms, got %v\n", text)
+	}
+
+	// The boosted tag:book^2 must match, so is required by the filter while its boost is counted by the scorer
+	filter, scorer = QueryParser("boat whale ?tag:featured tag:book^2").Split()
+	if text := filter.String(); text != "boat whale tag:book" {
+		t.Errorf("Expected the filter to require tag:book, got %v\n", text)
+	}
+	if text := scorer.String(); text != "?tag:featured ?tag:book^2" {
+		t.Errorf("Expected the scorer to hold the optional and boosted terms, got %v\n", text)
+	}
+	book := SearchableFields(map[string]string{"title": "boat whale", "tag": "book featured"})
+	leaflet := SearchableFields(map[string]string{"title": "boat whale", "tag": "leaflet featured"})
+	if !filter.Search(book) || filter.Search(leaflet) {
+		t.Errorf("Expected the filter to need book in the tag field\n")
+	}
+	if score := scorer.Score(book); score != 3 {
+		t.Errorf("Expected a score of 3 for featured and the boosted book, got %v\n", score)
+	}
+	if score := scorer.Score(leaflet); score != 1 {
+		t.Errorf("Expected a score of 1 for featured alone, got %v\n", score)
+	}
+
+	filter, scorer = QueryParser("boat").Split()
+	if !filter.Search(plain) || scorer.String() != "" {
+		t.Errorf("Expected an empty scorer without optional terms, got %v\n", scorer)
+	}
+}
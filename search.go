@@ -4,12 +4,45 @@ The search library provides a simple query language for searching records.
 The query language features are:
 
  * boat whale - must contain both `boat` and `whale`
+ * boat AND whale - the same as `boat whale`
  * boat OR whale - must contain either `boat` or `whale`
  * boat whale OR shark - must contain `boat` and either `whale` or `shark`
+ * (boat whale) OR shark - must contain either both `boat` and `whale`, or `shark`
  * boat whale NOT shark - must contain both `boat` and `whale` and not contain `shark`
+ * +boat -shark whale - the same as `boat whale NOT shark`
  * "floating boat" whale - must contain the phrase "floating boat" and the word `whale`
  * boat whale tag:book - must contain both `boat` and `whale` and the `tag` field must contain the word `book`
  * boat tag:book OR tag:"published leaflet" - must contain the word `boat` and either the `tag` field must have the word `book` or the phrase `published leaflet`
+ * tag!:draft - the `tag` field must not contain `draft`, the same as `NOT tag:draft` and `tag!=draft`
+ * "release date":2021 - the `release date` field must contain `2021`, quoting a field name allows spaces and colons in it
+ * any(title,subject):merry - either the `title` or the `subject` field must contain the word `merry`
+ * tag:(book,leaflet) - the `tag` field must contain either the word `book` or the word `leaflet`; spaces beside the commas, as in tag:(book, leaflet), are allowed
+ * title:("once upon" OR merry) - the `title` field must contain either the phrase `once upon` or the word `merry`
+ * _field_:author* - must have a field whose name starts with `author`, see FieldNamer
+ * _exists_:thumbnail - must have a `thumbnail` field, while _missing_:thumbnail must not, see FieldExister
+ * created:>2020-01-01 - the date in the `created` field must be after the 1st January 2020, see DateComparable
+ * created:>-7d - the date in the `created` field must be within the last 7 days, see DateComparable
+ * @saved:books whale - must match the query saved as `books` in Options.NamedQueries and contain `whale`
+ * size:>1MB - the number in the `size` field must be more than 1048576, see Comparable
+ * price:[5 TO 20] - the number in the `price` field must be from 5 to 20, with `{` and `}` excluding the bound, see Comparable
+ * src:192.168.0.0/16 - the IP address in the `src` field must be in the network, see IPSearchable
+ * name:>m - the text of the `name` field must sort after `m`, see FieldValuer and Options.Collator
+ * title:^="Once upon" - the `title` field must start with `Once upon`, see PrefixSearchable
+ * title:/^Once .* time$/ - the regular expression must match the `title` field, with \/ for a slash in it, see RegexMatchable
+ * boa* - must contain a word starting with `boa`, such as `boat` or `boardwalk`, see Prefixable
+ * whale~1 - must contain a word within 1 edit of `whale`, such as `whales` or `wbale`, with `whale~` allowing 2, see FuzzyMatchable
+ * "climate change" NEAR/5 policy - must contain both with at most 5 words between them, while ONEAR/5 keeps them in order, see Proximitable
+ * tag\:special \OR "say \"hi\"" - a backslash makes the next character ordinary, so this must contain `tag:special`, `OR` and `say "hi"`
+ * boat whale ?tag:featured - must contain both `boat` and `whale`, records that also have `featured` in the `tag` field are given a higher Score
+ * boat tag:book^2 - must contain `boat` and have `book` in the `tag` field, with the weight of `book` in the Score doubled
+
+NOT, and the + and - signs, apply to the term or bracketed group that follows
+them, though Options.NotScope can make NOT apply to the rest of the clause.  OR
+binds more tightly than the AND between terms, so `a b OR c d` is the same as
+`a (b OR c) d`, and `a OR b OR c` is a single OR of all three.  Brackets group
+terms so that they can be ORed together as one, as in `(a b) OR (c d)`.
+Options.OrKeyword and its partners change the words used as operators, for
+example to `||`.
 
 Such queries are parsed using the QueryParser function, which returns a Query
 object.  Query objects are able to search any object that implements the
@@ -19,8 +52,14 @@ Searchable interface.
 package search
 
 import (
+	"context"
+	"fmt"
 	// "log"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
@@ -47,11 +86,59 @@ func (sf SearchableFunc) Contains(field, phrase string) (present bool) {
 	return sf(field, phrase)
 }
 
+/*
+MatchFunc reports whether phrase is found in text.
+
+strings.Contains is the MatchFunc used when no matching options are set.
+*/
+type MatchFunc func(text, phrase string) (found bool)
+
+/*
+MatchSearchable is an optional interface for Searchable objects that hold their
+own text.
+
+Implementing ContainsMatch allows matching options, such as Options.GraphemeAware,
+to be applied to the text held by the object.  Searchable objects that do not
+implement it are always asked using Contains.
+*/
+type MatchSearchable interface {
+	Searchable
+	/*
+		ContainsMatch returns true if match reports the phrase is present in the
+		object, optionally restricted to the given field.
+	*/
+	ContainsMatch(field, phrase string, match MatchFunc) (present bool)
+}
+
+/*
+SearchableMatchFunc allows functions to implement the MatchSearchable interface.
+*/
+type SearchableMatchFunc func(field, phrase string, match MatchFunc) (present bool)
+
+/*
+Contains calls the SearchableMatchFunc using strings.Contains for matching
+*/
+func (sf SearchableMatchFunc) Contains(field, phrase string) (present bool) {
+	return sf(field, phrase, strings.Contains)
+}
+
+/*
+ContainsMatch calls the SearchableMatchFunc
+*/
+func (sf SearchableMatchFunc) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	return sf(field, phrase, match)
+}
+
 /*
 SearchableStringSlice makes a slice of strings Searchable.
 
 Each string in the slice is tested against the Query and returns true if any
-matches.
+matches.  The slice has no fields, so `title:merry` is the same as `merry`;
+use SearchableFields for records with named fields.
+
+As with SearchableString, the strings are only asked whether they contain
+each phrase.  SearchableMatchStringSlice applies every kind of term and the
+matching options to them.
 */
 func SearchableStringSlice(record []string) SearchableFunc {
 	return func(field, phrase string) bool {
@@ -68,6 +155,15 @@ func SearchableStringSlice(record []string) SearchableFunc {
 SearchableString makes a string Searchable.
 
 The query will be tested against the string, returning true if it matches.
+The string has no fields, so `title:merry` is the same as `merry`; use
+SearchableFields for records with named fields.
+
+The SearchableFunc returned can only be asked whether the string contains a
+phrase, so the terms that need the text itself are searched for as written:
+a fuzzy term such as `whale~1` only matches `whale`, and a regular expression
+such as `title:/^Once/` only matches `/^Once/`.  Matching options such as
+Options.CaseInsensitive are not applied either.  SearchableMatchString
+searches a string with all of them.
 */
 func SearchableString(record string) SearchableFunc {
 	return func(field, phrase string) bool {
@@ -75,6 +171,112 @@ func SearchableString(record string) SearchableFunc {
 	}
 }
 
+/*
+SearchableMatchStringSlice makes a slice of strings Searchable in the same way
+as SearchableStringSlice.
+
+The SearchableMatchFunc returned implements MatchSearchable, so fuzzy, NEAR and
+regular expression terms, and matching options such as
+Options.NormalizeWhitespace and Options.CaseInsensitive, apply to each of the
+strings.
+*/
+func SearchableMatchStringSlice(record []string) SearchableMatchFunc {
+	return func(field, phrase string, match MatchFunc) bool {
+		for _, str := range record {
+			if match(str, phrase) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+/*
+SearchableMatchString makes a string Searchable in the same way as
+SearchableString.
+
+The SearchableMatchFunc returned implements MatchSearchable, so fuzzy, NEAR and
+regular expression terms, and matching options such as
+Options.NormalizeWhitespace and Options.CaseInsensitive, apply to the string.
+*/
+func SearchableMatchString(record string) SearchableMatchFunc {
+	return func(field, phrase string, match MatchFunc) bool {
+		return match(record, phrase)
+	}
+}
+
+/*
+SearchableStringLimited makes the first maxBytes of a string Searchable, such
+as the headers or preview of a large record, bounding the work of a search.
+
+Phrases that only appear after the limit do not match.  If the limit falls
+inside a UTF-8 encoded character the whole character is left out.
+*/
+func SearchableStringLimited(record string, maxBytes int) Searchable {
+	if maxBytes < len(record) {
+		end := maxBytes
+		if end < 0 {
+			end = 0
+		}
+		for end > 0 && !utf8.RuneStart(record[end]) {
+			end--
+		}
+		record = record[:end]
+	}
+	return SearchableMatchString(record)
+}
+
+/*
+SearchableFields makes a map of field names to their values Searchable.
+
+Terms with a field, such as `title:merry`, only search the value of that field
+and do not match if the field is missing from the map.  Terms without a field
+search every value.  The Searchable implements MatchSearchable, FieldNamer,
+FieldExister and FieldValuer.
+*/
+func SearchableFields(record map[string]string) Searchable {
+	return searchableFields(record)
+}
+
+// searchableFields is the Searchable returned by SearchableFields
+type searchableFields map[string]string
+
+func (sf searchableFields) Contains(field, phrase string) (present bool) {
+	return sf.ContainsMatch(field, phrase, strings.Contains)
+}
+
+func (sf searchableFields) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	if field != "" {
+		value, found := sf[field]
+		return found && match(value, phrase)
+	}
+	for _, value := range sf {
+		if match(value, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sf searchableFields) FieldNames() (names []string) {
+	names = make([]string, 0, len(sf))
+	for name := range sf {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (sf searchableFields) HasField(field string) (present bool) {
+	_, present = sf[field]
+	return present
+}
+
+func (sf searchableFields) FieldValue(field string) (value string, present bool) {
+	value, present = sf[field]
+	return value, present
+}
+
 /*
 A filter function is part of a Query that executes searches.
 
@@ -90,6 +292,12 @@ A Query object is returned by QueryPraser to handle executing seraches.
 
 The Search method takes an object implementing the Searchable inteface and
 returns whether it matches the query.
+
+A Query is never changed once it has been parsed, so one Query may be used by
+any number of goroutines at once, each searching its own records.  Anything a
+search needs to keep track of, such as the budget of SearchBudget or the
+explanation built by Explain, belongs to that call alone.  The Searchable
+objects themselves are only safe to share between goroutines if they say so.
 */
 type Query interface {
 	/*
@@ -98,9 +306,383 @@ type Query interface {
 		Match is true if the searchable object satisfies the query.
 	*/
 	Search(s Searchable) (match bool)
+
+	/*
+		Score returns 0 if s does not match the query, otherwise the sum of the
+		weights of the terms in the query that s contains.  The weight of a term
+		is the number of words in its phrase, so `"climate change"` adds 2 where
+		`climate` adds 1, and the phrases of a NEAR term are added together.
+		Terms that compare values, such as `price:>5`, have a weight of 1.  A
+		boosted term multiplies its weight by the boost, so `tag:book^2` adds 2.
+
+		Negated terms do not count, while each alternative of an OR that is
+		present does, so records that satisfy more of the query score higher.
+		Optional terms count in the same way.  A match for a query without
+		positive terms, such as "NOT boat", scores 1.  Scores only depend on the
+		query and the record, so those of different records can be compared.
+	*/
+	Score(s Searchable) (score float64)
+
+	/*
+		SearchBudget executes the query against s as Search does, but gives up
+		once the terms searched for cost more than maxOps, returning
+		ErrBudgetExceeded.  Each search for a term costs as much as it adds to
+		Cost, so a phrase costs 1 and a regular expression more.
+
+		Unlike a timeout the result does not depend on the speed of the machine,
+		so the same query and record always give the same result.  Queries whose
+		Cost is within the budget are searched without counting.
+	*/
+	SearchBudget(s Searchable, maxOps int) (match bool, err error)
+
+	/*
+		SearchWithFields executes the query against s as Search does, except that
+		terms for the fields in overrides search the given values rather than
+		asking s.  Each value is searched as the text of its field, in the same
+		way as the query searches records, so `tenant:ac*` matches acme and
+		`tenant:>5` compares the value as a number.
+
+		This suits values known when searching that are not held in the record,
+		such as the tenant a user belongs to: `tenant:acme merry` only matches
+		if overrides gives acme as the tenant.
+	*/
+	SearchWithFields(s Searchable, overrides map[string]string) (match bool)
+
+	/*
+		SearchAny returns true if a single one of the items matches the whole
+		query, as AnyMatch does.  Searching SearchableSliceOf(items) instead
+		lets each term match a different item, so `tag:book author:smith`
+		would match a book by one author and a leaflet by smith.
+	*/
+	SearchAny(items []Searchable) (match bool)
+
+	/*
+		Split divides the query for a pipeline that retrieves candidate records
+		and then ranks them.  filter holds the terms a record must match or not
+		match, while scorer holds the optional terms, such as ?tag:featured,
+		that only add to the score.
+
+		For `boat whale ?tag:featured` the filter is `boat whale` and the
+		scorer is `?tag:featured`.  Searching with filter matches the same
+		records as the query, so if the query only has optional terms the
+		filter is the whole query.
+
+		A boosted term that must match, such as `tag:book^2`, is divided
+		between the two: the filter requires `tag:book` while the scorer holds
+		`?tag:book^2`, so the boost only counts towards the score.
+	*/
+	Split() (filter Query, scorer Query)
+
+	/*
+		Explain executes the query against s as Search does, reporting whether
+		each of the terms and operators of the query matched.  The Matched field
+		of the returned Explanation is the result of Search.
+
+		Explain is slower than Search, so is intended for debugging why a
+		record does or does not match.
+	*/
+	Explain(s Searchable) (explanation Explanation)
+
+	/*
+		MatchedTerms returns the terms responsible for s matching the query,
+		in query order, or nil if it does not match.  Terms within a NOT are
+		never reported, and only the first alternative of an OR that matches
+		is, as a search stops there.  Optional terms are reported if they
+		matched.
+
+		A match without any terms to report, as for `NOT boat`, returns an
+		empty slice that is not nil, so a nil result always means s did not
+		match.
+	*/
+	MatchedTerms(s Searchable) (matched []MatchedTerm)
+
+	/*
+		MatchString is a shortcut for Search(SearchableMatchString(s)).  This
+		is not the same as Search(SearchableString(s)): fuzzy, NEAR, prefix and
+		regular expression terms, and matching options such as
+		Options.CaseInsensitive, are applied to the text rather than the
+		phrase being looked for as written.
+	*/
+	MatchString(s string) (match bool)
+
+	/*
+		MatchStrings is a shortcut for Search(SearchableMatchStringSlice(ss)),
+		which differs from Search(SearchableStringSlice(ss)) in the same way
+		as MatchString.
+	*/
+	MatchStrings(ss []string) (match bool)
+
+	/*
+		Cost returns a rough estimate of how expensive the query is to run.
+
+		The estimate is static, based only on the parsed query, and grows with the
+		number of terms in it.  Terms are weighted by their kind, so a regular
+		expression, fuzzy or NEAR term costs more than a phrase.  It can be
+		used to throttle expensive queries.
+	*/
+	Cost() (cost int)
+
+	/*
+		NodeAt returns the innermost node of the parsed query whose span contains
+		the byte offset pos, or nil if pos is outside of the query.
+
+		This can be used by query editors to find the term, operator or group
+		under the cursor.
+	*/
+	NodeAt(pos int) (n Node)
+
+	/*
+		Keywords returns the words and phrases the query searches for, stripped
+		of operators and fields, such as ["boat", "whale", "shark"] for
+		`boat whale OR shark NOT dead tag:book`.
+
+		Negated terms are never included.  Terms restricted to a field are only
+		included if includeFieldValues is true.  Each keyword is listed once, in
+		the order it appears in the query.
+	*/
+	Keywords(includeFieldValues bool) (keywords []string)
+
+	/*
+		Highlight returns the parts of text matched by the terms of the query,
+		for showing a user why a record matched.  Every term that is not
+		negated is highlighted, whatever its field, including optional terms
+		and each alternative of an OR.  Text matched by terms that compare
+		values, such as `price:>5`, is not highlighted.
+
+		Matches are found as the query's Options would find them, so for
+		example only whole words are highlighted when WholeWord is set.  The
+		spans are in order, with those that overlap or touch merged into one.
+	*/
+	Highlight(text string) (spans []Span)
+
+	/*
+		HighlightString returns text with each span found by Highlight wrapped
+		in preTag and postTag, such as "<b>" and "</b>".  The text is not
+		escaped, so text shown as HTML should be escaped first.
+	*/
+	HighlightString(text, preTag, postTag string) (highlighted string)
+
+	/*
+		IndexableTerms returns the terms that search one of the fields listed in
+		Options.IndexedFields, so that they can be looked up in an index rather
+		than scanning every record.
+
+		Only terms that every matching record must contain are included, so
+		each term returned narrows the records to scan.  Negated and optional
+		terms, and the alternatives of an OR such as `tag:book OR
+		title:whale`, can not be used to find candidate records in an index and
+		are left out.
+	*/
+	IndexableTerms() (terms []Term)
+
+	/*
+		Diagnostics lists the problems found while parsing that did not stop the
+		query from being used, such as OR alternatives dropped because of
+		Options.MaxOrBranches, or a network such as 10.0.0.0/33 or a number
+		such as 1XB that is not valid and so is searched for as text.
+	*/
+	Diagnostics() (diagnostics []*ParseError)
+
+	/*
+		String returns the query as text, such as `boat whale OR shark`, that
+		QueryParser turns back into an equivalent Query.
+
+		The text is normalised: whitespace is collapsed, phrases containing
+		spaces or brackets are quoted, and shorthands such as tag:(book,leaflet)
+		are written out as tag:book OR tag:leaflet.  Saved queries are written
+		as the query they include.
+	*/
+	String() string
+}
+
+// query implements the Query interface for the package
+type query struct {
+	root        *AndNode
+	filters     filters
+	scorers     []weightedTerm
+	opts        Options
+	diagnostics []*ParseError
+	// calls are the filters used by searches that keep track of their own state, compiled once by callFilters
+	callOnce sync.Once
+	calls    filters
 }
 
-// filters implements the Query interface for the package
+// forSearch returns the Searchable used by a single search of s.  Options.MaxSearchDepth is applied to one returned by
+// SearchableSliceOf, while one built by FieldSearchableBuilder is given somewhere to keep the values it reads for the search.
+func (q *query) forSearch(s Searchable) Searchable {
+	switch s := s.(type) {
+	case *sliceSearchable:
+		if q.opts.MaxSearchDepth > 0 {
+			return s.withMaxDepth(q.opts.MaxSearchDepth)
+		}
+	case *fieldSearchable:
+		return s.forSearch()
+	}
+	return s
+}
+
+func (q *query) Search(s Searchable) (match bool) {
+	match, _ = q.SearchContext(context.Background(), s)
+	return match
+}
+
+func (q *query) SearchContext(ctx context.Context, s Searchable) (match bool, err error) {
+	s = q.forSearch(s)
+	if ctx.Done() == nil {
+		// The context can never be cancelled
+		return q.filters.Search(s), nil
+	}
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+	c := &cancellation{ctx: ctx}
+	match = q.callFilters().Search(&searchCall{Searchable: s, state: &callState{cancel: c}})
+	if c.err != nil {
+		return false, c.err
+	}
+	return match, nil
+}
+
+func (q *query) Score(s Searchable) (score float64) {
+	s = q.forSearch(s)
+	if !q.filters.Search(s) {
+		return 0
+	}
+	for _, term := range q.scorers {
+		if term.match(s) {
+			score += term.weight
+		}
+	}
+	// A match with no positive terms, such as "NOT boat", still needs a score to rank
+	if score == 0 {
+		score = 1
+	}
+	return score
+}
+
+func (q *query) SearchBudget(s Searchable, maxOps int) (match bool, err error) {
+	s = q.forSearch(s)
+	if nodeCost(q.root) <= maxOps {
+		return q.filters.Search(s), nil
+	}
+	b := &budget{remaining: maxOps}
+	match = q.callFilters().Search(&searchCall{Searchable: s, state: &callState{budget: b}})
+	if b.exceeded {
+		return false, ErrBudgetExceeded
+	}
+	return match, nil
+}
+
+func (q *query) Split() (filter Query, scorer Query) {
+	required := &AndNode{Start: q.root.Start, End: q.root.End}
+	optional := &AndNode{Start: q.root.Start, End: q.root.End}
+	for _, child := range q.root.Children {
+		if isOptional(child) {
+			optional.Children = append(optional.Children, child)
+		} else if term, isTerm := child.(*Term); isTerm && term.Boost != 0 {
+			unboosted, boosted := *term, *term
+			unboosted.Boost, boosted.Optional = 0, true
+			required.Children = append(required.Children, &unboosted)
+			optional.Children = append(optional.Children, &boosted)
+		} else {
+			required.Children = append(required.Children, child)
+		}
+	}
+	if len(required.Children) == 0 {
+		required = q.root
+	}
+	return buildQuery(required, q.opts, q.diagnostics), buildQuery(optional, q.opts, nil)
+}
+
+func (q *query) Explain(s Searchable) (explanation Explanation) {
+	s = q.forSearch(s)
+	explanation = explain(q.root, s, q.opts)
+	// Optional terms and Options.EmptyMatchesNone mean the top of the query may not match as an AND would
+	explanation.Matched = q.filters.Search(s)
+	return explanation
+}
+
+func (q *query) MatchedTerms(s Searchable) (matched []MatchedTerm) {
+	s = q.forSearch(s)
+	if !q.filters.Search(s) {
+		return nil
+	}
+	matched = []MatchedTerm{}
+	for _, child := range q.root.Children {
+		matched, _ = matchedTerms(child, "AND", s, q.opts, matched)
+	}
+	return matched
+}
+
+func (q *query) SearchWithFields(s Searchable, overrides map[string]string) (match bool) {
+	s = q.forSearch(s)
+	if len(overrides) == 0 {
+		return q.filters.Search(s)
+	}
+	return q.callFilters().Search(&searchCall{Searchable: s, state: &callState{overrides: overrides}})
+}
+
+func (q *query) SearchAny(items []Searchable) (match bool) {
+	return AnyMatch(q, items)
+}
+
+func (q *query) MatchString(s string) (match bool) {
+	return q.filters.Search(SearchableMatchString(s))
+}
+
+func (q *query) MatchStrings(ss []string) (match bool) {
+	return q.filters.Search(SearchableMatchStringSlice(ss))
+}
+
+func (q *query) String() string {
+	var b strings.Builder
+	formatNode(&b, q.root, true, q.opts.operatorWords(), q.opts.NotScope)
+	return b.String()
+}
+
+func (q *query) Cost() (cost int) {
+	return nodeCost(q.root)
+}
+
+func (q *query) NodeAt(pos int) (n Node) {
+	return nodeAt(q.root, pos)
+}
+
+func (q *query) Keywords(includeFieldValues bool) (keywords []string) {
+	return keywordsOf(q.root, includeFieldValues)
+}
+
+func (q *query) Highlight(text string) (spans []Span) {
+	return mergeSpans(highlightTerms(q.root, false, text, q.opts, nil))
+}
+
+func (q *query) HighlightString(text, preTag, postTag string) (highlighted string) {
+	var b strings.Builder
+	last := 0
+	for _, span := range q.Highlight(text) {
+		b.WriteString(text[last:span.Start])
+		b.WriteString(preTag)
+		b.WriteString(text[span.Start:span.End])
+		b.WriteString(postTag)
+		last = span.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (q *query) Diagnostics() (diagnostics []*ParseError) {
+	return q.diagnostics
+}
+
+func (q *query) IndexableTerms() (terms []Term) {
+	indexed := make(map[string]bool, len(q.opts.IndexedFields))
+	for _, field := range q.opts.IndexedFields {
+		indexed[field] = true
+	}
+	return indexableTerms(q.root, indexed, nil)
+}
+
+// filters holds the compiled filters that make up a query
 type filters []filter
 
 // Filters default to AND - as soon as one term doesn't match, return false
@@ -114,11 +696,41 @@ func (q filters) Search(s Searchable) (result bool) {
 	return true
 }
 
+// contains asks the Searchable for the field and phrase, using match if it is set and the Searchable supports it
+func contains(s Searchable, field, phrase string, match MatchFunc) bool {
+	if match != nil {
+		if ms, ok := s.(MatchSearchable); ok {
+			return ms.ContainsMatch(field, phrase, match)
+		}
+	}
+	return s.Contains(field, phrase)
+}
+
+// wrapper is implemented by the Searchable objects of the package that hold others, such as those returned by
+// SearchableSliceOf, so that terms can use the optional interfaces of the objects held
+type wrapper interface {
+	Searchable
+	// forward returns the result of f for the objects held, combined in the same way as phrases are
+	forward(field string, f filter) (match bool)
+}
+
+// forwarded returns a filter that applies f to the objects held by wrappers rather than to the wrappers themselves
+func forwarded(field string, f filter) filter {
+	var through filter
+	through = func(s Searchable) bool {
+		if w, ok := s.(wrapper); ok {
+			return w.forward(field, through)
+		}
+		return f(s)
+	}
+	return through
+}
+
 // mustContain returns true if the Searchable matches the field and phrase
-func mustContain(field, phrase string) filter {
+func mustContain(field, phrase string, match MatchFunc) filter {
 	// log.Printf("Adding must contain %v:%v\n", field, phrase)
 	return func(s Searchable) bool {
-		if s.Contains(field, phrase) {
+		if contains(s, field, phrase, match) {
 			// log.Printf("Must contain %v:%v returns true\n", field, phrase)
 			return true
 		}
@@ -128,10 +740,10 @@ func mustContain(field, phrase string) filter {
 }
 
 // mustContain returns true if the Searchable does not match the field and phrase
-func mustNotContain(field, phrase string) filter {
+func mustNotContain(field, phrase string, match MatchFunc) filter {
 	// log.Printf("Adding must NOT contain %v:%v\n", field, phrase)
 	return func(s Searchable) bool {
-		if s.Contains(field, phrase) {
+		if contains(s, field, phrase, match) {
 			return false
 		}
 		return true
@@ -169,196 +781,936 @@ func notFilter(subfilters ...filter) filter {
 	}
 }
 
+// isQuote returns true for any of the unicode quotation marks, checking the common ASCII quotes first
+func isQuote(char rune) bool {
+	if char < utf8.RuneSelf {
+		return char == '"' || char == '\''
+	}
+	return unicode.Is(unicode.Quotation_Mark, char)
+}
+
+// signPrefix returns the + or - that starts a term such as +boat or -shark, or 0 if there is none.
+// Signs followed by digits, as in -5, or by another sign are part of the phrase.
+func signPrefix(phrase string) byte {
+	if len(phrase) < 2 || (phrase[0] != '+' && phrase[0] != '-') {
+		return 0
+	}
+	if next := phrase[1]; next == '+' || next == '-' || ('0' <= next && next <= '9') {
+		return 0
+	}
+	return phrase[0]
+}
+
+// stripQuotes removes any quotation marks from the value, other than those escaped with a backslash.
+// Quotes surrounding the value are sliced off so that long values are only copied if quotes remain inside them.
+func stripQuotes(value string) string {
+	if strings.IndexByte(value, '\\') >= 0 {
+		// The backslashes are kept for unescape to remove
+		var b strings.Builder
+		escaped := false
+		for _, char := range value {
+			if !isQuote(char) || escaped {
+				b.WriteRune(char)
+			}
+			escaped = !escaped && char == '\\'
+		}
+		return b.String()
+	}
+	value = strings.TrimFunc(value, isQuote)
+	if strings.IndexFunc(value, isQuote) < 0 {
+		return value
+	}
+	// Use unicode categorisation to handle non-ASCII quote characters
+	return strings.Map(func(runechar rune) rune {
+		if isQuote(runechar) {
+			return -1
+		}
+		return runechar
+	}, value)
+}
+
+// splitQuotedMark separates a quoted value that is followed by the mark of a fuzzy or wildcard term, such as "it's beetle"~1,
+// into its unescaped phrase and the mark, given the opening quote and the value after it.  Only a quote of the same style as the
+// opening one closes the phrase, as in the lexer.  ok is false for any other value.
+func splitQuotedMark(open rune, value string) (phrase, mark string, ok bool) {
+	closing, escaped := -1, false
+	for i, char := range value {
+		if isQuote(char) && !escaped && sameQuoteStyle(open, char) {
+			if closing >= 0 {
+				// A second closing quote within the value
+				return "", "", false
+			}
+			closing = i
+		}
+		escaped = !escaped && char == '\\'
+	}
+	if closing <= 0 {
+		return "", "", false
+	}
+	_, size := utf8.DecodeRuneInString(value[closing:])
+	mark = value[closing+size:]
+	if mark != string(wildcard) {
+		if rest, _, isFuzzy := splitFuzzy("x" + mark); !isFuzzy || rest != "x" {
+			return "", "", false
+		}
+	}
+	return unescape(value[:closing]), mark, true
+}
+
+// unescapedIndex returns the index of the first instance of c in s that is not escaped with a backslash, or -1
+func unescapedIndex(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+// fieldSeparator returns the position of the colon between the field and value of a phrase such as title:merry, or -1 if
+// there is none.  negated is set if the field must not contain the value, written tag!:draft or tag!=draft, when the position
+// is that of the colon or equals sign after the !.  Escaped characters are never separators, and a ! elsewhere is ordinary.
+func fieldSeparator(phrase string) (pos int, negated bool) {
+	escapedAt := -1
+	for i := 0; i < len(phrase); i++ {
+		switch phrase[i] {
+		case '\\':
+			i++
+			escapedAt = i
+		case ':', '=':
+			negated = i > 1 && phrase[i-1] == '!' && escapedAt != i-1
+			if phrase[i] == ':' || negated {
+				return i, negated
+			}
+		}
+	}
+	return -1, false
+}
+
+// quotedField returns the field name of a phrase whose opening quote closes just before a colon, as in "release date":2021,
+// along with the position of the colon.  The phrase starts after the opening quote.  The position is -1 if the phrase has
+// no quoted field, as for "merry time" or "a:b".
+func quotedField(phrase string) (field string, colon int) {
+	escaped := false
+	for i, char := range phrase {
+		switch {
+		case escaped:
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case isQuote(char):
+			if next := i + utf8.RuneLen(char); next < len(phrase) && phrase[next] == ':' {
+				return phrase[:i], next
+			}
+			return "", -1
+		}
+	}
+	return "", -1
+}
+
+// unescape removes the backslashes that escape the characters after them, so tag\:special becomes tag:special
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// anyFieldList splits a field name of the form any(title,body) into its fields
+func anyFieldList(fieldName string) (fields []string, isAny bool) {
+	if !strings.HasPrefix(fieldName, "any(") || !strings.HasSuffix(fieldName, ")") {
+		return nil, false
+	}
+	for _, field := range strings.Split(fieldName[len("any("):len(fieldName)-1], ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields, len(fields) > 0
+}
+
+// valueList splits a field value of the form (book,leaflet) into its values.
+// Empty items, such as in (book,,leaflet,), are left out and reported by emptyItems.
+func valueList(fieldValue string) (values []string, isList bool, emptyItems bool) {
+	if !strings.HasPrefix(fieldValue, "(") || !strings.HasSuffix(fieldValue, ")") || len(fieldValue) < 2 {
+		return nil, false, false
+	}
+	for _, value := range strings.Split(fieldValue[1:len(fieldValue)-1], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		} else {
+			emptyItems = true
+		}
+	}
+	return values, true, emptyItems
+}
+
+// listItemField splits an item of a value list that gives its own field, such as the other:bb of tag:(other:bb,cc)
+func listItemField(item string) (field, value string, hasField bool) {
+	if pos, negated := fieldSeparator(item); pos > 0 && !negated && pos < len(item)-1 {
+		return item[:pos], item[pos+1:], true
+	}
+	return "", item, false
+}
+
 type queryParserFrame struct {
-	filters   filters
+	nodes     []Node
 	orPhrase  bool
 	notPhrase bool
+	notStart  int
+	// groupField is the field applied to terms in the enclosing group
+	groupField string
+	// optional is set for a group whose terms only add to the score, written ?(boat whale)
+	optional bool
+	// pos is the position of the opening bracket and start is the beginning of the group including any field
+	pos, start int
+}
+
+/*
+ParseError describes a problem that prevented a query from being parsed.
+
+Pos is the byte offset in the original query string where the problem was found.
+*/
+type ParseError struct {
+	Pos     int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("search: %v at position %v", e.Message, e.Pos)
 }
 
 /*
 QueryParser truns a string such as "book whale" into a Query.
+
+QueryParser is forgiving: unbalanced brackets are ignored or closed at the end
+of the query.  Use QueryParserErr to have them reported.
 */
 func QueryParser(query string) (q Query) {
-	var phraseStart, phraseEnd int
-	var orPhrase, notPhrase, inquote bool
+	// Any problems found are ignored, using as much of the query as could be understood.
+	root, _ := parseQuery(query, Options{})
+	return newQuery(root, Options{})
+}
+
+/*
+QueryParserErr turns a string such as "book whale" into a Query, returning a
+*ParseError if the query is malformed.
+
+A bracket without a partner, such as in "(boat whale" or "boat) whale", is reported
+as an error, as is a quoted phrase without a closing quote such as `"boat whale`.
+A phrase is only closed by a quote of the same style as the one opening it, so
+the apostrophe in `"it's here"` is part of the phrase, while `"boat whale'` is
+not closed.
+A field without a value to search for, such as `title:` or `title:""`, or a
+colon without a field name, are also errors.  QueryParser drops these terms
+rather than searching for an empty phrase that every record contains.
+*/
+func QueryParserErr(query string) (q Query, err error) {
+	return QueryParserOptions(query, Options{})
+}
+
+/*
+QueryParserOptions turns a string such as "book whale" into a Query, using opts to
+control how the query is interpreted.
+
+If the query is malformed, as described in QueryParserErr, or breaks one of the
+restrictions given in opts, a *ParseError is returned.  Options that conflict
+with each other, such as setting both Collator and NewCollator or more than
+one of DefaultFields, DefaultField and ImplicitField, are reported as an
+error before the query is parsed.
+*/
+func QueryParserOptions(query string, opts Options) (q Query, err error) {
+	if err = opts.check(); err != nil {
+		return nil, err
+	}
+	root, err := parseQuery(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.NamedQueries == nil {
+		return newQuery(root, opts), nil
+	}
+	// Saved queries are included once the options have been applied, as they were applied to them when they were parsed
+	references := savedReferences(root, opts, nil)
+	diagnostics := applyOptions(root, opts)
+	if err = expandSaved(root, opts.NamedQueries, references, nil); err != nil {
+		return nil, err
+	}
+	if err = checkIncluded(root, opts, references); err != nil {
+		return nil, err
+	}
+	return buildQuery(root, opts, diagnostics), nil
+}
+
+// newQuery applies the limits in opts to the parsed query and compiles it into the filters that carry out the search
+func newQuery(root *AndNode, opts Options) *query {
+	return buildQuery(root, opts, applyOptions(root, opts))
+}
+
+// applyOptions applies the limits and transforms in opts to the terms of a parsed query, returning the problems found
+func applyOptions(root *AndNode, opts Options) (diagnostics []*ParseError) {
+	if opts.MaxOrBranches > 0 {
+		diagnostics = limitOrBranches(root, opts.MaxOrBranches, diagnostics)
+	}
+	diagnostics = parseNetworks(root, diagnostics)
+	diagnostics = checkUnits(root, opts, diagnostics)
+	if opts.NormalizePunctuation {
+		normalizeTerms(root, NormalizePunctuation)
+	}
+	if opts.Normalizer != nil {
+		normalizeTerms(root, opts.Normalizer)
+	}
+	if opts.NormalizeWhitespace {
+		normalizeTerms(root, collapseWhitespace)
+	}
+	if len(opts.DefaultFields) > 0 {
+		implicitFields(root, opts.DefaultFields)
+	} else if opts.DefaultField != "" {
+		implicitField(root, opts.DefaultField)
+	} else if opts.ImplicitField != "" {
+		implicitField(root, opts.ImplicitField)
+	}
+	if len(opts.FieldTransforms) > 0 {
+		transformTerms(root, opts.FieldTransforms)
+	}
+	return diagnostics
+}
+
+// buildQuery compiles a query tree that has already had the options applied to its terms
+func buildQuery(root *AndNode, opts Options, diagnostics []*ParseError) *query {
+	if opts.NewCollator != nil && opts.Collator == nil {
+		// The pool is kept in the options, so queries built from this one, such as by Split, share it
+		opts.Collator = newPooledCollator(opts.NewCollator)
+	}
+	return &query{
+		root:        root,
+		filters:     compileQuery(root, opts, nil),
+		scorers:     positiveTerms(root, false, opts, nil),
+		opts:        opts,
+		diagnostics: diagnostics,
+	}
+}
+
+// compileQuery compiles the filters for the top of the query tree.
+// Optional terms are left out as they are in any AndNode, see compileAnd.
+// An empty query matches everything unless Options.EmptyMatchesNone is set.
+func compileQuery(root *AndNode, opts Options, wrap termWrapper) filters {
+	if len(root.Children) == 0 && opts.EmptyMatchesNone {
+		return filters{func(Searchable) bool { return false }}
+	}
+	fs := compileAnd(root.Children, opts, wrap)
+	if paths := sharedPaths(root); len(paths) > 0 {
+		// Terms whose fields pass through the same array, such as items.name and items.colour, match the same element
+		return filters{scoped(paths, fs)}
+	}
+	return fs
+}
 
+// parseQuery does the work of building the query tree for QueryParser and QueryParserOptions.
+// The tree returned is always usable, err records the first problem found in the query.
+func parseQuery(query string, opts Options) (root *AndNode, err error) {
+	var orPhrase, notPhrase bool
+	// The start of the last NOT keyword
+	var notStart int
+	// The field applied to terms without one, set by groups such as title:(merry OR battle)
+	var groupField string
+	// Set when the last result is a group that joins the enclosing AND unless an OR follows it, as in (aa bb) OR cc
+	var groupPending bool
+	// The number of groups not started as they are nested beyond Options.MaxDepth, and the number of terms so far
+	var skippedGroups, termCount int
+	// Set after a NOT with Options.NotScope of NotRestOfClause, until the next OR or bracket
+	var notClause bool
+	// Set once a term has been dropped as one of Options.StopWords
+	var stopped bool
+	// The sign before the next bracket, as in -(aa bb), and its position, or 0
+	var groupSign byte
+	var groupSignStart int
+	// The distance of a NEAR/5 or ONEAR/5 operator waiting for the phrase after it, or -1
+	nearDistance, nearOrdered, nearPos, nearText := -1, false, 0, ""
+	// The words used as operators
+	kw := opts.operatorWords()
+
+	// Positions reported in errors and node spans are relative to the untrimmed query
+	queryLength := len(query)
+	offset := len(query) - len(strings.TrimLeftFunc(query, unicode.IsSpace))
 	query = strings.TrimSpace(query)
 
-	results := make(filters, 0, 5)
+	results := make([]Node, 0, 5)
 
 	stack := make([]queryParserFrame, 0, 2)
 
-	popStack := func() {
+	// Record an error if a NEAR operator is not followed by a phrase it can join
+	dropNear := func() {
+		if nearDistance >= 0 && err == nil {
+			err = &ParseError{Pos: nearPos, Message: fmt.Sprintf("%v must be between two phrases", nearText)}
+		}
+		nearDistance = -1
+	}
+
+	// Move the children of a pending group into the enclosing AND now that no OR can follow it
+	mergeGroup := func() {
+		if groupPending {
+			group := results[len(results)-1].(*AndNode)
+			results = append(results[:len(results)-1], group.Children...)
+			groupPending = false
+		}
+	}
+
+	popStack := func(end int) {
+		dropNear()
+		if skippedGroups > 0 {
+			// The end of a group that was never started
+			skippedGroups--
+			return
+		}
 		// Do nothing if there is nothing on the stack.
 		if len(stack) == 0 {
 			return
 		}
+		mergeGroup()
 		stackFrame := stack[len(stack)-1]
 		// log.Printf("Popping stack: %v\n", stackFrame)
 		stack = stack[:len(stack)-1]
 		// Stick the nested results into the previous frame
 		bracketResults := results
-		results = stackFrame.filters
+		results = stackFrame.nodes
 		orPhrase = stackFrame.orPhrase
 		notPhrase = stackFrame.notPhrase
+		notStart = stackFrame.notStart
+		groupField = stackFrame.groupField
+		if stackFrame.optional {
+			markOptional(bracketResults)
+		}
+		group := &AndNode{Children: bracketResults, Start: offset + stackFrame.start, End: offset + end}
+		notGroup := &NotNode{Child: group, Start: notStart, End: group.End}
 
 		// We have just closed brackets - now need to add the contents into the main results.
 		// To do this we need to know whether they are NOT or OR or default AND
 		if orPhrase {
 			// Try and build an OR with the previous phrase
 			if len(results) > 0 {
-				previousFilter := results[len(results)-1]
+				previousNode := results[len(results)-1]
 				// Is this a compound OR NOT search?
 				if notPhrase {
-					// log.Printf("Adding in the OR with NOT the bracketResults.Search %v\n", bracketResults)
-					results[len(results)-1] = orFilter(previousFilter, notFilter(bracketResults...))
+					// log.Printf("Adding in the OR with NOT the bracketResults %v\n", bracketResults)
+					results[len(results)-1] = orWith(previousNode, notGroup)
 				} else {
-					// log.Printf("Adding in the OR with the bracketResults.Search %v\n", bracketResults)
-					results[len(results)-1] = orFilter(previousFilter, bracketResults.Search)
+					// log.Printf("Adding in the OR with the bracketResults %v\n", bracketResults)
+					results[len(results)-1] = orWith(previousNode, group)
 				}
-			} else {
+			} else if len(bracketResults) == 1 {
 				// Suppress the OR and search for it
+				results = append(results, bracketResults[0])
+			} else {
+				// Suppress the OR, keeping the group whole in case an OR follows it, as in OR (aa bb) OR cc
 				// log.Printf("Suppressing OR and adding %v as AND\n", bracketResults)
-				results = append(results, bracketResults.Search)
+				results = append(results, group)
+				groupPending = true
 			}
 		} else if notPhrase {
 			// log.Printf("Adding bracket results %v as a NOT AND\n", bracketResults)
-			results = append(results, notFilter(bracketResults...))
+			results = append(results, notGroup)
+		} else if len(bracketResults) == 1 {
+			results = append(results, bracketResults[0])
 		} else {
+			// AND is associative, so the group's contents join the enclosing AND,
+			// but not until it is known that an OR does not follow the group.
+			// An empty group such as "boat () whale" always matches, so it is dropped then,
+			// while in "boat () OR whale" it is kept to match everything.
 			// log.Printf("Adding bracket results %v as an AND\n", bracketResults)
-			results = append(results, bracketResults.Search)
+			results = append(results, group)
+			groupPending = true
 		}
 
 		orPhrase = false
 		notPhrase = false
 	}
 
-	pushStack := func() {
+	// pushStack starts a group at pos, with the given field applied to terms without one
+	pushStack := func(pos, start int, field string) {
+		dropNear()
+		if opts.MaxDepth > 0 && len(stack) >= opts.MaxDepth {
+			// The group is not started, so its terms join the group it is in
+			if err == nil {
+				err = &ParseError{Pos: offset + pos, Message: fmt.Sprintf("brackets are nested more than %v deep", opts.MaxDepth)}
+			}
+			skippedGroups++
+			return
+		}
+		if !orPhrase {
+			mergeGroup()
+		}
+		groupPending = false
 		stackFrame := queryParserFrame{
-			filters:   results,
-			orPhrase:  orPhrase,
-			notPhrase: notPhrase,
+			nodes:      results,
+			orPhrase:   orPhrase,
+			notPhrase:  notPhrase,
+			notStart:   notStart,
+			groupField: groupField,
+			optional:   groupSign == '?',
+			pos:        pos,
+			start:      start,
 		}
+		groupField = field
 		// log.Printf("Pushing stack: %v\n", stackFrame)
 		stack = append(stack, stackFrame)
-		results = make(filters, 0, 5)
+		results = make([]Node, 0, 5)
 		orPhrase = false
 		notPhrase = false
 	}
 
-	// Closure to handle any found search phrases
-	// The closure ensures that the same logic is used inside and outside of the loop
-	phraseHandler := func() {
-		if phraseStart < phraseEnd {
-			phraseValue := query[phraseStart : phraseEnd+1]
-			// log.Printf("Handling phrase value %v\n", phraseValue)
-			if phraseValue == "OR" {
-				// Treat the next phrase as an OR with the previous one
-				orPhrase = true
-			} else if phraseValue == "NOT" {
-				// Treat next phrase as a must not contain
+	// Record an error if a closing bracket at pos has nothing to close
+	unmatchedBracket := func(pos int) {
+		if err == nil && len(stack) == 0 && skippedGroups == 0 {
+			err = &ParseError{Pos: offset + pos, Message: "closing bracket without an opening bracket"}
+		}
+	}
+
+	// Closure to handle the search phrases found by the lexer
+	phraseHandler := func(tok token) {
+		tokenStart, end := tok.start, tok.end
+		phraseValue := tok.text
+		// Keywords are only recognised outside quotes, so "OR" searches for the word
+		keyword := tok.valueStart == tok.start
+		// log.Printf("Handling phrase value %v\n", phraseValue)
+		if keyword && kw.is(phraseValue, kw.and) {
+			// AND is the default, so only separates the phrases either side of it
+		} else if keyword && kw.is(phraseValue, kw.or) {
+			// Treat the next phrase as an OR with the previous one
+			orPhrase = true
+			notClause = false
+		} else if keyword && kw.is(phraseValue, kw.not) {
+			// Treat next phrase as a must not contain, along with the rest of the clause for NotRestOfClause
+			if !notPhrase {
+				notStart = offset + tokenStart
+			}
+			notPhrase = true
+			notClause = opts.NotScope == NotRestOfClause
+		} else if distance, ordered, isNear := splitNear(phraseValue); keyword && isNear {
+			// Join the next phrase to the previous one
+			dropNear()
+			nearDistance, nearOrdered, nearPos, nearText = distance, ordered, offset+tokenStart, phraseValue
+		} else {
+			if notClause && !notPhrase && nearDistance < 0 {
+				// Each term after the NOT that started the clause is excluded in turn
+				notStart = offset + tokenStart
 				notPhrase = true
+			}
+			valueStart := tok.valueStart
+			// A term such as +boat must be present, as every term must, while -shark is the same as NOT shark
+			sign := signPrefix(phraseValue)
+			// The operators before the sign, restored if the term turns out to be dropped
+			notBefore, notStartBefore := notPhrase, notStart
+			if tok.valueStart == tok.start && sign != 0 {
+				if sign == '-' {
+					if !notPhrase {
+						notStart = offset + tokenStart
+					}
+					notPhrase = true
+				}
+				phraseValue = phraseValue[1:]
+				valueStart++
+			}
+			// An optional term such as ?tag:featured, while "?wing" searches for the ? as written
+			optional := tok.valueStart == tok.start && len(phraseValue) > 1 && phraseValue[0] == '?'
+			if optional {
+				phraseValue = phraseValue[1:]
+				valueStart++
+			}
+			// A boosted term such as tag:book^2 or "floating boat"^2
+			var boost float64
+			var boosted bool
+			if boostable(query[tokenStart:end]) {
+				phraseValue, boost, boosted = splitBoost(phraseValue)
+			}
+			if boosted && tok.valueStart != tok.start && valueStart == tok.valueStart {
+				// The lexer has taken the opening quote, but not the closing one before the boost
+				if char, size := utf8.DecodeLastRuneInString(phraseValue); isQuote(char) {
+					phraseValue = phraseValue[:len(phraseValue)-size]
+				}
+			}
+			fieldBreak, negatedField := fieldSeparator(phraseValue)
+			// A field name in quotes, such as "release date":2021, may hold spaces and colons
+			quotedName, quotedBreak := "", -1
+			if tok.valueStart != tok.start && valueStart == tok.valueStart {
+				quotedName, quotedBreak = quotedField(phraseValue)
+			} else if char, size := utf8.DecodeRuneInString(phraseValue); valueStart > tok.valueStart && isQuote(char) {
+				// The quote follows a prefix, as in -"release date":2021
+				if quotedName, quotedBreak = quotedField(phraseValue[size:]); quotedBreak > 0 {
+					quotedBreak += size
+				}
+			}
+			var fieldName, fieldValue string
+			if quotedBreak > 0 {
+				fieldBreak = quotedBreak
+				fieldName = quotedName
+				fieldValue = stripQuotes(phraseValue[fieldBreak+1:])
+			} else if fieldBreak > 0 {
+				fieldName = phraseValue[:fieldBreak]
+				if negatedField {
+					// tag!:draft and tag!=draft are the same as NOT tag:draft
+					fieldName = phraseValue[:fieldBreak-1]
+					if !notPhrase {
+						notStart = offset + tokenStart
+					}
+					notPhrase = true
+				}
+				fieldValue = phraseValue[fieldBreak+1:]
+				// Remove any stray quotes, handles the form title:"A book"
+				fieldValue = stripQuotes(fieldValue)
+			} else if optional || valueStart > tok.valueStart {
+				// The quotes of ?"merry time" or -"merry time" follow the prefix so are part of the phrase
+				fieldValue = stripQuotes(phraseValue)
+				fieldName = groupField
 			} else {
-				fieldBreak := strings.Index(phraseValue, ":")
-				var fieldName, fieldValue string
-				if fieldBreak > 0 {
-					fieldName = phraseValue[:fieldBreak]
-					fieldValue = phraseValue[fieldBreak+1:]
-					// Remove any stray quotes, handles the form title:"A book"
-					// fieldValue = strings.Replace(fieldValue, "'", "", -1)
-					// fieldValue = strings.Replace(fieldValue, "\"", "", -1)
-					// Use unicode categorisation to handle non-ASCII quote characters
-					fieldValue = strings.Map(func(runechar rune) rune {
-						if unicode.Is(unicode.Quotation_Mark, runechar) {
-							return -1
+				fieldValue = phraseValue
+				fieldName = groupField
+			}
+			// A value with an escaped character, such as price:\>5 or \OR, is searched for as written
+			literal := strings.IndexByte(phraseValue[fieldBreak+1:], '\\') >= 0
+			fieldName, fieldValue = unescape(fieldName), unescape(fieldValue)
+			// The ^= of title:^="Once upon" comes before any quote, so title:"^=x" searches for ^=x as written
+			prefixed := strings.HasPrefix(phraseValue[fieldBreak+1:], prefixOp) && (fieldBreak >= 0 || tok.valueStart == tok.start)
+			// A trailing * is only a wildcard if the value was not quoted
+			unquoted := (tok.valueStart == tok.start || quotedBreak > 0) && strings.IndexFunc(phraseValue[fieldBreak+1:], isQuote) != 0
+			if fieldBreak < 0 && valueStart > tok.valueStart && len(stripQuotes(phraseValue)) <= 1 {
+				// A single character or empty quotes after a prefix, as in -a, ?a or -"", are dropped as the lexer
+				// drops a or "" on its own, leaving any operator before it for the next term
+				notPhrase, notStart = notBefore, notStartBefore
+				return
+			}
+			if fieldBreak < 0 && unquoted && sign != '+' && nearDistance < 0 && opts.StopWords[strings.ToLower(fieldValue)] {
+				// A stop word such as the is dropped, along with any operator before it, unless quoted or written +the
+				stopped = true
+				orPhrase = false
+				notPhrase = false
+				return
+			}
+			if fieldBreak == 0 && tok.valueStart == tok.start || fieldBreak > 0 && fieldValue == "" {
+				// Nothing to search for in title: or title:"", and no field in a bare :merry.
+				// The term is dropped rather than searching for the empty phrase that every record contains.
+				if err == nil {
+					message := fmt.Sprintf("empty value for field %v", fieldName)
+					if fieldBreak == 0 {
+						message = "missing field name before colon"
+					}
+					err = &ParseError{Pos: offset + valueStart + fieldBreak, Message: message}
+				}
+				orPhrase = false
+				notPhrase = false
+				return
+			}
+			// A regular expression such as title:/^Once .* time$/ is kept as written, escapes included
+			var re *regexp.Regexp
+			pattern, isRegex := regexValue(phraseValue[fieldBreak+1:])
+			if fieldBreak > 0 && isRegex {
+				var reErr error
+				if re, reErr = regexp.Compile(pattern); reErr != nil && err == nil {
+					err = &ParseError{
+						Pos:     offset + valueStart + fieldBreak + 1,
+						Message: fmt.Sprintf("invalid regular expression %q", pattern),
+					}
+				}
+			}
+			fieldNames := []string{fieldName}
+			anyFields, isAny := anyFieldList(fieldName)
+			if isAny {
+				fieldNames = anyFields
+			}
+			fieldValues := []string{fieldValue}
+			// A quoted value, as in tag:"(book,leaflet)", is a phrase rather than a list
+			var listValues []string
+			var isList, emptyItems bool
+			if unquoted {
+				listValues, isList, emptyItems = valueList(fieldValue)
+			}
+			var quotedItems map[string]bool
+			if fieldBreak > 0 && isList && !literal && re == nil {
+				if emptyItems && err == nil && opts.StrictLists {
+					err = &ParseError{
+						Pos:     offset + valueStart + fieldBreak + 1,
+						Message: fmt.Sprintf("empty item in value list for field %v", fieldName),
+					}
+				}
+				if len(listValues) == 0 {
+					// Nothing to search for, so the term is dropped in the same way as an empty group
+					orPhrase = false
+					notPhrase = false
+					return
+				}
+				fieldValues = listValues
+				// Quoted items, such as the "10:30" of time:("10:30",noon), are values whatever they hold
+				rawValues, _, _ := valueList(phraseValue[fieldBreak+1:])
+				for _, item := range rawValues {
+					if char, _ := utf8.DecodeRuneInString(item); isQuote(char) {
+						if quotedItems == nil {
+							quotedItems = make(map[string]bool)
 						}
-						return runechar
-					}, fieldValue)
-				} else {
-					fieldValue = phraseValue
+						quotedItems[unescape(stripQuotes(item))] = true
+					}
+				}
+			}
+			// A quoted value followed by a mark, such as "it's beetle"~1 or title:"boa con"*, is a fuzzy or wildcard term
+			var markedPhrase, mark string
+			if !isList && re == nil {
+				quotedValue := phraseValue[fieldBreak+1:]
+				if fieldBreak < 0 && valueStart == tok.valueStart && tok.valueStart != tok.start {
+					// The lexer has already taken the opening quote from the value
+					quotedValue = query[tok.start:tok.valueStart] + quotedValue
 				}
-				if orPhrase {
-					// Try and build an OR with the previous phrase
-					if len(results) > 0 {
-						previousFilter := results[len(results)-1]
-						// Is this a compound OR NOT search?
-						if notPhrase {
-							results[len(results)-1] = orFilter(previousFilter, mustNotContain(fieldName, fieldValue))
+				if open, size := utf8.DecodeRuneInString(quotedValue); isQuote(open) {
+					var isMarked bool
+					if markedPhrase, mark, isMarked = splitQuotedMark(open, quotedValue[size:]); !isMarked {
+						mark = ""
+					}
+				}
+			}
+			var terms []Node
+			for nameIndex, listName := range fieldNames {
+				if err == nil && listName != existsField && listName != missingField && !opts.allowedField(listName) {
+					err = &ParseError{Pos: offset + valueStart, Message: fmt.Sprintf("field %v is not allowed", listName)}
+				}
+				for _, value := range fieldValues {
+					name := listName
+					if itemName, itemValue, hasField := listItemField(value); isList && hasField && !quotedItems[value] {
+						// An item with a field of its own, as in tag:(other:bb,cc), is searched for in that field alone
+						if nameIndex > 0 {
+							continue
+						}
+						name, value = itemName, itemValue
+						if err == nil && name != existsField && name != missingField && !opts.allowedField(name) {
+							err = &ParseError{Pos: offset + valueStart + fieldBreak + 1, Message: fmt.Sprintf("field %v is not allowed", name)}
+						}
+					}
+					if err == nil && (name == existsField || name == missingField) && !opts.allowedField(value) {
+						err = &ParseError{Pos: offset + valueStart + fieldBreak + 1, Message: fmt.Sprintf("field %v is not allowed", value)}
+					}
+					if err == nil && !opts.allowedFieldValue(name, value) {
+						err = &ParseError{
+							Pos:     offset + valueStart + fieldBreak + 1,
+							Message: fmt.Sprintf("%q is not an allowed value for field %v", value, name),
+						}
+					}
+					if name == fieldNameField {
+						if _, matchErr := path.Match(value, ""); matchErr != nil && err == nil {
+							err = &ParseError{
+								Pos:     offset + valueStart + fieldBreak + 1,
+								Message: fmt.Sprintf("invalid field name pattern %q", value),
+							}
+						}
+						terms = append(terms, &Term{Phrase: value, Kind: TermFieldName, Optional: optional, Start: offset + tokenStart, End: offset + end})
+						continue
+					}
+					if name == existsField || name == missingField {
+						exists := &Term{Phrase: value, Kind: TermFieldExists, Start: offset + tokenStart, End: offset + end}
+						if name == missingField {
+							// _missing_:thumbnail is the same as NOT _exists_:thumbnail
+							terms = append(terms, &NotNode{Child: exists, Start: exists.Start, End: exists.End})
 						} else {
-							results[len(results)-1] = orFilter(previousFilter, mustContain(fieldName, fieldValue))
+							exists.Optional = optional
+							terms = append(terms, exists)
 						}
-					} else {
-						// Suppress the OR and search for it
-						results = append(results, mustContain(fieldName, fieldValue))
+						continue
 					}
-				} else if notPhrase {
-					results = append(results, mustNotContain(fieldName, fieldValue))
-				} else {
-					results = append(results, mustContain(fieldName, fieldValue))
+					term := &Term{Field: name, Phrase: value, Optional: optional, Start: offset + tokenStart, End: offset + end}
+					if re != nil {
+						term.Kind, term.Phrase, term.Regexp = TermRegex, pattern, re
+					} else if mark == string(wildcard) {
+						term.Kind, term.Phrase = TermWildcard, markedPhrase
+					} else if mark != "" {
+						_, term.Distance, _ = splitFuzzy(markedPhrase + mark)
+						term.Kind, term.Phrase = TermFuzzy, markedPhrase
+					} else if literal {
+						// Searched for as written
+					} else if (prefixed || isList && !quotedItems[value]) && strings.HasPrefix(value, prefixOp) && len(value) > len(prefixOp) && name != "" {
+						// A phrase at the start of the field such as title:^="Once upon"
+						term.Kind, term.Phrase = TermPrefix, value[len(prefixOp):]
+					} else if lowOp, low, highOp, high, isRange := splitRange(value); isRange && name != "" {
+						// Numbers between two bounds such as price:[5 TO 20] or price:{0 TO 1}
+						// A bound with an unknown unit, as in price:[5 TO 1XB], is searched for as text and reported by checkUnits
+						if rangeErr := opts.parseRange(term, lowOp, low, highOp, high); rangeErr != nil && !isUnitError(rangeErr) && err == nil {
+							err = &ParseError{Pos: offset + valueStart + fieldBreak + 1, Message: rangeErr.Error()}
+						}
+					} else if op, rest := splitComparison(value); op != 0 && name != "" {
+						// A comparison such as created:>2020-01-01, size:>1MB or name:>m
+						date, dateErr := opts.parseDate(rest)
+						number, isNumber, numberErr := opts.parseNumber(rest)
+						if rest == "" {
+							// Nothing to compare with, as in price:>, which is not worth reporting as an invalid date
+							if err == nil {
+								err = &ParseError{Pos: offset + valueStart + fieldBreak + 1, Message: fmt.Sprintf("missing value after %v", op)}
+							}
+						} else if dateErr == nil {
+							term.Kind, term.Op, term.Phrase, term.Time = TermDate, op, rest, date
+						} else if isNumber && numberErr == nil {
+							term.Kind, term.Op, term.Phrase, term.Number = TermNumber, op, rest, number
+						} else if !isNumber && isWord(rest) {
+							term.Kind, term.Op, term.Phrase = TermString, op, rest
+						} else if isUnitError(numberErr) {
+							// An unknown unit, as in size:>1XB, is searched for as text and reported by checkUnits
+						} else if err == nil {
+							if isNumber {
+								dateErr = numberErr
+							}
+							err = &ParseError{Pos: offset + valueStart + fieldBreak + 1, Message: dateErr.Error()}
+						}
+					} else if unquoted && !isList && isWildcard(value) {
+						// Words starting with boa, written boa*
+						term.Kind, term.Phrase = TermWildcard, value[:len(value)-1]
+					} else if phrase, distance, isFuzzy := splitFuzzy(value); unquoted && !isList && isFuzzy {
+						// Words within an edit distance, such as whale~1
+						term.Kind, term.Phrase, term.Distance = TermFuzzy, phrase, distance
+					}
+					if opts.Stemmer != nil && unquoted && !literal && term.Kind == TermContains && !opts.savedReference(term) {
+						// Quoted phrases, such as "running shoes", are searched for as written
+						term.Phrase = stemWords(term.Phrase, opts.Stemmer)
+					}
+					terms = append(terms, term)
+				}
+			}
+			for _, term := range terms {
+				if term, isTerm := term.(*Term); isTerm {
+					term.Boost = boost
+				}
+			}
+			if termCount += len(terms); opts.MaxTerms > 0 && termCount > opts.MaxTerms {
+				// Terms beyond the limit are dropped, along with any operator before them
+				if err == nil {
+					err = &ParseError{Pos: offset + tokenStart, Message: fmt.Sprintf("query has more than %v terms", opts.MaxTerms)}
 				}
 				orPhrase = false
 				notPhrase = false
+				return
+			}
+			// any(title,body):merry is the same as (title:merry OR body:merry)
+			// and tag:(book,leaflet) is the same as (tag:book OR tag:leaflet)
+			var positive Node = newOrNode(terms...)
+			if len(terms) == 1 {
+				positive = terms[0]
+			}
+			negative := &NotNode{Child: positive, Start: notStart, End: offset + end}
+			if nearDistance >= 0 && len(results) > 0 && !orPhrase && !notPhrase {
+				// A phrase close to the one before it, as in "climate change" NEAR/5 policy
+				if near, joined := joinNear(results[len(results)-1], positive, nearDistance, nearOrdered); joined {
+					results[len(results)-1] = near
+					nearDistance = -1
+					return
+				}
 			}
+			dropNear()
+			if !orPhrase {
+				mergeGroup()
+			}
+			groupPending = false
+			if orPhrase {
+				// Try and build an OR with the previous phrase
+				if len(results) > 0 {
+					previousNode := results[len(results)-1]
+					// Is this a compound OR NOT search?
+					if notPhrase {
+						results[len(results)-1] = orWith(previousNode, negative)
+					} else {
+						results[len(results)-1] = orWith(previousNode, positive)
+					}
+				} else {
+					// Suppress the OR and search for it
+					results = append(results, positive)
+				}
+			} else if notPhrase {
+				results = append(results, negative)
+			} else {
+				results = append(results, positive)
+			}
+			orPhrase = false
+			notPhrase = false
 		}
 	}
 
-	for pos, char := range query {
-		if unicode.IsSpace(char) {
-			if !inquote {
-				phraseHandler()
-				phraseStart = pos + utf8.RuneLen(char)
-				phraseStart = pos + 1
-			} else {
-				phraseEnd = pos
-			}
-		} else if pos == phraseStart {
-			// Begining of a new phrase.
-			// Assume we are going to consume a character
-			phraseStart += utf8.RuneLen(char)
-			// phraseStart++
-			// if !inquote && (char == '"' || char == '\'') {
-			if !inquote && unicode.Is(unicode.Quotation_Mark, char) {
-				inquote = true
-			} else if !inquote && char == '(' {
-				pushStack()
-			} else if !inquote && char == ')' {
-				phraseEnd = pos - 1
-				phraseHandler()
-				phraseStart = pos + 1
-				popStack()
-			} else {
-				// We didn't consume a character, so keep where we are
-				phraseStart -= utf8.RuneLen(char)
+	tokens := lex(query)
+	tokens.joinComparisons = opts.SpacedComparisons
+	tokens.keywords = kw
+	for tok, ok := tokens.token(); ok; tok, ok = tokens.token() {
+		switch tok.kind {
+		case tokenPhrase:
+			if len(tok.text) == 1 && tok.text != ":" {
+				// A single character kept for a spaced comparison that turned out not to be one
+				continue
 			}
-			phraseEnd = pos + utf8.RuneLen(char) - 1
-		} else {
-			// if inquote && (char == '"' || char == '\'') {
-			if inquote && unicode.Is(unicode.Quotation_Mark, char) {
-				inquote = false
-				phraseEnd = pos - utf8.RuneLen(char)
-				// } else if !inquote && (char == '"' || char == '\'') {
-			} else if !inquote && unicode.Is(unicode.Quotation_Mark, char) {
-				// Quote part way through the phrase, e.g. title:"A book"
-				inquote = true
-			} else if !inquote && char == ')' {
-				phraseEnd = pos - 1
-				phraseHandler()
-				phraseStart = pos + 1
-				popStack()
-			} else {
-				phraseEnd = pos + utf8.RuneLen(char) - 1
-				// phraseEnd = pos
+			phraseHandler(tok)
+		case tokenSign:
+			groupSign, groupSignStart = tok.text[0], tok.start
+		case tokenOpen:
+			// A NOT before the bracket applies to the group, but no further
+			notClause = false
+			field, start := groupField, tok.start
+			if groupSign != 0 {
+				// -(aa bb) is the same as NOT (aa bb), +(aa bb) as (aa bb), and the terms of ?(aa bb) are optional
+				start = groupSignStart
+				if groupSign == '-' {
+					if !notPhrase {
+						notStart = offset + start
+					}
+					notPhrase = true
+				}
+			}
+			if tok.fieldGroup {
+				field = tok.field
+				// -tag:(book OR leaflet) and tag!:(book OR leaflet) are the same as NOT tag:(book OR leaflet),
+				// and +tag:(book OR leaflet) as tag:(book OR leaflet)
+				sign := signPrefix(field)
+				if sign != 0 {
+					field = field[1:]
+				}
+				if sign == '-' || tok.negated {
+					if !notPhrase {
+						notStart = offset + tok.start
+					}
+					notPhrase = true
+				}
+				if err == nil && !opts.allowedField(field) {
+					err = &ParseError{Pos: offset + tok.start + len(tok.field) - len(field), Message: fmt.Sprintf("field %v is not allowed", field)}
+				}
 			}
+			pushStack(tok.pos, start, field)
+			groupSign = 0
+		case tokenClose:
+			notClause = false
+			unmatchedBracket(tok.pos)
+			popStack(tok.end)
 		}
 	}
-	// End of all phrases, spit it out.
-	phraseHandler()
 
+	// A missing closing quote swallows the rest of the query, including any closing brackets, so is reported first
+	if quotePos := tokens.unclosedQuote(); err == nil && quotePos >= 0 {
+		err = &ParseError{Pos: offset + quotePos, Message: "quote is not closed"}
+	}
+	dropNear()
 	// Close any still open brackets
+	if err == nil && len(stack) > 0 {
+		err = &ParseError{Pos: offset + stack[len(stack)-1].pos, Message: "opening bracket is not closed"}
+	}
+	skippedGroups = 0
 	for _ = range stack {
 		// log.Printf("Handling un-closed stack\n")
-		popStack()
+		popStack(len(query))
 	}
 
-	return results
+	mergeGroup()
+	if stopped && len(results) == 0 && opts.StopWordsMatchNone {
+		// Every term was a stop word, so NOT () stands in for a query that matches nothing
+		results = append(results, &NotNode{Child: &AndNode{End: queryLength}, End: queryLength})
+	}
+	root = &AndNode{Children: results, End: queryLength}
+	return root, err
 }
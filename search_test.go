@@ -1,7 +1,10 @@
 package search
 
 import (
+	"context"
+	"fmt"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -10,6 +13,12 @@ var testMaterial = SearchableStringSlice([]string{`Raw testing subject pingo goe
 More than one line exists!`,
 })
 
+// testMatchMaterial holds the text of testMaterial in the matching Searchable, which applies fuzzy, NEAR and regular expression terms
+var testMatchMaterial = SearchableMatchStringSlice([]string{`Raw testing subject pingo goes here.`,
+	`Raw body of the test message goes here.
+More than one line exists!`,
+})
+
 type testSearchObject struct {
 	Title string
 	Body  string
@@ -44,6 +53,23 @@ var testFieldMaterialWithEmoji = &testSearchObject{
 	Body:  "A beetle 🐜 OR battle NOT fought in a 🍾 bottle",
 }
 
+var testFieldMaterialWithSubject = SearchableFunc(func(field, phrase string) bool {
+	fields := map[string]string{
+		"title":   "Once upon a time",
+		"subject": "A very merry tale",
+		"body":    "A beetle battle fought in a bottle",
+	}
+	if field == "" {
+		for _, value := range fields {
+			if strings.Contains(value, phrase) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(fields[field], phrase)
+})
+
 type TestNote struct {
 	Body  string
 	Label string
@@ -193,6 +219,66 @@ var testCases = []struct {
 		true,
 		testMaterial,
 	},
+	{
+		"testAnd",
+		"test AND goes",
+		true,
+		testMaterial,
+	},
+	{
+		"testAndNotFound",
+		"test AND frog",
+		false,
+		testMaterial,
+	},
+	{
+		"testEarlyAnd",
+		"AND test",
+		true,
+		testMaterial,
+	},
+	{
+		"testEarlyAndNotFound",
+		"AND frog",
+		false,
+		testMaterial,
+	},
+	{
+		"testAndAnd",
+		"test AND AND goes",
+		true,
+		testMaterial,
+	},
+	{
+		"testLateAnd",
+		"test AND",
+		true,
+		testMaterial,
+	},
+	{
+		"testAndOr",
+		"frog OR test AND goes",
+		true,
+		testMaterial,
+	},
+	{
+		"testAndNot",
+		"test AND NOT frog",
+		true,
+		testMaterial,
+	},
+	{
+		"testQuotedAnd",
+		"'test AND goes'",
+		false,
+		testMaterial,
+	},
+	{
+		"testQuotedAndKeyword",
+		`"AND"`,
+		false,
+		testMaterial,
+	},
 	{
 		"testFieldAny",
 		"merry",
@@ -479,12 +565,290 @@ var testCases = []struct {
 		true,
 		testFieldMaterialWithEmoji,
 	},
+	{
+		"unicodeSpaceMatch",
+		"beetle\u3000battle\u00a0bottle",
+		true,
+		testFieldMaterial,
+	},
+	{
+		"unicodeSpaceNoMatch",
+		"beetle\u3000frog",
+		false,
+		testFieldMaterial,
+	},
 	{
 		"emojiSearchNoMatch",
 		"beetle 🍞 battle 🐜",
 		false,
 		testFieldMaterialWithEmoji,
 	},
+	{
+		"anyFieldMatch",
+		"any(title,subject):merry",
+		true,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"anyFieldNoMatch",
+		"any(title,body):merry",
+		false,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"anyFieldThreeFieldsMatch",
+		"any(title,subject,body):battle upon",
+		true,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"anyFieldQuotedMatch",
+		"any(title,subject):'merry tale'",
+		true,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"anyFieldNotMatch",
+		"upon NOT any(title,subject):battle",
+		true,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"anyFieldNotNoMatch",
+		"upon NOT any(title,subject):merry",
+		false,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"anyFieldOrMatch",
+		"frog OR any(title,subject):merry",
+		true,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"anyFieldInBracketsMatch",
+		"upon (frog OR any(title,subject):merry)",
+		true,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"anyFieldInBracketsNoMatch",
+		"upon (frog OR any(title,body):merry)",
+		false,
+		testFieldMaterialWithSubject,
+	},
+	{
+		"valueListMatch",
+		"title:(frog,merry)",
+		true,
+		testFieldMaterial,
+	},
+	{
+		"valueListNoMatch",
+		"title:(frog,battle)",
+		false,
+		testFieldMaterial,
+	},
+	{
+		"valueListEmptyItemsMatch",
+		"body:(,frog,,battle,)",
+		true,
+		testFieldMaterial,
+	},
+	{
+		"valueListEmptyNoTerm",
+		"title:(,) frog",
+		false,
+		testFieldMaterial,
+	},
+	{
+		"valueListQuotedMatch",
+		"title:('upon a',frog)",
+		true,
+		testFieldMaterial,
+	},
+	{
+		"valueListNotNoMatch",
+		"upon NOT title:(frog,merry)",
+		false,
+		testFieldMaterial,
+	},
+	{
+		"valueListInBracketsMatch",
+		"upon (frog OR body:(frog,bottle))",
+		true,
+		testFieldMaterial,
+	},
+	{
+		"valueListAnyFieldMatch",
+		"any(title,body):(frog,bottle)",
+		true,
+		testFieldMaterial,
+	},
+	// Title: "Once upon a very merry time",
+	// Body:  "A beetle battle fought in a bottle",
+	{
+		"fieldGroupPhraseOrFirstMatch",
+		`title:("upon a" OR "battle fought")`,
+		true,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupPhraseOrSecondMatch",
+		`body:("upon a" OR "in a bottle")`,
+		true,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupPhraseOrNoMatch",
+		`title:("battle fought" OR "in a bottle")`,
+		false,
+		testFieldMaterial,
+	},
+	{
+		"groupQuotedPhraseBeforeBracket",
+		`pingo OR ("battle fought")`,
+		true,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupPhraseAndMatch",
+		`title:("upon a" "merry time")`,
+		true,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupPhraseAndNoMatch",
+		`title:("upon a" "battle fought")`,
+		false,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupNotNoMatch",
+		`battle NOT title:("upon a" OR frog)`,
+		false,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupOrMatch",
+		`frog OR title:("merry time" OR frog)`,
+		true,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupInnerFieldMatch",
+		`title:(frog OR body:"battle fought")`,
+		true,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupNestedMatch",
+		`title:(upon (frog OR merry))`,
+		true,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupNestedNoMatch",
+		`title:(upon (frog OR battle))`,
+		false,
+		testFieldMaterial,
+	},
+	{
+		"fieldGroupEndsScope",
+		`title:(frog OR upon) battle`,
+		true,
+		testFieldMaterial,
+	},
+	{
+		"fuzzyQuotedMatch",
+		`body:"beatle"~1`,
+		true,
+		SearchableFields(map[string]string{"title": testFieldMaterial.Title, "body": testFieldMaterial.Body}),
+	},
+	{
+		"fuzzyQuotedPhraseNoMatch",
+		`"it's beetle"~`,
+		false,
+		SearchableFields(map[string]string{"title": testFieldMaterial.Title, "body": testFieldMaterial.Body}),
+	},
+	{
+		"wildcardQuotedPhraseMatch",
+		`body:"beetle bat"*`,
+		true,
+		SearchableFields(map[string]string{"title": testFieldMaterial.Title, "body": testFieldMaterial.Body}),
+	},
+	{
+		"wildcardQuotedPhraseNoMatch",
+		`"merry bat"*`,
+		false,
+		SearchableFields(map[string]string{"title": testFieldMaterial.Title, "body": testFieldMaterial.Body}),
+	},
+	{
+		"signedSingleCharacterDropped",
+		"test -a",
+		true,
+		testMaterial,
+	},
+	{
+		"requiredSingleCharacterDropped",
+		"+a OR frog test",
+		false,
+		testMaterial,
+	},
+	{
+		"matchFuzzy",
+		"pinga~1",
+		true,
+		testMatchMaterial,
+	},
+	{
+		"plainFuzzy",
+		"pinga~1",
+		false,
+		testMaterial,
+	},
+	{
+		"matchNear",
+		"test NEAR/1 message",
+		true,
+		testMatchMaterial,
+	},
+	{
+		"matchNearTooFar",
+		"body NEAR/1 message",
+		false,
+		testMatchMaterial,
+	},
+	{
+		"plainNearTooFar",
+		"body NEAR/1 message",
+		true,
+		testMaterial,
+	},
+	{
+		"matchRegex",
+		"body:/^Raw body/",
+		true,
+		testMatchMaterial,
+	},
+	{
+		"plainRegex",
+		"body:/^Raw body/",
+		false,
+		testMaterial,
+	},
+	{
+		"matchPlainTerms",
+		"raw test -frog",
+		false,
+		testMatchMaterial,
+	},
+	{
+		"matchPlainTermsFound",
+		"Raw test -frog",
+		true,
+		testMatchMaterial,
+	},
 }
 
 // var testFieldMaterialWithEmoji = &testSearchObject{
@@ -505,10 +869,10 @@ func TestSearchTestCases(t *testing.T) {
 func TestManualComposition(t *testing.T) {
 	query := filters{
 		orFilter(
-			mustContain("", "demo notes"),
-			mustContain("", "demo instructions"),
+			mustContain("", "demo notes", nil),
+			mustContain("", "demo instructions", nil),
 		),
-		mustNotContain("label", "Test"),
+		mustNotContain("label", "Test", nil),
 	}
 
 	tn := &TestNote{Body: "demo notes", Label: "Instructions"}
@@ -529,3 +893,920 @@ func TestSearchableString(t *testing.T) {
 		t.Errorf("Error matching in SearchableString.\n")
 	}
 }
+
+func TestSearchableStringTermKinds(t *testing.T) {
+	// SearchableString and SearchableStringSlice are plain SearchableFuncs, so terms that need the text are searched for as written
+	for _, test := range []struct {
+		Condition string
+		Record    string
+		Plain     bool
+		Matched   bool
+	}{
+		{"whale~1", "a wbale", false, true},
+		{"whale~1", "a whale", true, true},
+		{"boa*", "the boat", false, true},
+		{"boa*", "boa* here", true, true},
+		{"title:/^Once/", "Once upon", false, true},
+		{"title:/^Once/", "say /^Once/", true, false},
+		{"cat jumped", "The Cat jumped", false, true},
+	} {
+		q, _ := QueryParserOptions(test.Condition, Options{CaseInsensitive: true})
+		var plain SearchableFunc = SearchableString(test.Record)
+		var plainSlice SearchableFunc = SearchableStringSlice([]string{"", test.Record})
+		if q.Search(plain) != test.Plain || q.Search(plainSlice) != test.Plain {
+			t.Errorf("Expected %v for %v in SearchableString(%q), got %v\n", test.Plain, test.Condition, test.Record, q.Search(plain))
+		}
+		if q.MatchString(test.Record) != test.Matched || q.MatchStrings([]string{"", test.Record}) != test.Matched {
+			t.Errorf("Expected %v for %v in MatchString(%q), got %v\n", test.Matched, test.Condition, test.Record, q.MatchString(test.Record))
+		}
+	}
+}
+
+func TestSearchableMatchString(t *testing.T) {
+	// The matching string Searchables implement MatchSearchable, so matching options reach the text
+	var single MatchSearchable = SearchableMatchString("Raw body of the test message goes here.\nMore than one line exists!")
+	var slice MatchSearchable = SearchableMatchStringSlice([]string{"", "Raw body of the test message goes here.\nMore than one line exists!"})
+	for _, test := range []struct {
+		Condition string
+		Opts      Options
+		Match     bool
+	}{
+		{`"goes here. More"`, Options{NormalizeWhitespace: true}, true},
+		{`"goes here. More"`, Options{}, false},
+		{"RAW body", Options{CaseInsensitive: true}, true},
+		{"ody", Options{WholeWord: true}, false},
+	} {
+		q, err := QueryParserOptions(test.Condition, test.Opts)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if q.Search(single) != test.Match || q.Search(slice) != test.Match {
+			t.Errorf("Expected %v for %v with %+v, got %v\n", test.Match, test.Condition, test.Opts, q.Search(single))
+		}
+	}
+}
+
+func TestSearchableStringLimited(t *testing.T) {
+	record := "Subject: merry time\n\nA whale was seen from the boat"
+	for _, test := range []struct {
+		Condition string
+		MaxBytes  int
+		Match     bool
+	}{
+		{"merry", 20, true},
+		{"whale", 20, false},
+		{"merry NOT whale", 20, true},
+		{"whale", 1000, true},
+		{"time", 18, false},
+		{"merry", 0, false},
+		{"NOT merry", -1, true},
+	} {
+		if result := QueryParser(test.Condition).Search(SearchableStringLimited(record, test.MaxBytes)); result != test.Match {
+			t.Errorf("Expected %v for %v limited to %v bytes, got %v\n", test.Match, test.Condition, test.MaxBytes, result)
+		}
+	}
+	// The limit falls inside the two byte é, which is left out
+	if !QueryParser("NOT café").Search(SearchableStringLimited("café", 4)) {
+		t.Errorf("Expected a partial character not to be matched\n")
+	}
+	if !QueryParser("caf").Search(SearchableStringLimited("café", 4)) {
+		t.Errorf("Expected the text before a partial character to match\n")
+	}
+}
+
+func TestSearchableFields(t *testing.T) {
+	record := SearchableFields(map[string]string{
+		"title": "Once upon a very merry time",
+		"body":  "A beetle battle fought in a bottle",
+	})
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"merry", true},
+		{"title:merry", true},
+		{"body:merry", false},
+		{"tag:merry", false},
+		{"NOT tag:merry", true},
+		{"title:merry body:beetle", true},
+		{"title:beetle", false},
+		{"merry beetle", true},
+		{"_field_:tag", false},
+		{"_field_:ti*", true},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+	// The field agnostic helpers still search the whole record
+	if !QueryParser("body:merry").Search(SearchableString("Once upon a very merry time")) {
+		t.Errorf("Expected SearchableString to ignore the field\n")
+	}
+}
+
+func TestMatchString(t *testing.T) {
+	q1 := QueryParser("cat jumped")
+	if !q1.MatchString("The cat jumped over the mouse") {
+		t.Errorf("Error matching in MatchString.\n")
+	}
+	q2 := QueryParser("cat fox")
+	if q2.MatchString("The cat jumped over the mouse") {
+		t.Errorf("Error matching in MatchString.\n")
+	}
+	// MatchString searches as SearchableMatchString does, which finds the words near a fuzzy term where SearchableString does not
+	q3 := QueryParser("cat~1 jumped")
+	if !q3.MatchString("The cot jumped") || !q3.Search(SearchableMatchString("The cot jumped")) || q3.Search(SearchableString("The cot jumped")) {
+		t.Errorf("Expected MatchString to match as SearchableMatchString rather than SearchableString.\n")
+	}
+}
+
+func TestMatchStrings(t *testing.T) {
+	a1 := []string{"The cat jumped", "over the mouse"}
+	q1 := QueryParser("cat mouse")
+	if !q1.MatchStrings(a1) {
+		t.Errorf("Error matching in MatchStrings.\n")
+	}
+	q2 := QueryParser("cat fox")
+	if q2.MatchStrings(a1) {
+		t.Errorf("Error matching in MatchStrings.\n")
+	}
+	q3 := QueryParser("cat~1 mouse")
+	a2 := []string{"The cot jumped", "over the mouse"}
+	if !q3.MatchStrings(a2) || !q3.Search(SearchableMatchStringSlice(a2)) || q3.Search(SearchableStringSlice(a2)) {
+		t.Errorf("Expected MatchStrings to match as SearchableMatchStringSlice rather than SearchableStringSlice.\n")
+	}
+}
+
+func TestSearchWithFields(t *testing.T) {
+	everything := SearchableFunc(func(field, phrase string) bool { return true })
+	for _, test := range []struct {
+		Condition string
+		Overrides map[string]string
+		Match     bool
+	}{
+		{"merry tenant:acme", nil, true},
+		{"merry tenant:acme", map[string]string{"tenant": "acme"}, true},
+		{"merry tenant:acme", map[string]string{"tenant": "globex"}, false},
+		{"merry tenant:acme", map[string]string{"tenant": "acme corp"}, true},
+		{"merry tenant:acme", map[string]string{"tenant": "ACME"}, false},
+		{"merry tenant:ac*", map[string]string{"tenant": "acme"}, true},
+		{"merry tenant:>5", map[string]string{"tenant": "5"}, false},
+		{"merry tenant:>5", map[string]string{"tenant": "7"}, true},
+		{"merry tenant:[1 TO 3]", map[string]string{"tenant": "2"}, true},
+		{"merry tenant:>2020-01-01", map[string]string{"tenant": "2021-06-01"}, true},
+		{"merry tenant:/^ac/", map[string]string{"tenant": "globex"}, false},
+		{"merry NOT tenant:acme", map[string]string{"tenant": "globex"}, true},
+		{"merry tenant:(acme,globex)", map[string]string{"tenant": "globex"}, true},
+		{"merry", map[string]string{"tenant": "globex"}, true},
+	} {
+		if QueryParser(test.Condition).SearchWithFields(everything, test.Overrides) != test.Match {
+			t.Errorf("Expected %v for %v with %v\n", test.Match, test.Condition, test.Overrides)
+		}
+	}
+	if QueryParser("whale tenant:acme").SearchWithFields(testFieldMaterial, map[string]string{"tenant": "acme"}) {
+		t.Errorf("Expected the rest of the query to be searched in the record\n")
+	}
+	query, err := QueryParserOptions("merry tenant:acme", Options{CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.SearchWithFields(everything, map[string]string{"tenant": "ACME"}) {
+		t.Errorf("Expected the override to be searched using CaseInsensitive\n")
+	}
+}
+
+func TestEmptyGroupDropped(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Filters   int
+	}{
+		{"boat () whale", 2},
+		{"boat ( ) whale", 2},
+		{"boat (()) whale", 2},
+		{"()", 0},
+		{"boat OR () whale", 2},
+		{"boat NOT () whale", 3},
+	} {
+		query := QueryParser(test.Condition).(*query)
+		if len(query.filters) != test.Filters {
+			t.Errorf("Expected %v filters for %v, got %v\n", test.Filters, test.Condition, len(query.filters))
+		}
+	}
+
+	tso := &testSearchObject{Title: "boat", Body: "whale"}
+	for _, condition := range []string{"boat () whale", "boat whale", "boat OR () whale", "()"} {
+		if !QueryParser(condition).Search(tso) {
+			t.Errorf("Expected %v to match\n", condition)
+		}
+	}
+	if QueryParser("boat NOT () whale").Search(tso) {
+		t.Errorf("Expected NOT () to match nothing\n")
+	}
+
+	// An empty group followed by an OR always matches, as it did before empty groups were dropped
+	for _, test := range []struct {
+		Condition string
+		Record    *testSearchObject
+		Match     bool
+	}{
+		{"frog () OR boat", &testSearchObject{Title: "frog"}, true},
+		{"frog () OR boat", &testSearchObject{Title: "boat"}, false},
+		{"frog (()) OR boat", &testSearchObject{Title: "frog"}, true},
+		{"() OR merry", &testSearchObject{Title: "boat"}, true},
+		{"() OR merry", &testSearchObject{}, true},
+		{"() OR (merry frog)", &testSearchObject{Title: "boat"}, true},
+	} {
+		if QueryParser(test.Condition).Search(test.Record) != test.Match {
+			t.Errorf("Expected %v matching %v to be %v\n", test.Condition, test.Record, test.Match)
+		}
+	}
+}
+
+func TestEmptyQuery(t *testing.T) {
+	tso := &testSearchObject{Title: "boat", Body: "whale"}
+	for _, condition := range []string{"", "   ", "OR", "NOT", "   OR   NOT   ", "NOT OR NOT", "() OR", "OR ( NOT )", "\t\n"} {
+		q, err := QueryParserErr(condition)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v\n", condition, err)
+			continue
+		}
+		if root := q.(*query).root; len(root.Children) != 0 {
+			t.Errorf("Expected no nodes for %q, got %v\n", condition, len(root.Children))
+		}
+		if !q.Search(tso) {
+			t.Errorf("Expected %q to match everything by default\n", condition)
+		}
+		q, err = QueryParserOptions(condition, Options{EmptyMatchesNone: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if q.Search(tso) || q.Score(tso) != 0 {
+			t.Errorf("Expected %q to match nothing with EmptyMatchesNone\n", condition)
+		}
+		if match, err := q.SearchBudget(tso, 10); match || err != nil {
+			t.Errorf("Expected %q to match nothing within a budget, got %v, %v\n", condition, match, err)
+		}
+	}
+
+	// Operators left over after terms are ignored rather than making the query empty
+	q, err := QueryParserOptions("boat OR NOT", Options{EmptyMatchesNone: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !q.Search(tso) {
+		t.Errorf("Expected trailing operators to be ignored\n")
+	}
+}
+
+func TestNotOrGroup(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Record    *testSearchObject
+		Match     bool
+	}{
+		{"NOT (frog OR toad)", &testSearchObject{Title: "frog", Body: "pond"}, false},
+		{"NOT (frog OR toad)", &testSearchObject{Title: "toad", Body: "pond"}, false},
+		{"NOT (frog OR toad)", &testSearchObject{Title: "newt", Body: "pond"}, true},
+		{"NOT (frog OR toad)", &testSearchObject{Title: "frog", Body: "toad"}, false},
+		{"pond NOT (frog OR toad)", &testSearchObject{Title: "newt", Body: "pond"}, true},
+		{"pond NOT (frog OR toad)", &testSearchObject{Title: "frog", Body: "pond"}, false},
+		{"NOT (title:frog OR body:toad)", &testSearchObject{Title: "toad", Body: "frog"}, true},
+		{"NOT (title:frog OR body:toad)", &testSearchObject{Title: "frog", Body: "pond"}, false},
+	} {
+		if QueryParser(test.Condition).Search(test.Record) != test.Match {
+			t.Errorf("Expected %v for %v against %v\n", test.Match, test.Condition, test.Record)
+		}
+	}
+}
+
+func TestOrPrecedence(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Result    string
+		Record    string
+		Match     bool
+	}{
+		{"boat whale OR shark", "boat whale OR shark", "boat shark", true},
+		{"(boat whale) OR shark", "(boat whale) OR shark", "shark", true},
+		{"(boat whale) OR shark", "(boat whale) OR shark", "boat whale", true},
+		{"(boat whale) OR (frog toad) pond", "(boat whale) OR (frog toad) pond", "frog toad pond", true},
+		{"(boat whale) OR (frog toad) pond", "(boat whale) OR (frog toad) pond", "whale toad pond", false},
+		{"(frog OR toad) pond OR lake", "frog OR toad pond OR lake", "toad lake", true},
+		{"(frog OR toad) pond OR lake", "frog OR toad pond OR lake", "pond lake", false},
+		{"(boat whale) shark", "boat whale shark", "boat whale shark", true},
+		{"title:(boat whale) OR shark", "(title:boat title:whale) OR shark", "shark", true},
+		{"((boat whale) OR shark) frog", "(boat whale) OR shark frog", "shark frog", true},
+		{"OR (boat whale) OR shark", "(boat whale) OR shark", "shark", true},
+		{"OR (boat whale) OR shark", "(boat whale) OR shark", "boat", false},
+		{"OR (boat whale) shark", "boat whale shark", "boat whale shark", true},
+	} {
+		q := QueryParser(test.Condition)
+		if result := q.String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		if q.MatchString(test.Record) != test.Match {
+			t.Errorf("Expected %v for %v in %v\n", test.Match, test.Condition, test.Record)
+		}
+	}
+}
+
+func TestFieldValueColons(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Field     string
+		Phrase    string
+	}{
+		{"url:http://example.com", "url", "http://example.com"},
+		{"time:12:30", "time", "12:30"},
+		{"time:'12:30'", "time", "12:30"},
+		{`url:"http://example.com/a b"`, "url", "http://example.com/a b"},
+		{"(time:12:30:15)", "time", "12:30:15"},
+	} {
+		var field, phrase string
+		QueryParser(test.Condition).Search(SearchableFunc(func(f, p string) bool {
+			field, phrase = f, p
+			return true
+		}))
+		if field != test.Field || phrase != test.Phrase {
+			t.Errorf("Expected field %v and phrase %v for %v, got %v and %v\n", test.Field, test.Phrase, test.Condition, field, phrase)
+		}
+	}
+}
+
+func TestQuotedFieldNames(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Field     string
+		Phrase    string
+		Result    string
+	}{
+		{`"release date":2021`, "release date", "2021", `"release date":2021`},
+		{`'a:b':value`, "a:b", "value", `"a:b":value`},
+		{`"release date":"in may"`, "release date", "in may", `"release date":"in may"`},
+		{`"release date":(2020 OR 2021)`, "release date", "2021", `"release date":2020 OR "release date":2021`},
+		{`-"release date":2021`, "release date", "2021", `NOT "release date":2021`},
+		{`?"release date":2021`, "release date", "2021", `?"release date":2021`},
+		{`"say \"hi\"":there`, `say "hi"`, "there", `"say \"hi\"":there`},
+		{`title:"a phrase"`, "title", "a phrase", `title:"a phrase"`},
+		{`"a phrase"`, "", "a phrase", `"a phrase"`},
+		{`"time:12:30"`, "time", "12:30", "time:12:30"},
+	} {
+		var field, phrase string
+		q := QueryParser(test.Condition)
+		q.Search(SearchableFunc(func(f, p string) bool {
+			field, phrase = f, p
+			return false
+		}))
+		if field != test.Field || phrase != test.Phrase {
+			t.Errorf("Expected field %v and phrase %v for %v, got %v and %v\n", test.Field, test.Phrase, test.Condition, field, phrase)
+		}
+		if result := q.String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		if again := QueryParser(q.String()).String(); again != test.Result {
+			t.Errorf("Expected %v to read back unchanged, got %v\n", test.Result, again)
+		}
+	}
+
+	// A quoted field keeps wildcards and comparisons working on its value
+	root := QueryParser(`"release date":20* "page count":>5`).(*query).root
+	if len(root.Children) != 2 || root.Children[0].(*Term).Kind != TermWildcard || root.Children[1].(*Term).Kind != TermNumber {
+		t.Errorf("Expected a wildcard and a number comparison, got %v\n", root.Children)
+	}
+}
+
+func TestFieldScopeAcrossOr(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Fields    []string
+		Match     bool
+	}{
+		{"title:whale OR battle", []string{"title", ""}, true},
+		{"title:(whale OR battle)", []string{"title", "title"}, false},
+		{"battle OR title:whale", []string{"", "title"}, true},
+		{"title:(merry OR battle)", []string{"title", "title"}, true},
+		{"title:(whale OR body:battle)", []string{"title", "body"}, true},
+	} {
+		query := QueryParser(test.Condition).(*query)
+		or, ok := query.root.Children[0].(*OrNode)
+		if !ok || len(query.root.Children) != 1 || len(or.Children) != len(test.Fields) {
+			t.Errorf("Expected a single OR of %v terms for %v\n", len(test.Fields), test.Condition)
+			continue
+		}
+		for i, child := range or.Children {
+			if term := child.(*Term); term.Field != test.Fields[i] {
+				t.Errorf("Expected field %q for %v in %v, got %q\n", test.Fields[i], term.Phrase, test.Condition, term.Field)
+			}
+		}
+		if query.Search(testFieldMaterial) != test.Match {
+			t.Errorf("Expected %v for %v\n", test.Match, test.Condition)
+		}
+	}
+}
+
+func TestFieldGroups(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Result    string
+		Match     bool
+	}{
+		{"title:(merry OR battle)", "title:merry OR title:battle", true},
+		{"title:(whale OR body:beetle)", "title:whale OR body:beetle", true},
+		{"title:((merry time) OR battle)", "(title:merry title:time) OR title:battle", true},
+		{"title:(merry OR (body:(whale OR beetle) bottle))", "title:merry OR (body:whale OR body:beetle title:bottle)", true},
+		{"-title:(merry OR battle)", "NOT (title:merry OR title:battle)", false},
+		{"+title:(merry OR battle)", "title:merry OR title:battle", true},
+		{"beetle -title:(whale OR battle)", "beetle NOT (title:whale OR title:battle)", true},
+		// The inner field wins however the group is written
+		{"title:(body:beetle)", "body:beetle", true},
+		{"title:(body:beetle,whale)", "body:beetle OR title:whale", true},
+		{"title:(body:merry, whale)", "body:merry OR title:whale", false},
+		{`title:("body:beetle",whale)`, "title:body:beetle OR title:whale", false},
+	} {
+		q := QueryParser(test.Condition)
+		if result := q.String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		if result := q.Search(testFieldMaterial); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
+
+func TestNegatedFields(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Result    string
+		Match     bool
+	}{
+		{"title!:merry", "NOT title:merry", false},
+		{"title!=merry", "NOT title:merry", false},
+		{"title!:whale", "NOT title:whale", true},
+		{"title!:merry OR body:beetle", "NOT title:merry OR body:beetle", true},
+		{"NOT title!:whale", "NOT title:whale", true},
+		{`title!:"very merry"`, `NOT title:"very merry"`, false},
+		{"title!=(whale,merry)", "NOT (title:whale OR title:merry)", false},
+		{"beetle title!:(whale OR battle)", "beetle NOT (title:whale OR title:battle)", true},
+		{"body!=(whale OR merry) beetle", "NOT (body:whale OR body:merry) beetle", true},
+		// Only a ! before the separator is special
+		{"title:merry!", "title:merry!", false},
+		{"title:a!=b", "title:a!=b", false},
+		{`title\!:merry`, `"title!":merry`, true},
+		{`merry\!=time`, `merry!\=time`, false},
+		{"!=merry", "!=merry", false},
+	} {
+		q, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		if again := QueryParser(q.String()).String(); again != test.Result {
+			t.Errorf("Expected %v to be unchanged when reparsed, got %v\n", test.Result, again)
+		}
+		if result := q.Search(testFieldMaterial); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
+
+func TestValueListTerms(t *testing.T) {
+	root := QueryParser("tag:(book,,leaflet,)").(*query).root
+	if len(root.Children) != 1 {
+		t.Fatalf("Expected a single node, got %v\n", len(root.Children))
+	}
+	or, ok := root.Children[0].(*OrNode)
+	if !ok {
+		t.Fatalf("Expected an OrNode, got %T\n", root.Children[0])
+	}
+	if len(or.Children) != 2 {
+		t.Fatalf("Expected two terms, got %v\n", len(or.Children))
+	}
+	for i, phrase := range []string{"book", "leaflet"} {
+		term := or.Children[i].(*Term)
+		if term.Field != "tag" || term.Phrase != phrase {
+			t.Errorf("Expected tag:%v, got %v:%v\n", phrase, term.Field, term.Phrase)
+		}
+	}
+
+	// A quoted value is searched for as a phrase
+	term, ok := QueryParser(`tag:"(book,leaflet)"`).(*query).root.Children[0].(*Term)
+	if !ok || term.Field != "tag" || term.Phrase != "(book,leaflet)" {
+		t.Errorf("Expected the phrase tag:(book,leaflet), got %#v\n", term)
+	}
+}
+
+func TestQueryParserErrBrackets(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+	}{
+		{"(merry OR frog", 0},
+		{"  merry (battle (frog)", 8},
+		{"merry) battle", 5},
+		{"merry battle)", 12},
+		{"()) battle", 2},
+	} {
+		_, err := QueryParserErr(test.Condition)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Expected a *ParseError for %v, got %v\n", test.Condition, err)
+			continue
+		}
+		if perr.Pos != test.Pos {
+			t.Errorf("Expected error at %v for %v, got %v\n", test.Pos, test.Condition, perr.Pos)
+		}
+	}
+
+	for _, test := range testCases {
+		// The forgiving parser must still accept any query, including the test table.
+		if QueryParser(test.Condition) == nil {
+			t.Errorf("QueryParser returned nil for %v\n", test.Condition)
+		}
+	}
+}
+
+func TestQueryParserErrMalformed(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+		Forgiving bool
+	}{
+		{"merry title:", 11, true},
+		{`merry title:""`, 11, true},
+		{"NOT title: merry", 9, true},
+		{"merry : battle", 6, true},
+		{":merry", 0, true},
+		{`merry "battle fought`, 6, true},
+		{`merry title:"battle fought`, 12, false},
+		{`(merry "battle)`, 7, false},
+		{"(title: merry", 6, true},
+	} {
+		_, err := QueryParserErr(test.Condition)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Expected a *ParseError for %v, got %v\n", test.Condition, err)
+			continue
+		}
+		if perr.Pos != test.Pos {
+			t.Errorf("Expected error at %v for %v, got %v: %v\n", test.Pos, test.Condition, perr.Pos, perr)
+		}
+		// The forgiving parser drops empty values rather than matching every record
+		if result := QueryParser(test.Condition).Search(testFieldMaterial); result != test.Forgiving {
+			t.Errorf("Expected %v for %v from QueryParser, got %v\n", test.Forgiving, test.Condition, result)
+		}
+	}
+
+	for _, condition := range []string{"it's merry", `"merry time"`, `title:"merry time"`, "title:merry"} {
+		if _, err := QueryParserErr(condition); err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", condition, err)
+		}
+	}
+}
+
+func TestQueryParserErrQuotes(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Pos       int
+	}{
+		{`title:"unterminated`, 6},
+		{`merry "battle fought'`, 6},
+		{`merry 'battle fought"`, 6},
+		{`title:'merry" battle`, 6},
+		{`“merry time’`, 0},
+	} {
+		_, err := QueryParserErr(test.Condition)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Expected a *ParseError for %v, got %v\n", test.Condition, err)
+			continue
+		}
+		if perr.Pos != test.Pos || perr.Message != "quote is not closed" {
+			t.Errorf("Expected an unclosed quote at %v for %v, got %v\n", test.Pos, test.Condition, perr)
+		}
+	}
+
+	for _, test := range []struct {
+		Condition string
+		Record    string
+		Expected  bool
+	}{
+		// Quotes of the same style close each other, so an apostrophe does not end a double quoted phrase
+		{`"it's merry"`, "it's merry", true},
+		{`"it's merry"`, "it is merry", false},
+		{`'say "hi"'`, `we say "hi" here`, true},
+		{`“merry time”`, "a merry time", true},
+		{`„merry time“`, "a merry timer", true},
+		{`“merry time”`, "a merry tim", false},
+	} {
+		query, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := query.MatchString(test.Record); result != test.Expected {
+			t.Errorf("Expected %v for %v against %v, got %v\n", test.Expected, test.Condition, test.Record, result)
+		}
+	}
+}
+
+func TestSignedTerms(t *testing.T) {
+	record := SearchableString("a -wing whale scored -5 and +1, tagged -book")
+	for _, test := range []struct {
+		Condition string
+		Field     bool
+		Record    bool
+	}{
+		{"+merry -frog battle", true, false},
+		{"+merry -battle", false, false},
+		{"-merry", false, true},
+		{`-"merry time"`, false, true},
+		{`-"beetle battle"`, false, true},
+		{"-frog -toad", true, true},
+		{"+whale -shark", false, true},
+		{`"-wing"`, false, true},
+		{"-wing", true, false},
+		{"title:-book", false, true},
+		{"-title:merry", false, true},
+		{"-5", false, true},
+		{"+1", false, true},
+		{"--", false, false},
+	} {
+		q := QueryParser(test.Condition)
+		if result := q.Search(testFieldMaterial); result != test.Field {
+			t.Errorf("Expected %v for %v, got %v\n", test.Field, test.Condition, result)
+		}
+		if result := q.Search(record); result != test.Record {
+			t.Errorf("Expected %v for %v in the record, got %v\n", test.Record, test.Condition, result)
+		}
+	}
+
+	// Empty quotes after a sign are dropped, as "" is on its own, rather than excluding every record
+	for _, condition := range []string{`merry -""`, `merry -''`, `merry ?""`, `merry NOT ""`} {
+		q := QueryParser(condition)
+		if !q.Search(testFieldMaterial) {
+			t.Errorf("Expected %v to match\n", condition)
+		}
+		if text := q.String(); text != "merry" {
+			t.Errorf("Expected %v to be written as merry, got %v\n", condition, text)
+		}
+	}
+}
+
+func TestSignedGroups(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		String    string
+		Both      bool
+		Other     bool
+		All       bool
+	}{
+		{"-(aa bb)", "NOT (aa bb)", false, true, false},
+		{"+(aa bb)", "aa bb", true, false, true},
+		{"?(aa bb)", "?aa ?bb", true, false, true},
+		{"cc -(aa bb)", "cc NOT (aa bb)", false, true, false},
+		{"cc +(aa bb)", "cc aa bb", false, false, true},
+		{"cc ?(aa bb)", "cc ?aa ?bb", false, true, true},
+		{"cc ?(aa OR bb)", "cc ?aa OR ?bb", false, true, true},
+		{"cc OR -(aa bb)", "cc OR NOT (aa bb)", false, true, true},
+		{"cc ?(aa NEAR/1 bb)", "cc ?(aa NEAR/1 bb)", false, true, true},
+		{"cc ?(aa ONEAR/1 bb)", "cc ?(aa ONEAR/1 bb)", false, true, true},
+	} {
+		q, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Errorf("Expected no error for %v, got %v\n", test.Condition, err)
+			continue
+		}
+		if q.String() != test.String {
+			t.Errorf("Expected %v for %v, got %v\n", test.String, test.Condition, q.String())
+		}
+		if _, err := QueryParserErr(q.String()); err != nil {
+			t.Errorf("Expected %v to parse back, got %v\n", q.String(), err)
+		}
+		for _, record := range []struct {
+			Text  string
+			Match bool
+		}{{"aa bb", test.Both}, {"cc", test.Other}, {"cc aa bb", test.All}} {
+			if result := q.Search(SearchableString(record.Text)); result != record.Match {
+				t.Errorf("Expected %v for %v in %q, got %v\n", record.Match, test.Condition, record.Text, result)
+			}
+		}
+	}
+	q := QueryParser("cc ?(aa bb)")
+	if score, fewer := q.Score(SearchableString("cc aa bb")), q.Score(SearchableString("cc aa")); score != 3 || fewer != 2 {
+		t.Errorf("Expected scores of 3 and 2 for the optional group, got %v and %v\n", score, fewer)
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	record := SearchableString(`a tag:special OR (bracketed) offer, say "hi" to it's 5* C:\Users and -wing`)
+	for _, test := range []struct {
+		Condition string
+		Field     string
+		Phrase    string
+		Match     bool
+	}{
+		{`tag\:special`, "", "tag:special", true},
+		{`\OR`, "", "OR", true},
+		{`\NOT`, "", "NOT", false},
+		{`\(bracketed\)`, "", "(bracketed)", true},
+		{`title:\(book,leaflet\)`, "title", "(book,leaflet)", false},
+		{`"say \"hi\""`, "", `say "hi"`, true},
+		{`title:"say \"hi\""`, "title", `say "hi"`, true},
+		{`'it\'s'`, "", "it's", true},
+		{`5\*`, "", "5*", true},
+		{`C\:\\Users`, "", `C:\Users`, true},
+		{`\-wing`, "", "-wing", true},
+		{`price:\>5`, "price", ">5", false},
+		{`merry\ time`, "", "merry time", false},
+	} {
+		q := QueryParser(test.Condition)
+		term, ok := q.(*query).root.Children[0].(*Term)
+		if !ok || len(q.(*query).root.Children) != 1 {
+			t.Errorf("Expected a single term for %v, got %#v\n", test.Condition, q.(*query).root.Children)
+			continue
+		}
+		if term.Field != test.Field || term.Phrase != test.Phrase || term.Kind != TermContains {
+			t.Errorf("Expected %v:%v for %v, got %v:%v of kind %v\n", test.Field, test.Phrase, test.Condition, term.Field, term.Phrase, term.Kind)
+		}
+		if result := q.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+		text := q.String()
+		if again, ok := QueryParser(text).(*query).root.Children[0].(*Term); !ok || again.Field != term.Field || again.Phrase != term.Phrase {
+			t.Errorf("Expected %v to be read back from %v\n", test.Condition, text)
+		}
+	}
+
+	// A backslash at the end of the query has nothing to escape
+	if term := QueryParser(`boat\`).(*query).root.Children[0].(*Term); term.Phrase != `boat\` {
+		t.Errorf("Expected a trailing backslash to be kept, got %v\n", term.Phrase)
+	}
+}
+
+func TestLongTerm(t *testing.T) {
+	long := strings.Repeat("merry", 200000)
+	record := SearchableString("A " + long + " time")
+
+	for _, condition := range []string{long, "title:" + long, `title:"` + long + `"`, "time " + long + " OR frog"} {
+		if !QueryParser(condition).Search(record) {
+			t.Errorf("Expected long term of %v bytes to match\n", len(condition))
+		}
+	}
+
+	// Quotes around a field value are sliced off rather than copying the value
+	plain := testing.AllocsPerRun(5, func() { QueryParser("title:" + long) })
+	quoted := testing.AllocsPerRun(5, func() { QueryParser(`title:"` + long + `"`) })
+	if quoted > plain {
+		t.Errorf("Expected quoted value not to be copied, %v allocations compared to %v\n", quoted, plain)
+	}
+}
+
+func BenchmarkQueryParserLongTerm(b *testing.B) {
+	long := strings.Repeat("a", 1<<20)
+	for _, condition := range []string{long, `title:"` + long + `"`} {
+		b.Run(fmt.Sprintf("%vbytes", len(condition)), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(condition)))
+			for i := 0; i < b.N; i++ {
+				QueryParser(condition)
+			}
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, condition := range []string{
+		"boat whale",
+		`boat (whale OR "killer shark") NOT title:'merry time' tag:(book,leaflet)`,
+		`title:("once upon" OR merry) any(title,body):battle created:>2020-01-01 NOT (frog OR toad)`,
+	} {
+		b.Run(fmt.Sprintf("%vbytes", len(condition)), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				QueryParser(condition)
+			}
+		})
+	}
+}
+
+// testSearchRecords are searched by the queries of BenchmarkSearch and TestSearchAllocations
+var testSearchRecords = []Searchable{
+	SearchableMatchString("Once upon a very merry time, a beetle battle fought in a bottle"),
+	SearchableMatchStringSlice([]string{"Once upon a very merry time", "A beetle battle fought in a bottle"}),
+	SearchableFields(map[string]string{"title": "Once upon a very merry time", "body": "A beetle battle fought in a bottle"}),
+	testFieldMaterial,
+}
+
+var testSearchConditions = []string{
+	"merry battle",
+	`boat (whale OR "killer shark") NOT title:'merry time' tag:(book,leaflet)`,
+	`title:("once upon" OR merry) any(title,body):battle created:>2020-01-01 NOT (frog OR toad)`,
+	"price:[5 TO 20] OR bott* OR battel~1",
+}
+
+// testSearchOptions are the options the queries of BenchmarkSearch and TestSearchAllocations are parsed with, named after those they enable
+var testSearchOptions = []struct {
+	Name string
+	Opts Options
+}{
+	{"default", Options{}},
+	{"CaseInsensitive", Options{CaseInsensitive: true}},
+	{"WholeWord", Options{WholeWord: true}},
+	{"CaseInsensitive+WholeWord", Options{CaseInsensitive: true, WholeWord: true}},
+	{"TreatMissingFieldAsMatch", Options{TreatMissingFieldAsMatch: true}},
+}
+
+func BenchmarkSearch(b *testing.B) {
+	for _, condition := range testSearchConditions {
+		for _, options := range testSearchOptions {
+			q, err := QueryParserOptions(condition, options.Opts)
+			if err != nil {
+				b.Fatalf("Unexpected error: %v\n", err)
+			}
+			b.Run(fmt.Sprintf("%vbytes/%v", len(condition), options.Name), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for _, record := range testSearchRecords {
+						q.Search(record)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSearchAllocations(t *testing.T) {
+	for _, condition := range testSearchConditions {
+		for _, options := range testSearchOptions {
+			q, err := QueryParserOptions(condition, options.Opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			for i, record := range testSearchRecords {
+				if allocs := testing.AllocsPerRun(10, func() { q.Search(record) }); allocs != 0 {
+					t.Errorf("Expected no allocations searching record %v for %v with %v, got %v\n", i, condition, options.Name, allocs)
+				}
+			}
+		}
+	}
+}
+
+// testBufferCollator keeps a buffer between comparisons, as collators such as *collate.Collator do
+type testBufferCollator struct {
+	buf []byte
+}
+
+func (c *testBufferCollator) CompareString(a, b string) int {
+	c.buf = append(append(c.buf[:0], a...), b...)
+	return strings.Compare(string(c.buf[:len(a)]), string(c.buf[len(a):]))
+}
+
+func TestConcurrentSearch(t *testing.T) {
+	saved := QueryParser("whale NEAR/3 boat")
+	q, err := QueryParserOptions("@saved:near (merry OR battle) name:>m NOT shark", Options{
+		NamedQueries: map[string]Query{"near": saved},
+		DefaultField: "body",
+		NewCollator:  func() Collator { return &testBufferCollator{} },
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	records := []Searchable{
+		SearchableFields(map[string]string{"name": "zed", "body": "a whale by the boat in battle"}),
+		SearchableFields(map[string]string{"name": "abe", "body": "a whale by the boat in battle"}),
+		SearchableFields(map[string]string{"name": "zed", "body": "a whale by the boat in battle with a shark"}),
+	}
+	expected := []bool{true, false, false}
+
+	// Run with go test -race to check that searches do not share any state
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for k, record := range records {
+					if q.Search(record) != expected[k] {
+						t.Errorf("Expected %v for record %v\n", expected[k], k)
+					}
+					if match, _ := q.(ContextQuery).SearchContext(context.Background(), record); match != expected[k] {
+						t.Errorf("Expected %v for record %v using SearchContext\n", expected[k], k)
+					}
+					if match, _ := q.SearchBudget(record, 2); match {
+						t.Errorf("Expected record %v to run out of budget\n", k)
+					}
+					q.Score(record)
+					q.Explain(record)
+					q.Highlight("a whale by the boat")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Including a query leaves it as it was
+	if result := saved.String(); result != "whale NEAR/3 boat" {
+		t.Errorf("Expected the saved query to be unchanged, got %v\n", result)
+	}
+}
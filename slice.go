@@ -0,0 +1,112 @@
+package search
+
+/*
+DefaultMaxSearchDepth is the deepest that a Searchable returned by
+SearchableSliceOf is searched through nested slices if
+Options.MaxSearchDepth is not set.
+*/
+const DefaultMaxSearchDepth = 32
+
+/*
+SearchableSliceOf combines several Searchable objects, such as the items of a
+collection, so that a phrase is present if it is present in any of them.
+
+Each term of a query is checked separately, so `tag:book author:smith` matches
+if one item has the tag book and another the author smith.  Use
+Query.SearchAny to require a single item to match the whole query.  Items may
+themselves be returned by SearchableSliceOf.  Nested slices are only searched
+to the depth given by Options.MaxSearchDepth, or DefaultMaxSearchDepth, so a
+deep or cyclic structure can not recurse without limit: items beyond the limit
+never contain the phrase.  Matching options are passed on to items that
+implement MatchSearchable, and terms that use the other optional interfaces,
+such as `price:>10` for Comparable, are asked of each item in turn.  The slice
+has a field if any item has it, so Options.TreatMissingFieldAsMatch treats it
+as missing only if every item implements FieldExister and none has it.
+*/
+func SearchableSliceOf(items []Searchable) Searchable {
+	return &sliceSearchable{items: items}
+}
+
+/*
+SearchableMulti combines the sources of a single record, such as a struct and
+an index of its attachments, so that a phrase is present if it is present in
+any of them.  It is the same as SearchableSliceOf(sources).
+
+Searchable does not say which fields a source has, so a term with a field asks
+every source.  A source without fields, such as SearchableStringSlice, ignores
+the field, so `title:merry` matches if its text has `merry` anywhere.  Terms
+that use the optional interfaces, such as `price:>10` or `_exists_:price`, are
+true if they are true for any source that implements them.
+*/
+func SearchableMulti(sources ...Searchable) Searchable {
+	return SearchableSliceOf(sources)
+}
+
+// sliceSearchable is the Searchable returned by SearchableSliceOf
+type sliceSearchable struct {
+	items    []Searchable
+	maxDepth int
+}
+
+// withMaxDepth returns a copy of the slice limited to maxDepth levels of nesting
+func (ss *sliceSearchable) withMaxDepth(maxDepth int) *sliceSearchable {
+	return &sliceSearchable{items: ss.items, maxDepth: maxDepth}
+}
+
+// containsDepth searches the items of a slice nested depth levels deep, limited to maxDepth levels
+func (ss *sliceSearchable) containsDepth(field, phrase string, match MatchFunc, depth, maxDepth int) bool {
+	if depth > maxDepth {
+		return false
+	}
+	for _, item := range ss.items {
+		if nested, ok := item.(*sliceSearchable); ok {
+			if nested.containsDepth(field, phrase, match, depth+1, maxDepth) {
+				return true
+			}
+		} else if contains(item, field, phrase, match) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardDepth applies f to the items of a slice nested depth levels deep, limited to maxDepth levels
+func (ss *sliceSearchable) forwardDepth(f filter, depth, maxDepth int) bool {
+	if depth > maxDepth {
+		return false
+	}
+	for _, item := range ss.items {
+		if nested, ok := item.(*sliceSearchable); ok {
+			if nested.forwardDepth(f, depth+1, maxDepth) {
+				return true
+			}
+		} else if f(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// limit returns the maximum depth to search the slice to
+func (ss *sliceSearchable) limit() int {
+	if ss.maxDepth > 0 {
+		return ss.maxDepth
+	}
+	return DefaultMaxSearchDepth
+}
+
+func (ss *sliceSearchable) Contains(field, phrase string) (present bool) {
+	return ss.containsDepth(field, phrase, nil, 1, ss.limit())
+}
+
+func (ss *sliceSearchable) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	return ss.containsDepth(field, phrase, match, 1, ss.limit())
+}
+
+func (ss *sliceSearchable) forward(field string, f filter) (match bool) {
+	return ss.forwardDepth(f, 1, ss.limit())
+}
+
+func (ss *sliceSearchable) HasField(field string) (present bool) {
+	return ss.forwardDepth(func(item Searchable) bool { return hasField(item, field) }, 1, ss.limit())
+}
@@ -0,0 +1,194 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+// testNestedSlice returns the item nested inside depth levels of SearchableSliceOf
+func testNestedSlice(item Searchable, depth int) Searchable {
+	for i := 0; i < depth; i++ {
+		item = SearchableSliceOf([]Searchable{SearchableString("filler"), item})
+	}
+	return item
+}
+
+func TestSearchableSliceOf(t *testing.T) {
+	items := SearchableSliceOf([]Searchable{
+		SearchableString("A whale of a boat trip"),
+		testFieldMaterial,
+	})
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"whale", true},
+		{"whale title:merry", true},
+		{"shark", false},
+		{"NOT shark", true},
+		{"NOT whale", false},
+	} {
+		if QueryParser(test.Condition).Search(items) != test.Match {
+			t.Errorf("Expected %v for %v\n", test.Match, test.Condition)
+		}
+	}
+	if !QueryParser("whale").Search(testNestedSlice(items, 3)) {
+		t.Errorf("Expected nested slices to be searched\n")
+	}
+}
+
+func TestSearchableSliceOfComparisons(t *testing.T) {
+	items := []Searchable{
+		SearchableTypedRow(map[string]interface{}{"tag": "book", "price": 5}),
+		SearchableTypedRow(map[string]interface{}{"tag": "leaflet", "price": 15}),
+	}
+	for _, test := range []struct {
+		Condition string
+		Slice     bool
+		Any       bool
+	}{
+		{"price:>10", true, true},
+		{"price:>20", false, false},
+		{"tag:book price:<10", true, true},
+		// Terms matching different items only match the slice as a whole
+		{"tag:book price:>10", true, false},
+		{"price:[10 TO 20]", true, true},
+		{"tag:>c", true, true},
+	} {
+		q := QueryParser(test.Condition)
+		if result := q.Search(SearchableSliceOf(items)); result != test.Slice {
+			t.Errorf("Expected %v for %v against SearchableSliceOf, got %v\n", test.Slice, test.Condition, result)
+		}
+		if result := q.SearchAny(items); result != test.Any {
+			t.Errorf("Expected %v for %v from SearchAny, got %v\n", test.Any, test.Condition, result)
+		}
+	}
+	if QueryParser("price:>10").Search(testNestedSlice(SearchableSliceOf(items), DefaultMaxSearchDepth)) {
+		t.Errorf("Expected comparisons to be limited to DefaultMaxSearchDepth\n")
+	}
+}
+
+func TestSearchAny(t *testing.T) {
+	items := []Searchable{
+		&testSearchObject{Title: "A whale of a time", Body: "leaflet"},
+		&testSearchObject{Title: "Once upon a very merry time", Body: "book"},
+	}
+	for _, test := range []struct {
+		Condition string
+		Slice     bool
+		Any       bool
+	}{
+		{"body:book", true, true},
+		{"title:merry body:book", true, true},
+		// Terms matching different items only match the slice as a whole
+		{"title:whale body:book", true, false},
+		{"time NOT merry", false, true},
+		{"shark", false, false},
+	} {
+		q := QueryParser(test.Condition)
+		if result := q.Search(SearchableSliceOf(items)); result != test.Slice {
+			t.Errorf("Expected %v for %v against SearchableSliceOf, got %v\n", test.Slice, test.Condition, result)
+		}
+		if result := q.SearchAny(items); result != test.Any {
+			t.Errorf("Expected %v for %v from SearchAny, got %v\n", test.Any, test.Condition, result)
+		}
+	}
+	if QueryParser("NOT shark").SearchAny(nil) {
+		t.Errorf("Expected no match for no items\n")
+	}
+}
+
+func TestSearchableMulti(t *testing.T) {
+	record := SearchableMulti(
+		SearchableStruct(&testStructBook{Title: "Once upon a very merry time", Tags: []string{"book"}}),
+		SearchableStringSlice([]string{"attachment: beetle.pdf", "attachment: bottle.png"}),
+	)
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"merry", true},
+		{"beetle", true},
+		{"merry bottle", true},
+		{"title:merry tags:book", true},
+		{"tags:beetle", true},
+		{"title:whale", false},
+		{"merry NOT bottle", false},
+		{"battle OR whale", false},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+	if SearchableMulti().Contains("", "merry") {
+		t.Errorf("Expected no sources to contain nothing\n")
+	}
+}
+
+func TestSearchableMultiComparisons(t *testing.T) {
+	record := SearchableMulti(
+		SearchableTypedRow(map[string]interface{}{"price": 12, "created": time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)}),
+		SearchableStringSlice([]string{"attachment: beetle.pdf"}),
+	)
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"price:>10", true},
+		{"price:<10", false},
+		{"price:[10 TO 20]", true},
+		{"created:>2020-01-01", true},
+		{"created:<2020-01-01", false},
+		{"_exists_:price", true},
+		{"_missing_:price", false},
+		{"_field_:pri*", true},
+		{"price:>10 beetle", true},
+		{"price:>20 OR beetle", true},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+
+	rows := SearchableMulti(SearchableTypedRow(map[string]interface{}{"price": 12}), SearchableTypedRow(map[string]interface{}{"size": 3}))
+	query, err := QueryParserOptions("title:merry", Options{TreatMissingFieldAsMatch: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	if !query.Search(rows) {
+		t.Errorf("Expected a field missing from every source to match\n")
+	}
+	if query.Search(record) {
+		t.Errorf("Expected a source without FieldExister to be asked as usual\n")
+	}
+}
+
+func TestMaxSearchDepth(t *testing.T) {
+	whale := SearchableString("whale")
+	for _, test := range []struct {
+		Depth    int
+		MaxDepth int
+		Match    bool
+	}{
+		{5, 5, true},
+		{6, 5, false},
+		{DefaultMaxSearchDepth, 0, true},
+		{DefaultMaxSearchDepth + 1, 0, false},
+		{100, 200, true},
+	} {
+		query, err := QueryParserOptions("whale", Options{MaxSearchDepth: test.MaxDepth})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if query.Search(testNestedSlice(whale, test.Depth)) != test.Match {
+			t.Errorf("Expected %v for depth %v with limit %v\n", test.Match, test.Depth, test.MaxDepth)
+		}
+	}
+
+	// A slice that contains itself stops at the depth limit
+	cyclic := &sliceSearchable{items: []Searchable{SearchableString("filler")}}
+	cyclic.items = append(cyclic.items, cyclic)
+	if QueryParser("whale").Search(cyclic) {
+		t.Errorf("Expected a cyclic slice not to match\n")
+	}
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"sync"
+)
+
+/*
+FieldStats counts how often a field was searched by a StatsSearchable, split
+by whether the phrase was present.
+*/
+type FieldStats struct {
+	Hits   int
+	Misses int
+}
+
+/*
+Stats accumulates the FieldStats for each field searched through a
+StatsSearchable.  Terms without a field are counted under the field "".
+
+Stats may be read while searches are running in other goroutines.
+*/
+type Stats struct {
+	lock   sync.Mutex
+	fields map[string]FieldStats
+}
+
+// record counts a single search of the field
+func (st *Stats) record(field string, present bool) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	fs := st.fields[field]
+	if present {
+		fs.Hits++
+	} else {
+		fs.Misses++
+	}
+	st.fields[field] = fs
+}
+
+/*
+Field returns the counts for a single field.
+*/
+func (st *Stats) Field(field string) FieldStats {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	return st.fields[field]
+}
+
+/*
+Fields returns a copy of the counts for every field searched so far.
+*/
+func (st *Stats) Fields() map[string]FieldStats {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	fields := make(map[string]FieldStats, len(st.fields))
+	for field, fs := range st.fields {
+		fields[field] = fs
+	}
+	return fields
+}
+
+/*
+StatsSearchable wraps s so that every field searched, and whether the phrase
+was found, is counted in the returned Stats.
+
+The same Searchable and Stats can be used for many searches, including from
+several goroutines at once if s allows it.  Matching options are passed on to
+s if it implements MatchSearchable, and terms that use the other optional
+interfaces, such as `price:>10` for Comparable, are passed on to s and counted
+in the same way.
+*/
+func StatsSearchable(s Searchable) (Searchable, *Stats) {
+	st := &Stats{fields: make(map[string]FieldStats)}
+	return &statsSearchable{s: s, stats: st}, st
+}
+
+// statsSearchable is the Searchable returned by StatsSearchable
+type statsSearchable struct {
+	s     Searchable
+	stats *Stats
+}
+
+func (ss *statsSearchable) Contains(field, phrase string) (present bool) {
+	return ss.ContainsMatch(field, phrase, nil)
+}
+
+func (ss *statsSearchable) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	present = contains(ss.s, field, phrase, match)
+	ss.stats.record(field, present)
+	return present
+}
+
+func (ss *statsSearchable) forward(field string, f filter) (match bool) {
+	match = f(ss.s)
+	ss.stats.record(field, match)
+	return match
+}
+
+func (ss *statsSearchable) HasField(field string) (present bool) {
+	return hasField(ss.s, field)
+}
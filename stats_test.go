@@ -0,0 +1,67 @@
+package search
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestStatsSearchable(t *testing.T) {
+	record, stats := StatsSearchable(testFieldMaterial)
+	for _, condition := range []string{"title:merry body:battle", "title:whale", "merry NOT title:battle", "title:(once OR upon)"} {
+		QueryParser(condition).Search(record)
+	}
+	expected := map[string]FieldStats{
+		"title": {Hits: 2, Misses: 3},
+		"body":  {Hits: 1},
+		"":      {Hits: 1},
+	}
+	if fields := stats.Fields(); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v\n", expected, fields)
+	}
+	if fs := stats.Field("author"); fs.Hits != 0 || fs.Misses != 0 {
+		t.Errorf("Expected no counts for an unsearched field, got %v\n", fs)
+	}
+}
+
+func TestStatsSearchableComparisons(t *testing.T) {
+	record, stats := StatsSearchable(SearchableTypedRow(map[string]interface{}{"price": 12, "title": "A whale of a time"}))
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"price:>10", true},
+		{"price:[20 TO 30]", false},
+		{"price:<10 OR title:whale", true},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+	expected := map[string]FieldStats{
+		"price": {Hits: 1, Misses: 2},
+		"title": {Hits: 1},
+	}
+	if fields := stats.Fields(); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v\n", expected, fields)
+	}
+}
+
+func TestStatsSearchableConcurrent(t *testing.T) {
+	record, stats := StatsSearchable(SearchableString("A whale of a boat trip"))
+	query := QueryParser("whale shark")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				query.Search(record)
+			}
+		}()
+	}
+	wg.Wait()
+	if fs := stats.Field(""); fs.Hits != 1000 || fs.Misses != 1000 {
+		t.Errorf("Expected 1000 hits and misses, got %v\n", fs)
+	}
+}
@@ -0,0 +1,267 @@
+package search
+
+import (
+	"strings"
+)
+
+/*
+PorterStem reduces an English word to its stem using the Porter stemming
+algorithm, so that `running` and `runs` both become `run` and `boats` becomes
+`boat`.  The word is lowered first, and the stem returned in lower case.  Words
+of one or two letters, and words with characters other than the letters a to
+z, are returned lowered but otherwise unchanged.
+
+Stems are not always words themselves, `ponies` becomes `poni`, so the text of
+records must be stemmed in the same way, as SearchableStemmed does.
+*/
+func PorterStem(word string) string {
+	word = strings.ToLower(word)
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	s := &porterStemmer{b: []byte(word), k: len(word) - 1}
+	s.step1ab()
+	if s.k > 0 {
+		s.step1c()
+		s.step2()
+		s.step3()
+		s.step4()
+		s.step5()
+	}
+	return string(s.b[:s.k+1])
+}
+
+/*
+SearchableStemmed makes a string Searchable for queries parsed with stemmer as
+their Options.Stemmer.
+
+The text is searched both as written, for quoted phrases which are not
+stemmed, and with each of its words replaced by its stem, for the stemmed
+terms of the query.  The words are stemmed once, when SearchableStemmed is
+called, rather than on every search.
+*/
+func SearchableStemmed(text string, stemmer func(word string) string) Searchable {
+	return SearchableMatchStringSlice([]string{text, stemWords(text, stemmer)})
+}
+
+// stemWords applies the stemmer to each word of text, joining the stems with spaces.
+// Text without any words is returned unchanged.
+func stemWords(text string, stemmer func(word string) string) string {
+	words := strings.FieldsFunc(text, notWordChar)
+	if len(words) == 0 {
+		return text
+	}
+	for i, word := range words {
+		words[i] = stemmer(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// porterStemmer holds a word as it is stemmed, which runs from b[0] to b[k], with j marking the end of the stem before a suffix
+type porterStemmer struct {
+	b    []byte
+	k, j int
+}
+
+// cons returns true if b[i] is a consonant, with y a consonant at the start of the word or after a vowel
+func (s *porterStemmer) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.cons(i-1)
+	}
+	return true
+}
+
+// m counts the vowel consonant sequences between 0 and j, so tr, ee and tree have none, trouble one and troubles two
+func (s *porterStemmer) m() int {
+	n, i := 0, 0
+	for ; i <= s.j && s.cons(i); i++ {
+	}
+	for i <= s.j {
+		for ; i <= s.j && !s.cons(i); i++ {
+		}
+		if i > s.j {
+			break
+		}
+		for ; i <= s.j && s.cons(i); i++ {
+		}
+		n++
+	}
+	return n
+}
+
+// vowelInStem returns true if there is a vowel between 0 and j
+func (s *porterStemmer) vowelInStem() bool {
+	for i := 0; i <= s.j; i++ {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleC returns true if b[i-1] and b[i] are the same consonant
+func (s *porterStemmer) doubleC(i int) bool {
+	return i >= 1 && s.b[i] == s.b[i-1] && s.cons(i)
+}
+
+// cvc returns true if b[i-2], b[i-1] and b[i] are consonant, vowel, consonant and b[i] is not w, x or y,
+// which marks a short word such as hop whose e has been removed, as in hoping
+func (s *porterStemmer) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends returns true if the word up to k ends with suffix, setting j to the end of the stem before it
+func (s *porterStemmer) ends(suffix string) bool {
+	if len(suffix) > s.k+1 || string(s.b[s.k+1-len(suffix):s.k+1]) != suffix {
+		return false
+	}
+	s.j = s.k - len(suffix)
+	return true
+}
+
+// setTo replaces the word after j with replacement
+func (s *porterStemmer) setTo(replacement string) {
+	s.b = append(s.b[:s.j+1], replacement...)
+	s.k = len(s.b) - 1
+}
+
+// replace replaces the word after j with replacement if the stem has at least one vowel consonant sequence
+func (s *porterStemmer) replace(replacement string) {
+	if s.m() > 0 {
+		s.setTo(replacement)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing, as in caresses, ponies, feed, agreed, matting and meetings
+func (s *porterStemmer) step1ab() {
+	if s.b[s.k] == 's' {
+		switch {
+		case s.ends("sses"):
+			s.k -= 2
+		case s.ends("ies"):
+			s.setTo("i")
+		case s.b[s.k-1] != 's':
+			s.k--
+		}
+	}
+	if s.ends("eed") {
+		if s.m() > 0 {
+			s.k--
+		}
+	} else if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.k = s.j
+		switch {
+		case s.ends("at"):
+			s.setTo("ate")
+		case s.ends("bl"):
+			s.setTo("ble")
+		case s.ends("iz"):
+			s.setTo("ize")
+		case s.doubleC(s.k):
+			switch s.b[s.k] {
+			case 'l', 's', 'z':
+			default:
+				s.k--
+			}
+		default:
+			s.j = s.k
+			if s.m() == 1 && s.cvc(s.k) {
+				s.setTo("e")
+			}
+		}
+	}
+	s.b = s.b[:s.k+1]
+}
+
+// step1c turns a final y into i when there is another vowel in the stem, as in happy
+func (s *porterStemmer) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[s.k] = 'i'
+	}
+}
+
+// porterStep2 and porterStep3 are the suffixes replaced by step2 and step3, and porterStep4 those removed by step4,
+// with the longer of two suffixes that end the same way first
+var (
+	porterStep2 = [][2]string{
+		{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"}, {"izer", "ize"},
+		{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"},
+		{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"},
+		{"fulness", "ful"}, {"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+		{"logi", "log"},
+	}
+	porterStep3 = [][2]string{
+		{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"}, {"ical", "ic"},
+		{"ful", ""}, {"ness", ""},
+	}
+	porterStep4 = []string{
+		"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+		"ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+	}
+)
+
+// step2 replaces double suffixes with single ones, so relational becomes relate
+func (s *porterStemmer) step2() {
+	s.replaceSuffix(porterStep2)
+}
+
+// step3 handles -ic-, -ful, -ness and similar, so hopeful becomes hope
+func (s *porterStemmer) step3() {
+	s.replaceSuffix(porterStep3)
+}
+
+// replaceSuffix replaces the first suffix of the table that the word ends with, when the stem before it is long enough
+func (s *porterStemmer) replaceSuffix(suffixes [][2]string) {
+	for _, suffix := range suffixes {
+		if s.ends(suffix[0]) {
+			s.replace(suffix[1])
+			return
+		}
+	}
+}
+
+// step4 removes -ant, -ence and similar from long stems, so adjustment becomes adjust
+func (s *porterStemmer) step4() {
+	for _, suffix := range porterStep4 {
+		if !s.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (s.j < 0 || s.b[s.j] != 's' && s.b[s.j] != 't') {
+			return
+		}
+		if s.m() > 1 {
+			s.k = s.j
+			s.b = s.b[:s.k+1]
+		}
+		return
+	}
+}
+
+// step5 removes a final -e and turns a final -ll into -l on long stems, so probate becomes probat and controll becomes control
+func (s *porterStemmer) step5() {
+	s.j = s.k
+	if s.b[s.k] == 'e' {
+		if m := s.m(); m > 1 || m == 1 && !s.cvc(s.k-1) {
+			s.k--
+		}
+	}
+	if s.b[s.k] == 'l' && s.doubleC(s.k) && s.m() > 1 {
+		s.k--
+	}
+	s.b = s.b[:s.k+1]
+}
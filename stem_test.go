@@ -0,0 +1,64 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestPorterStem(t *testing.T) {
+	for _, test := range []struct {
+		Word string
+		Stem string
+	}{
+		{"caresses", "caress"}, {"ponies", "poni"}, {"ties", "ti"}, {"caress", "caress"}, {"cats", "cat"},
+		{"feed", "feed"}, {"agreed", "agre"}, {"plastered", "plaster"}, {"motoring", "motor"}, {"sing", "sing"},
+		{"conflated", "conflat"}, {"troubled", "troubl"}, {"sized", "size"}, {"hopping", "hop"}, {"tanned", "tan"},
+		{"falling", "fall"}, {"hissing", "hiss"}, {"fizzed", "fizz"}, {"failing", "fail"}, {"filing", "file"},
+		{"happy", "happi"}, {"sky", "sky"}, {"relational", "relat"}, {"conditional", "condit"}, {"rational", "ration"},
+		{"digitizer", "digit"}, {"vietnamization", "vietnam"}, {"predication", "predic"}, {"operator", "oper"},
+		{"feudalism", "feudal"}, {"decisiveness", "decis"}, {"hopefulness", "hope"}, {"callousness", "callous"},
+		{"formaliti", "formal"}, {"sensitiviti", "sensit"}, {"sensibiliti", "sensibl"}, {"triplicate", "triplic"},
+		{"formative", "form"}, {"formalize", "formal"}, {"electriciti", "electr"}, {"electrical", "electr"},
+		{"hopeful", "hope"}, {"goodness", "good"}, {"revival", "reviv"}, {"allowance", "allow"}, {"inference", "infer"},
+		{"airliner", "airlin"}, {"gyroscopic", "gyroscop"}, {"adjustable", "adjust"}, {"defensible", "defens"},
+		{"irritant", "irrit"}, {"replacement", "replac"}, {"adjustment", "adjust"}, {"dependent", "depend"},
+		{"adoption", "adopt"}, {"homologou", "homolog"}, {"communism", "commun"}, {"activate", "activ"},
+		{"angulariti", "angular"}, {"homologous", "homolog"}, {"effective", "effect"}, {"bowdlerize", "bowdler"},
+		{"probate", "probat"}, {"rate", "rate"}, {"cease", "ceas"}, {"controlling", "control"}, {"roll", "roll"},
+		{"generalizations", "gener"}, {"running", "run"}, {"runs", "run"}, {"boats", "boat"},
+		{"Boats", "boat"}, {"is", "is"}, {"café", "café"}, {"mp3s", "mp3s"}, {"", ""},
+	} {
+		if stem := PorterStem(test.Word); stem != test.Stem {
+			t.Errorf("Expected %v for %v, got %v\n", test.Stem, test.Word, stem)
+		}
+	}
+}
+
+func TestStemmer(t *testing.T) {
+	record := SearchableStemmed("He runs to the boats, running shoes on", PorterStem)
+	for _, test := range []struct {
+		Condition string
+		Result    string
+		Match     bool
+	}{
+		{"running", "run", true},
+		{"run boat", "run boat", true},
+		{"Boats", "boat", true},
+		{`"running shoes"`, `"running shoes"`, true},
+		{`"run shoe"`, `"run shoe"`, true},
+		{`"runs shoes"`, `"runs shoes"`, false},
+		{"title:shoes", "title:shoe", true},
+		{"-boats", "NOT boat", false},
+		{"shoes~1", "shoes~1", true},
+	} {
+		q, err := QueryParserOptions(test.Condition, Options{Stemmer: PorterStem})
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		if result := q.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package search
+
+/*
+DefaultEnglishStopWords is a small set of common English words, such as `the`
+and `in`, for use as Options.StopWords.  Copy it before adding or removing
+words, as it is shared by every query that uses it.
+*/
+var DefaultEnglishStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "has": true,
+	"have": true, "if": true, "in": true, "into": true, "is": true, "it": true,
+	"its": true, "no": true, "not": true, "of": true, "on": true, "or": true,
+	"such": true, "that": true, "the": true, "their": true, "then": true,
+	"there": true, "these": true, "they": true, "this": true, "to": true,
+	"was": true, "were": true, "will": true, "with": true,
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestStopWords(t *testing.T) {
+	record := SearchableString("a cat wearing a hat")
+	for _, test := range []struct {
+		Condition string
+		Result    string
+		Match     bool
+	}{
+		{"the cat in the hat", "cat hat", true},
+		{"The Cat In The Hat", "Cat Hat", false},
+		{`"the cat" hat`, `"the cat" hat`, false},
+		{"title:the cat", "title:the cat", false},
+		{"+the cat", "the cat", false},
+		{"cat NOT the", "cat", true},
+		{"cat -the", "cat", true},
+		{"the OR cat", "cat", true},
+		{"cat OR the hat", "cat hat", true},
+		{"(the OR an) cat", "cat", true},
+		{"cat NEAR/2 the", "cat NEAR/2 the", false},
+		{"the", "", true},
+		{"the in", "", true},
+	} {
+		q, err := QueryParserOptions(test.Condition, Options{StopWords: DefaultEnglishStopWords})
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.String(); result != test.Result {
+			t.Errorf("Expected %v for %v, got %v\n", test.Result, test.Condition, result)
+		}
+		if result := q.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
+
+func TestStopWordsMatchNone(t *testing.T) {
+	record := SearchableString("the cat in the hat")
+	opts := Options{StopWords: DefaultEnglishStopWords, StopWordsMatchNone: true}
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"the", false},
+		{"the in (an OR a)", false},
+		{"the cat", true},
+		{`"the"`, true},
+		{"", true},
+	} {
+		q, err := QueryParserOptions(test.Condition, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := q.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package search
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// maxStructDepth stops SearchableStruct walking structs that refer to themselves
+const maxStructDepth = 10
+
+/*
+SearchableStruct makes a struct Searchable using reflection, saving writing a
+Contains method for each type of record.
+
+Each exported field is searched under its lowercased name, so `title:merry`
+searches the Title field.  A `search` tag renames a field, with a list such as
+`search:"title,heading"` searching it under each name, and fields given the
+same name are combined so that either may match.  Fields tagged `search:"-"`
+are left out.
+
+Pointers are followed, and the fields of nested structs are named by their
+path, so `author.name:smith` searches the Name field of the Author field.
+Embedded structs add their fields as if they were declared in the outer
+struct.  Each element of a slice or array is searched under the name of its
+field.  Other values, including those implementing fmt.Stringer such as
+time.Time, are formatted with fmt.Sprint and searched as text.  Terms
+without a field search every value.
+
+The struct is read once, when SearchableStruct is called, so build a new
+Searchable if the struct changes.  The Searchable also implements
+MatchSearchable, FieldNamer, FieldExister and FieldValuer.
+*/
+func SearchableStruct(v interface{}) Searchable {
+	s := &structSearchable{fields: make(map[string][]string)}
+	s.walk(reflect.ValueOf(v), nil, 0)
+	return s
+}
+
+// structSearchable is the Searchable returned by SearchableStruct, holding the text of each field in the order found
+type structSearchable struct {
+	names  []string
+	fields map[string][]string
+}
+
+// add records the text of a value under each of its names
+func (s *structSearchable) add(names []string, text string) {
+	for _, name := range names {
+		if _, found := s.fields[name]; !found {
+			s.names = append(s.names, name)
+		}
+		s.fields[name] = append(s.fields[name], text)
+	}
+}
+
+// walk adds the text of v under the given names, descending into structs, slices and pointers
+func (s *structSearchable) walk(v reflect.Value, names []string, depth int) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if depth > maxStructDepth {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+	case reflect.String:
+		s.add(names, v.String())
+	case reflect.Struct:
+		if v.CanInterface() {
+			if stringer, ok := v.Interface().(fmt.Stringer); ok {
+				s.add(names, stringer.String())
+				return
+			}
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if fieldNames, ok := structFieldNames(t.Field(i), names); ok {
+				s.walk(v.Field(i), fieldNames, depth+1)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			s.walk(v.Index(i), names, depth+1)
+		}
+	default:
+		s.add(names, fmt.Sprint(v))
+	}
+}
+
+// structFieldNames returns the names a struct field is searched under, or false if it is not searched
+func structFieldNames(field reflect.StructField, parents []string) (names []string, ok bool) {
+	tag := field.Tag.Get("search")
+	embedded := field.Type
+	if embedded.Kind() == reflect.Ptr {
+		embedded = embedded.Elem()
+	}
+	// The exported fields of an embedded struct are searched even if its type is not exported
+	isEmbedded := field.Anonymous && embedded.Kind() == reflect.Struct
+	if (field.PkgPath != "" && !isEmbedded) || tag == "-" {
+		return nil, false
+	}
+	if tag == "" && isEmbedded {
+		return parents, true
+	}
+	own := []string{strings.ToLower(field.Name)}
+	if tag != "" {
+		own = strings.Split(tag, ",")
+	}
+	if len(parents) == 0 {
+		return own, true
+	}
+	for _, parent := range parents {
+		for _, name := range own {
+			names = append(names, parent+"."+name)
+		}
+	}
+	return names, true
+}
+
+func (s *structSearchable) Contains(field, phrase string) (present bool) {
+	return s.ContainsMatch(field, phrase, strings.Contains)
+}
+
+func (s *structSearchable) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	for _, name := range s.names {
+		if field != "" && field != name {
+			continue
+		}
+		for _, text := range s.fields[name] {
+			if match(text, phrase) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *structSearchable) FieldNames() (names []string) {
+	return s.names
+}
+
+func (s *structSearchable) HasField(field string) (present bool) {
+	_, present = s.fields[field]
+	return present
+}
+
+func (s *structSearchable) FieldValue(field string) (value string, present bool) {
+	texts, present := s.fields[field]
+	return strings.Join(texts, " "), present
+}
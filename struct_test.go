@@ -0,0 +1,85 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+type testStructAuthor struct {
+	Name string
+}
+
+type testStructMeta struct {
+	Publisher string
+}
+
+type testStructBook struct {
+	testStructMeta
+	Title    string
+	Subtitle string `search:"title"`
+	Summary  string `search:"blurb,body"`
+	Tags     []string
+	Pages    int
+	Price    float64
+	Author   testStructAuthor
+	Editor   *testStructAuthor
+	Sequel   *testStructBook
+	Created  time.Time
+	Secret   string `search:"-"`
+	internal string
+}
+
+func TestSearchableStruct(t *testing.T) {
+	book := &testStructBook{
+		testStructMeta: testStructMeta{Publisher: "Owl Press"},
+		Title:          "Once upon a very merry time",
+		Subtitle:       "A tale of beetles",
+		Summary:        "A beetle battle fought in a bottle",
+		Tags:           []string{"book", "leaflet"},
+		Pages:          320,
+		Price:          9.5,
+		Author:         testStructAuthor{Name: "Smith"},
+		Created:        time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+		Secret:         "whale",
+		internal:       "shark",
+	}
+	book.Sequel = book
+	record := SearchableStruct(book)
+	for _, test := range []struct {
+		Condition string
+		Match     bool
+	}{
+		{"title:merry", true},
+		{"merry", true},
+		{"title:tale", true},
+		{"subtitle:tale", false},
+		{"blurb:beetle body:bottle", true},
+		{"summary:beetle", false},
+		{"tags:leaflet", true},
+		{"tag:(pamphlet,book)", false},
+		{"tags:(pamphlet,book)", true},
+		{"pages:320", true},
+		{"price:9.5", true},
+		{"author.name:Smith", true},
+		{"name:Smith", false},
+		{"editor.name:Smith", false},
+		{"publisher:Owl", true},
+		{"created:2020-03-01", true},
+		{"sequel.title:merry", true},
+		{"whale", false},
+		{"secret:whale", false},
+		{"shark", false},
+		{"_field_:author.*", true},
+	} {
+		if result := QueryParser(test.Condition).Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v, got %v\n", test.Match, test.Condition, result)
+		}
+	}
+
+	if QueryParser("merry").Search(SearchableStruct(nil)) {
+		t.Errorf("Expected nothing to match a nil struct\n")
+	}
+	if !QueryParser("name:Smith").Search(SearchableStruct(testStructAuthor{Name: "Smith"})) {
+		t.Errorf("Expected a struct value to be searchable\n")
+	}
+}
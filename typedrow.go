@@ -0,0 +1,150 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+SearchableTypedRow makes a row of typed columns, such as one read from a
+database, Searchable.
+
+Every value is searched as text, formatted with fmt.Sprint, or as RFC3339 for
+a time.Time.  The type of the value also enables comparisons: numbers of any
+Go numeric type implement Comparable for queries such as `price:>10`,
+time.Time values implement DateComparable for `created:>2020-01-01`, and bool
+values only match the phrases true or false, as in `active:true`.  Terms
+without a field search every column.  The Searchable also implements
+FieldNamer, FieldExister and FieldValuer.
+*/
+func SearchableTypedRow(row map[string]interface{}) Searchable {
+	return typedRow(row)
+}
+
+// typedRow is the Searchable returned by SearchableTypedRow
+type typedRow map[string]interface{}
+
+// typedText formats a column value as the text that is searched
+func typedText(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// typedNumber returns the value of a column holding any numeric type
+func typedNumber(value interface{}) (n float64, ok bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// containsValue returns true if the phrase is present in a single column value
+func containsValue(value interface{}, phrase string, match MatchFunc) bool {
+	if b, ok := value.(bool); ok {
+		parsed, err := strconv.ParseBool(phrase)
+		return err == nil && parsed == b
+	}
+	return match(typedText(value), phrase)
+}
+
+func (r typedRow) Contains(field, phrase string) (present bool) {
+	return r.ContainsMatch(field, phrase, strings.Contains)
+}
+
+func (r typedRow) ContainsMatch(field, phrase string, match MatchFunc) (present bool) {
+	if field != "" {
+		value, found := r[field]
+		return found && containsValue(value, phrase, match)
+	}
+	for _, value := range r {
+		if containsValue(value, phrase, match) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r typedRow) Compare(field string, op Op, value float64) (match bool) {
+	n, ok := typedNumber(r[field])
+	if !ok {
+		return false
+	}
+	switch op {
+	case OpLess:
+		return n < value
+	case OpLessEqual:
+		return n <= value
+	case OpGreater:
+		return n > value
+	case OpGreaterEqual:
+		return n >= value
+	}
+	return false
+}
+
+func (r typedRow) CompareDate(field string, op Op, t time.Time) (match bool) {
+	date, ok := r[field].(time.Time)
+	if !ok {
+		return false
+	}
+	switch op {
+	case OpLess:
+		return date.Before(t)
+	case OpLessEqual:
+		return !date.After(t)
+	case OpGreater:
+		return date.After(t)
+	case OpGreaterEqual:
+		return !date.Before(t)
+	}
+	return false
+}
+
+func (r typedRow) FieldNames() (names []string) {
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r typedRow) HasField(field string) (present bool) {
+	_, present = r[field]
+	return present
+}
+
+func (r typedRow) FieldValue(field string) (value string, present bool) {
+	v, present := r[field]
+	if !present {
+		return "", false
+	}
+	return typedText(v), true
+}
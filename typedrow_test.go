@@ -0,0 +1,53 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchableTypedRow(t *testing.T) {
+	rows := []Searchable{
+		SearchableTypedRow(map[string]interface{}{
+			"name":    "Garden hose",
+			"price":   12.5,
+			"stock":   3,
+			"active":  true,
+			"created": time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		}),
+		SearchableTypedRow(map[string]interface{}{
+			"name":    "Watering can",
+			"price":   float32(7.25),
+			"stock":   uint8(0),
+			"active":  false,
+			"created": time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+		}),
+	}
+	for _, test := range []struct {
+		Condition string
+		Matches   []bool
+	}{
+		{"price:>10", []bool{true, false}},
+		{"price:<=7.25", []bool{false, true}},
+		{"stock:>0", []bool{true, false}},
+		{"active:true", []bool{true, false}},
+		{"active:false", []bool{false, true}},
+		{"active:tr", []bool{false, false}},
+		{"created:>2020-01-01", []bool{true, false}},
+		{"name:hose", []bool{true, false}},
+		{"price:12.5", []bool{true, false}},
+		{"2019", []bool{false, true}},
+		{"name:>10", []bool{false, false}},
+		{"_field_:stock", []bool{true, true}},
+	} {
+		query, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v\n", test.Condition, err)
+			continue
+		}
+		for i, row := range rows {
+			if query.Search(row) != test.Matches[i] {
+				t.Errorf("Expected %v for %v against row %v\n", test.Matches[i], test.Condition, i)
+			}
+		}
+	}
+}
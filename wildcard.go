@@ -0,0 +1,54 @@
+package search
+
+// wildcard ends an unquoted term that matches the start of a word, as in boa*
+const wildcard = '*'
+
+/*
+Prefixable is an optional interface for Searchable objects that can find words
+starting with a prefix, used for terms such as `boa*` that match `boat`,
+`boats` and `boardwalk`.
+
+Only a `*` at the end of an unquoted term, or straight after a quoted phrase
+as in `"boa con"*`, is a wildcard.  For now a `*` elsewhere in a word, as in
+`b*t`, or in quotes, as in `"boa*"`, is searched for as written.
+
+Searchable objects that do not implement Prefixable but do implement
+MatchSearchable, as SearchableFields does, are asked using
+ContainsWordPrefix.  Others, such as SearchableString, are asked whether they
+contain the term as written, including the `*`.
+*/
+type Prefixable interface {
+	Searchable
+	/*
+		HasPrefix returns true if a word in the named field starts with the prefix.
+		A field of "" means any field.
+	*/
+	HasPrefix(field, prefix string) (present bool)
+}
+
+// isWildcard returns true if the unquoted value ends in a * that follows something to search for
+func isWildcard(value string) bool {
+	return len(value) > 1 && value[len(value)-1] == wildcard
+}
+
+// wildcardFunc returns the MatchFunc that finds words starting with a prefix, ignoring case if the options ask for it
+func (opts Options) wildcardFunc() MatchFunc {
+	if opts.CaseInsensitive {
+		return containsWordPrefixLower
+	}
+	return ContainsWordPrefix
+}
+
+// mustHavePrefix returns true if the Searchable has a word starting with the prefix, using literal for those that can
+// not be asked with a MatchFunc
+func mustHavePrefix(field, prefix string, wordPrefix MatchFunc, literal filter) filter {
+	return func(s Searchable) bool {
+		switch s := s.(type) {
+		case Prefixable:
+			return s.HasPrefix(field, prefix)
+		case MatchSearchable:
+			return s.ContainsMatch(field, prefix, wordPrefix)
+		}
+		return literal(s)
+	}
+}
@@ -0,0 +1,124 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+// testPrefixableRecord finds words by their prefix through HasPrefix
+type testPrefixableRecord struct {
+	Title string
+}
+
+func (r testPrefixableRecord) Contains(field, phrase string) (present bool) {
+	return strings.Contains(r.Title, phrase)
+}
+
+func (r testPrefixableRecord) HasPrefix(field, prefix string) (present bool) {
+	if field != "" && field != "title" {
+		return false
+	}
+	for _, word := range strings.Fields(r.Title) {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWildcard(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Title     string
+		Prefix    bool
+		String    bool
+		Literal   bool
+	}{
+		{"boa*", "a boat", true, true, false},
+		{"boa*", "two boats", true, true, false},
+		{"boa*", "the boardwalk", true, true, false},
+		{"boa*", "a rowboat", false, false, false},
+		{"boa*", "a (boat)", false, true, false},
+		{"boa*", "boa* is literal", true, true, true},
+		{"title:boa*", "a boat", true, true, false},
+		{"body:boa*", "a boat", false, true, false},
+		{"NOT boa*", "a boat", false, false, true},
+		{`"boa*"`, "a boat", false, false, false},
+		{`"boa*"`, "boa* is literal", true, true, true},
+		{`title:"boa*"`, "a boat", false, false, false},
+		{"b*t", "a boat", false, false, false},
+		{"b*t", "b*t is literal", true, true, true},
+		{"whale OR boa*", "a boat", true, true, false},
+	} {
+		query, err := QueryParserErr(test.Condition)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := query.Search(testPrefixableRecord{test.Title}); result != test.Prefix {
+			t.Errorf("Expected %v for %v in %v using HasPrefix, got %v\n", test.Prefix, test.Condition, test.Title, result)
+		}
+		if result := query.Search(SearchableMatchString(test.Title)); result != test.String {
+			t.Errorf("Expected %v for %v in %v using ContainsWordPrefix, got %v\n", test.String, test.Condition, test.Title, result)
+		}
+		if result := query.Search(SearchableFunc(func(field, phrase string) bool { return strings.Contains(test.Title, phrase) })); result != test.Literal {
+			t.Errorf("Expected %v for %v in %v, got %v\n", test.Literal, test.Condition, test.Title, result)
+		}
+	}
+}
+
+func TestWildcardBuiltIn(t *testing.T) {
+	fields := SearchableFields(map[string]string{"title": "Merry time", "body": "A beetle battle"})
+	for _, test := range []struct {
+		Condition string
+		Options   Options
+		Match     bool
+	}{
+		{"merr*", Options{}, false},
+		{"Merr*", Options{}, true},
+		{"merr*", Options{CaseInsensitive: true}, true},
+		{"title:bat*", Options{}, false},
+		{"body:bat*", Options{}, true},
+		{"body:eetle*", Options{}, false},
+		{"NOT bee*", Options{}, false},
+	} {
+		query, err := QueryParserOptions(test.Condition, test.Options)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v\n", test.Condition, err)
+		}
+		if result := query.Search(fields); result != test.Match {
+			t.Errorf("Expected %v for %v with %+v, got %v\n", test.Match, test.Condition, test.Options, result)
+		}
+	}
+	if !QueryParser("merry*").Search(SearchableFields(map[string]string{"title": "merry time"})) {
+		t.Errorf("Expected merry* to find merry in SearchableFields\n")
+	}
+	if !QueryParser("merr*").Search(SearchableMatchString("a merry time")) {
+		t.Errorf("Expected merr* to find merry in SearchableMatchString\n")
+	}
+}
+
+func TestWildcardTerm(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Kind      TermKind
+		Phrase    string
+	}{
+		{"boa*", TermWildcard, "boa"},
+		{"title:boa*", TermWildcard, "boa"},
+		{`"boa*"`, TermContains, "boa*"},
+		{`title:"boa*"`, TermContains, "boa*"},
+		{"b*t", TermContains, "b*t"},
+	} {
+		term, ok := QueryParser(test.Condition).(*query).root.Children[0].(*Term)
+		if !ok {
+			t.Errorf("Expected a single term for %v\n", test.Condition)
+			continue
+		}
+		if term.Kind != test.Kind || term.Phrase != test.Phrase {
+			t.Errorf("Expected %v %q for %v, got %v %q\n", test.Kind, test.Phrase, test.Condition, term.Kind, term.Phrase)
+		}
+		if text := QueryParser(test.Condition).String(); term.Kind == TermWildcard && text != test.Condition {
+			t.Errorf("Expected %v to format as written, got %v\n", test.Condition, text)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+ContainsWholeWords is a MatchFunc that only reports phrase as found in text
+when it matches whole words, so `cat` is found in "the cat sat" and "the cat."
+but not in "category".
+
+Words are runs of letters and digits, with everything else, such as spaces and
+punctuation, separating them.  A phrase of several words, such as
+"merry time", must match the same words next to each other in text, whatever
+separates them.  A phrase without any letters or digits is matched using
+strings.Contains.
+*/
+func ContainsWholeWords(text, phrase string) (found bool) {
+	if start, _ := nextWord(phrase, 0); start < 0 {
+		return strings.Contains(text, phrase)
+	}
+	for start, end := nextWord(text, 0); start >= 0; start, end = nextWord(text, end) {
+		if wordsFrom(text, start, phrase, false) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ContainsWordPrefix is a MatchFunc that only reports prefix as found in text
+when it starts a word, so `boa` is found in "a boat" and "the boardwalk" but
+not in "a rowboat".  Words are separated as they are for ContainsWholeWords.
+*/
+func ContainsWordPrefix(text, prefix string) (found bool) {
+	for offset := 0; offset <= len(text); {
+		i := strings.Index(text[offset:], prefix)
+		if i < 0 {
+			return false
+		}
+		if wordBoundary(text, offset+i) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[offset+i:])
+		offset += i + size
+	}
+	return false
+}
+
+// containsWordPrefixLower is ContainsWordPrefix ignoring case, lowering each character as it is compared as ContainsLower does
+func containsWordPrefixLower(text, prefix string) (found bool) {
+	for pos := range text {
+		if wordBoundary(text, pos) && lowerPrefix(text[pos:], prefix) {
+			return true
+		}
+	}
+	return prefix == ""
+}
+
+// containsWholeWordsLower is ContainsWholeWords ignoring case, as ContainsLower does
+func containsWholeWordsLower(text, phrase string) (found bool) {
+	if start, _ := nextWord(phrase, 0); start < 0 {
+		return ContainsLower(text, phrase)
+	}
+	for start, end := nextWord(text, 0); start >= 0; start, end = nextWord(text, end) {
+		if wordsFrom(text, start, phrase, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWord returns the start and end of the first word in text at or after pos, start is -1 if there are none
+func nextWord(text string, pos int) (start, end int) {
+	start = -1
+	for i, char := range text[pos:] {
+		switch {
+		case !notWordChar(char) && start < 0:
+			start = pos + i
+		case notWordChar(char) && start >= 0:
+			return start, pos + i
+		}
+	}
+	if start < 0 {
+		return -1, len(text)
+	}
+	return start, len(text)
+}
+
+// wordsFrom returns true if the words of phrase are the words of text starting at pos, ignoring case if lower is set
+func wordsFrom(text string, pos int, phrase string, lower bool) bool {
+	for phraseStart, phraseEnd := nextWord(phrase, 0); phraseStart >= 0; phraseStart, phraseEnd = nextWord(phrase, phraseEnd) {
+		start, end := nextWord(text, pos)
+		if start < 0 {
+			return false
+		}
+		word, want := text[start:end], phrase[phraseStart:phraseEnd]
+		if word != want && (!lower || !lowerEqual(word, want)) {
+			return false
+		}
+		pos = end
+	}
+	return true
+}
+
+// notWordChar returns true for characters that separate words
+func notWordChar(char rune) bool {
+	return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+}
+
+// wordBoundary returns true if pos in text is not part way through a word
+func wordBoundary(text string, pos int) bool {
+	if pos <= 0 || pos >= len(text) {
+		return true
+	}
+	before, _ := utf8.DecodeLastRuneInString(text[:pos])
+	after, _ := utf8.DecodeRuneInString(text[pos:])
+	return notWordChar(before) || notWordChar(after)
+}
+
+// collapseWhitespace replaces each run of whitespace in text with a single space.
+// Text whose only whitespace is single spaces is returned without being copied.
+func collapseWhitespace(text string) string {
+	collapsed, space := true, false
+	for _, char := range text {
+		isSpace := unicode.IsSpace(char)
+		if isSpace && (space || char != ' ') {
+			collapsed = false
+			break
+		}
+		space = isSpace
+	}
+	if collapsed {
+		return text
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	space = false
+	for _, char := range text {
+		if unicode.IsSpace(char) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		b.WriteRune(char)
+		space = false
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestContainsWholeWords(t *testing.T) {
+	for _, test := range []struct {
+		Text   string
+		Phrase string
+		Found  bool
+	}{
+		{"the cat sat", "cat", true},
+		{"category", "cat", false},
+		{"the cat.", "cat", true},
+		{"(cat)", "cat", true},
+		{"the cat sat", "cat.", true},
+		{"the cat  sat on the mat", "cat sat", true},
+		{"the cat, sat", "cat sat", true},
+		{"the cat sits", "cat sat", false},
+		{"the cat", "cat sat", false},
+		{"scat sat", "cat sat", false},
+		{"café au lait", "café", true},
+		{"cafés", "café", false},
+		{"room 101", "101", true},
+		{"room 1010", "101", false},
+		{"so :-) there", ":-)", true},
+		{"", "cat", false},
+	} {
+		if found := ContainsWholeWords(test.Text, test.Phrase); found != test.Found {
+			t.Errorf("Expected %v for %v in %v, got %v\n", test.Found, test.Phrase, test.Text, found)
+		}
+	}
+}
+
+func TestContainsWordPrefix(t *testing.T) {
+	for _, test := range []struct {
+		Text   string
+		Prefix string
+		Found  bool
+		Lower  bool
+	}{
+		{"a boat", "boa", true, true},
+		{"a rowboat then a boardwalk", "boa", true, true},
+		{"a rowboat", "boa", false, false},
+		{"(boat)", "boa", true, true},
+		{"A Boat", "boa", false, true},
+		{"café au lait", "caf", true, true},
+		{"", "boa", false, false},
+	} {
+		if found := ContainsWordPrefix(test.Text, test.Prefix); found != test.Found {
+			t.Errorf("Expected %v for %v in %v, got %v\n", test.Found, test.Prefix, test.Text, found)
+		}
+		if found := containsWordPrefixLower(test.Text, test.Prefix); found != test.Lower {
+			t.Errorf("Expected %v for %v in %v ignoring case, got %v\n", test.Lower, test.Prefix, test.Text, found)
+		}
+	}
+}
+
+func TestWholeWord(t *testing.T) {
+	record := SearchableMatchStringSlice([]string{"The cat sat on the mat.", "Category: pets"})
+	for _, test := range []struct {
+		Condition string
+		Options   Options
+		Match     bool
+	}{
+		{"cat", Options{}, true},
+		{"cat", Options{WholeWord: true}, true},
+		{"ate", Options{}, true},
+		{"ate", Options{WholeWord: true}, false},
+		{"mat", Options{WholeWord: true}, true},
+		{`"sat on"`, Options{WholeWord: true}, true},
+		{`"sat on th"`, Options{WholeWord: true}, false},
+		{"NOT ego", Options{WholeWord: true}, true},
+		{"category", Options{WholeWord: true}, false},
+		{"category", Options{WholeWord: true, CaseInsensitive: true}, true},
+	} {
+		query, err := QueryParserOptions(test.Condition, test.Options)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if result := query.Search(record); result != test.Match {
+			t.Errorf("Expected %v for %v with %+v, got %v\n", test.Match, test.Condition, test.Options, result)
+		}
+	}
+
+	fields := NewFieldSearchable().Field("title", func() string { return "Once upon a time" }).Build()
+	for condition, match := range map[string]bool{`title:^=Once`: true, `title:^=On`: false, `title:^="Once upon"`: true} {
+		query, err := QueryParserOptions(condition, Options{WholeWord: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\n", err)
+		}
+		if result := query.Search(fields); result != match {
+			t.Errorf("Expected %v for %v with WholeWord, got %v\n", match, condition, result)
+		}
+	}
+}
+
+func TestCollapseWhitespace(t *testing.T) {
+	for _, test := range []struct {
+		Text      string
+		Collapsed string
+	}{
+		{"goes here.\nMore", "goes here. More"},
+		{"a  \t\r\n b", "a b"},
+		{" leading and trailing\n", " leading and trailing "},
+		{"single spaces only", "single spaces only"},
+		{"", ""},
+	} {
+		if collapsed := collapseWhitespace(test.Text); collapsed != test.Collapsed {
+			t.Errorf("Expected %q for %q, got %q\n", test.Collapsed, test.Text, collapsed)
+		}
+	}
+}
+
+// testLineMaterial has a sentence broken across lines, for the options that normalise whitespace
+var testLineMaterial = SearchableMatchStringSlice([]string{`Raw testing subject pingo goes here.`,
+	`Raw body of the test message goes here.
+More than one line exists!`,
+})
+
+func TestNormalizeWhitespace(t *testing.T) {
+	for _, test := range []struct {
+		Condition string
+		Opts      Options
+		Plain     bool
+		Normal    bool
+	}{
+		{`"goes here. More than"`, Options{}, false, true},
+		{`"goes  here."`, Options{}, false, true},
+		{`"GOES here. more"`, Options{CaseInsensitive: true}, false, true},
+		{`"here. More"`, Options{WholeWord: true}, true, true},
+		{`"ere. More"`, Options{WholeWord: true}, false, false},
+		{`NOT "goes here. More"`, Options{}, true, false},
+	} {
+		q, _ := QueryParserOptions(test.Condition, test.Opts)
+		if result := q.Search(testLineMaterial); result != test.Plain {
+			t.Errorf("Expected %v for %v without normalising, got %v\n", test.Plain, test.Condition, result)
+		}
+		test.Opts.NormalizeWhitespace = true
+		q, _ = QueryParserOptions(test.Condition, test.Opts)
+		if result := q.Search(testLineMaterial); result != test.Normal {
+			t.Errorf("Expected %v for %v when normalising, got %v\n", test.Normal, test.Condition, result)
+		}
+	}
+}